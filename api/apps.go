@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -14,24 +15,47 @@ import (
 )
 
 type appResp struct {
-	ID        string     `json:"id"`
-	Name      string     `json:"name"`
-	CreatedAt *time.Time `json:"createdAt"`
-	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Owner       string            `json:"owner,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	CreatedAt   *time.Time        `json:"createdAt"`
+	UpdatedAt   *time.Time        `json:"updatedAt,omitempty"`
+	DeletedAt   *time.Time        `json:"deletedAt,omitempty"`
 }
 
 func toAppResp(app *aura.App) appResp {
 	return appResp{
-		ID:        app.ID,
-		Name:      app.Name,
-		CreatedAt: app.CreatedAt,
-		DeletedAt: app.DeletedAt,
+		ID:          app.ID,
+		Name:        app.Name,
+		Description: app.Description,
+		Owner:       app.Owner,
+		Labels:      app.Labels,
+		CreatedAt:   app.CreatedAt,
+		UpdatedAt:   app.UpdatedAt,
+		DeletedAt:   app.DeletedAt,
 	}
 }
 
+// includeDeleted determines if deleted apps were requested.
+func includeDeleted(req *http.Request) (bool, error) {
+	v := req.URL.Query().Get("include_deleted")
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
 func (s *Server) handleGetApps() http.HandlerFunc {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		apps, err := s.app.Apps(req.Context(), aura.AppsQuery{})
+		inclDeleted, err := includeDeleted(req)
+		if err != nil {
+			render.JSONError(rw, http.StatusBadRequest, "invalid include_deleted value")
+			return
+		}
+
+		apps, err := s.app.Apps(req.Context(), aura.AppsQuery{IncludeDeleted: inclDeleted})
 		if err != nil {
 			s.log.Error("Could not get apps", lctx.Error("error", err))
 			render.JSONInternalServerError(rw)
@@ -55,7 +79,13 @@ func (s *Server) handleGetApp() http.HandlerFunc {
 
 		log := s.log.With(lctx.Str("app_id", appID))
 
-		app, err := s.app.App(req.Context(), aura.AppsQuery{ID: appID})
+		inclDeleted, err := includeDeleted(req)
+		if err != nil {
+			render.JSONError(rw, http.StatusBadRequest, "invalid include_deleted value")
+			return
+		}
+
+		app, err := s.app.App(req.Context(), aura.AppsQuery{IDOrName: appID, IncludeDeleted: inclDeleted})
 		if err != nil {
 			switch {
 			case errors.Is(err, aura.ErrNotFound):
@@ -95,6 +125,8 @@ func (s *Server) handleCreateApp() http.HandlerFunc {
 			case errors.As(err, &aura.ValidationError{}):
 				s.log.Debug("Invalid app", lctx.Error("error", err))
 				render.JSONErrorf(rw, http.StatusBadRequest, "invalid app: %v", err)
+			case errors.Is(err, aura.ErrConflict):
+				render.JSONError(rw, http.StatusConflict, "an app with this name already exists")
 			default:
 				s.log.Error("Could not create app", lctx.Error("error", err))
 				render.JSONInternalServerError(rw)
@@ -110,6 +142,71 @@ func (s *Server) handleCreateApp() http.HandlerFunc {
 	}
 }
 
+type updateAppReq struct {
+	Name        *string            `json:"name"`
+	Description *string            `json:"description"`
+	Owner       *string            `json:"owner"`
+	Labels      map[string]*string `json:"labels"`
+}
+
+func (s *Server) handleUpdateApp() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		var appReq updateAppReq
+		if err := json.NewDecoder(req.Body).Decode(&appReq); err != nil {
+			log.Debug("Could not unmarshal body", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "invalid app data")
+			return
+		}
+
+		resp, err := s.updateApp(req.Context(), appID, appReq)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid app", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid app: %v", err)
+			case errors.Is(err, aura.ErrConflict):
+				render.JSONError(rw, http.StatusConflict, "an app with this name already exists")
+			default:
+				log.Error("Could not update app", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) updateApp(ctx context.Context, appID string, appReq updateAppReq) (appResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return appResp{}, err
+	}
+
+	app, err = s.app.Update(ctx, aura.UpdateConfig{
+		App:         app,
+		Name:        appReq.Name,
+		Description: appReq.Description,
+		Owner:       appReq.Owner,
+		Labels:      appReq.Labels,
+	})
+	if err != nil {
+		return appResp{}, err
+	}
+
+	return toAppResp(app), nil
+}
+
 func (s *Server) handleDestroyApp() http.HandlerFunc {
 	return func(rw http.ResponseWriter, req *http.Request) {
 		appID := chi.URLParam(req, "app")
@@ -133,10 +230,56 @@ func (s *Server) handleDestroyApp() http.HandlerFunc {
 }
 
 func (s *Server) destroyApp(ctx context.Context, appID string) error {
-	app, err := s.app.App(ctx, aura.AppsQuery{ID: appID})
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
 	if err != nil {
 		return err
 	}
 
 	return s.app.Destroy(ctx, aura.DestroyConfig{App: app})
 }
+
+func (s *Server) handleRestoreApp() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		resp, err := s.restoreApp(req.Context(), appID)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid app restore", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid app restore: %v", err)
+			case errors.Is(err, aura.ErrConflict):
+				render.JSONError(rw, http.StatusConflict, "an app with this name already exists")
+			default:
+				log.Error("Could not restore app", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) restoreApp(ctx context.Context, appID string) (appResp, error) {
+	// Deleted applications are only found by ID, as their names may be reused.
+	app, err := s.app.App(ctx, aura.AppsQuery{ID: appID, IncludeDeleted: true})
+	if err != nil {
+		return appResp{}, err
+	}
+
+	app, err = s.app.Restore(ctx, aura.RestoreConfig{App: app})
+	if err != nil {
+		return appResp{}, err
+	}
+
+	return toAppResp(app), nil
+}
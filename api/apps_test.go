@@ -24,9 +24,9 @@ func TestServer_HandleGetApps(t *testing.T) {
 	}{
 		{
 			name:           "handles request",
-			apps:           []*aura.App{{ID: "test", Name: "test app", CreatedAt: &now}},
+			apps:           []*aura.App{{ID: "test", Name: "test-app", CreatedAt: &now}},
 			wantStatusCode: http.StatusOK,
-			wantResp:       `[{"id":"test","name":"test app","createdAt":"2022-02-01T04:00:00Z"}]`,
+			wantResp:       `[{"id":"test","name":"test-app","createdAt":"2022-02-01T04:00:00Z"}]`,
 		},
 		{
 			name:           "handles app error",
@@ -57,6 +57,41 @@ func TestServer_HandleGetApps(t *testing.T) {
 	}
 }
 
+func TestServer_HandleGetAppsIncludesDeleted(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	app := &mockApp{}
+	app.On("Apps", aura.AppsQuery{IncludeDeleted: true}).Return([]*aura.App{{ID: "test", Name: "test-app", CreatedAt: &now, DeletedAt: &now}}, nil)
+
+	srvUrl := setupTestServer(t, app)
+
+	resp := requireDoRequest(t, http.MethodGet, srvUrl+"/apps?include_deleted=true", nil)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	got, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id":"test","name":"test-app","createdAt":"2022-02-01T04:00:00Z","deletedAt":"2022-02-01T04:00:00Z"}]`, string(got))
+	app.AssertExpectations(t)
+}
+
+func TestServer_HandleGetAppsHandlesInvalidIncludeDeleted(t *testing.T) {
+	app := &mockApp{}
+
+	srvUrl := setupTestServer(t, app)
+
+	resp := requireDoRequest(t, http.MethodGet, srvUrl+"/apps?include_deleted=maybe", nil)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	got, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"error":"invalid include_deleted value"}`, string(got))
+	app.AssertExpectations(t)
+}
+
 func TestServer_HandleGetApp(t *testing.T) {
 	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
 
@@ -69,9 +104,9 @@ func TestServer_HandleGetApp(t *testing.T) {
 	}{
 		{
 			name:           "handles request",
-			app:            &aura.App{ID: "test", Name: "test app", CreatedAt: &now},
+			app:            &aura.App{ID: "test", Name: "test-app", CreatedAt: &now},
 			wantStatusCode: http.StatusOK,
-			wantResp:       `{"id":"test","name":"test app","createdAt":"2022-02-01T04:00:00Z"}`,
+			wantResp:       `{"id":"test","name":"test-app","createdAt":"2022-02-01T04:00:00Z"}`,
 		},
 		{
 			name:           "handles app not found",
@@ -91,7 +126,7 @@ func TestServer_HandleGetApp(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			app := &mockApp{}
-			app.On("App", aura.AppsQuery{ID: "123"}).Return(test.app, test.err)
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(test.app, test.err)
 
 			srvUrl := setupTestServer(t, app)
 
@@ -126,11 +161,11 @@ func TestServer_HandleCreateApp(t *testing.T) {
 	}{
 		{
 			name:           "handles request",
-			req:            `{"name":"test app"}`,
-			app:            &aura.App{ID: "123", Name: "test app", CreatedAt: &now},
-			wantAppName:    "test app",
+			req:            `{"name":"test-app"}`,
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			wantAppName:    "test-app",
 			wantStatusCode: http.StatusOK,
-			wantResp:       `{"id":"123","name":"test app","createdAt":"2022-02-01T04:00:00Z"}`,
+			wantResp:       `{"id":"123","name":"test-app","createdAt":"2022-02-01T04:00:00Z"}`,
 		},
 		{
 			name:           "handles invalid json",
@@ -140,17 +175,25 @@ func TestServer_HandleCreateApp(t *testing.T) {
 		},
 		{
 			name:           "handles validation error",
-			req:            `{"name":"test app"}`,
+			req:            `{"name":"test-app"}`,
 			err:            aura.ValidationError{},
-			wantAppName:    "test app",
+			wantAppName:    "test-app",
 			wantStatusCode: http.StatusBadRequest,
 			wantResp:       `{"error":"invalid app: validation error"}`,
 		},
+		{
+			name:           "handles name conflict",
+			req:            `{"name":"test-app"}`,
+			err:            aura.ErrConflict,
+			wantAppName:    "test-app",
+			wantStatusCode: http.StatusConflict,
+			wantResp:       `{"error":"an app with this name already exists"}`,
+		},
 		{
 			name:           "handles app error",
-			req:            `{"name":"test app"}`,
+			req:            `{"name":"test-app"}`,
 			err:            errors.New("test"),
-			wantAppName:    "test app",
+			wantAppName:    "test-app",
 			wantStatusCode: http.StatusInternalServerError,
 			wantResp:       `{"error":"internal server error"}`,
 		},
@@ -179,6 +222,132 @@ func TestServer_HandleCreateApp(t *testing.T) {
 	}
 }
 
+func TestServer_HandleUpdateApp(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	name, desc, team := "new-app", "A test app", "a"
+
+	tests := []struct {
+		name           string
+		req            string
+		app            *aura.App
+		findErr        error
+		wantCfg        *aura.UpdateConfig
+		updated        *aura.App
+		updateErr      error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name: "handles request",
+			req:  `{"name":"new-app","description":"A test app","labels":{"team":"a","tier":null}}`,
+			app:  &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			wantCfg: &aura.UpdateConfig{
+				Name:        &name,
+				Description: &desc,
+				Labels:      map[string]*string{"team": &team, "tier": nil},
+			},
+			updated: &aura.App{
+				ID:          "123",
+				Name:        "new-app",
+				Description: "A test app",
+				Labels:      aura.Labels{"team": "a"},
+				CreatedAt:   &now,
+				UpdatedAt:   &later,
+			},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"123","name":"new-app","description":"A test app","labels":{"team":"a"},"createdAt":"2022-02-01T04:00:00Z","updatedAt":"2022-02-01T05:00:00Z"}`,
+		},
+		{
+			name:           "handles invalid json",
+			req:            `{"name":"new-app}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid app data"}`,
+		},
+		{
+			name:           "handles app not found",
+			req:            `{"name":"new-app"}`,
+			findErr:        aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles validation error",
+			req:            `{"name":"new-app"}`,
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			wantCfg:        &aura.UpdateConfig{Name: &name},
+			updateErr:      aura.ValidationError{},
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid app: validation error"}`,
+		},
+		{
+			name:           "handles name conflict",
+			req:            `{"name":"new-app"}`,
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			wantCfg:        &aura.UpdateConfig{Name: &name},
+			updateErr:      aura.ErrConflict,
+			wantStatusCode: http.StatusConflict,
+			wantResp:       `{"error":"an app with this name already exists"}`,
+		},
+		{
+			name:           "handles app error",
+			req:            `{"name":"new-app"}`,
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			wantCfg:        &aura.UpdateConfig{Name: &name},
+			updateErr:      errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			app := &mockApp{}
+			if test.app != nil || test.findErr != nil {
+				app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(test.app, test.findErr)
+			}
+			if test.wantCfg != nil {
+				cfg := *test.wantCfg
+				cfg.App = test.app
+				app.On("Update", cfg).Return(test.updated, test.updateErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodPatch, srvUrl+"/apps/123", []byte(test.req))
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleGetAppIncludesDeleted(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	app := &mockApp{}
+	app.On("App", aura.AppsQuery{IDOrName: "123", IncludeDeleted: true}).Return(&aura.App{ID: "123", Name: "test-app", CreatedAt: &now, DeletedAt: &now}, nil)
+
+	srvUrl := setupTestServer(t, app)
+
+	resp := requireDoRequest(t, http.MethodGet, srvUrl+"/apps/123?include_deleted=1", nil)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	got, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"123","name":"test-app","createdAt":"2022-02-01T04:00:00Z","deletedAt":"2022-02-01T04:00:00Z"}`, string(got))
+	app.AssertExpectations(t)
+}
+
 func TestServer_HandleDestroyApp(t *testing.T) {
 	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
 
@@ -192,7 +361,7 @@ func TestServer_HandleDestroyApp(t *testing.T) {
 	}{
 		{
 			name:           "handles request",
-			app:            &aura.App{ID: "test", Name: "test app", CreatedAt: &now},
+			app:            &aura.App{ID: "test", Name: "test-app", CreatedAt: &now},
 			wantStatusCode: http.StatusNoContent,
 		},
 		{
@@ -203,7 +372,7 @@ func TestServer_HandleDestroyApp(t *testing.T) {
 		},
 		{
 			name:           "handles app error",
-			app:            &aura.App{ID: "test", Name: "test app", CreatedAt: &now},
+			app:            &aura.App{ID: "test", Name: "test-app", CreatedAt: &now},
 			destroyErr:     errors.New("test"),
 			wantStatusCode: http.StatusInternalServerError,
 			wantResp:       `{"error":"internal server error"}`,
@@ -214,7 +383,7 @@ func TestServer_HandleDestroyApp(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			app := &mockApp{}
-			app.On("App", aura.AppsQuery{ID: "123"}).Return(test.app, test.findErr)
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(test.app, test.findErr)
 			if test.app != nil {
 				app.On("Destroy", aura.DestroyConfig{App: test.app}).Return(test.destroyErr)
 			}
@@ -233,3 +402,76 @@ func TestServer_HandleDestroyApp(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_HandleRestoreApp(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name           string
+		app            *aura.App
+		findErr        error
+		restored       *aura.App
+		restoreErr     error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now, DeletedAt: &now},
+			restored:       &aura.App{ID: "123", Name: "test-app", CreatedAt: &now, UpdatedAt: &later},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"123","name":"test-app","createdAt":"2022-02-01T04:00:00Z","updatedAt":"2022-02-01T05:00:00Z"}`,
+		},
+		{
+			name:           "handles app not found",
+			findErr:        aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles validation error",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			restoreErr:     aura.ValidationError{},
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid app restore: validation error"}`,
+		},
+		{
+			name:           "handles name conflict",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now, DeletedAt: &now},
+			restoreErr:     aura.ErrConflict,
+			wantStatusCode: http.StatusConflict,
+			wantResp:       `{"error":"an app with this name already exists"}`,
+		},
+		{
+			name:           "handles app error",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now, DeletedAt: &now},
+			restoreErr:     errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{ID: "123", IncludeDeleted: true}).Return(test.app, test.findErr)
+			if test.app != nil {
+				app.On("Restore", aura.RestoreConfig{App: test.app}).Return(test.restored, test.restoreErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodPost, srvUrl+"/apps/123/restore", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/render"
+)
+
+type configResp struct {
+	Version   int               `json:"version"`
+	Vars      map[string]string `json:"vars"`
+	CreatedAt *time.Time        `json:"createdAt"`
+}
+
+func toConfigResp(cfg *aura.Config) configResp {
+	resp := configResp{
+		Version:   cfg.Version,
+		Vars:      cfg.Vars,
+		CreatedAt: cfg.CreatedAt,
+	}
+	if resp.Vars == nil {
+		resp.Vars = map[string]string{}
+	}
+	return resp
+}
+
+func (s *Server) handleGetConfig() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		app, err := s.app.App(req.Context(), aura.AppsQuery{IDOrName: appID})
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			default:
+				log.Error("Could not get app", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		cfg, err := s.app.Config(req.Context(), aura.ConfigsQuery{App: app})
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				// The app has never been configured.
+				cfg = &aura.Config{}
+			default:
+				log.Error("Could not get config", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+				return
+			}
+		}
+
+		resp := toConfigResp(cfg)
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) handleUpdateConfig() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		var vars map[string]*string
+		if err := json.NewDecoder(req.Body).Decode(&vars); err != nil {
+			log.Debug("Could not unmarshal body", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "invalid config data")
+			return
+		}
+
+		resp, err := s.updateConfig(req.Context(), appID, vars)
+		if err != nil {
+			switch {
+			case errors.As(err, &aura.RedeployError{}):
+				log.Error("Could not redeploy config", lctx.Int("version", resp.Version), lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusInternalServerError, "config version %d was saved, but could not be redeployed", resp.Version)
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid config", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid config: %v", err)
+			default:
+				log.Error("Could not update config", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) updateConfig(ctx context.Context, appID string, vars map[string]*string) (configResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return configResp{}, err
+	}
+
+	cfg, err := s.app.SetVars(ctx, aura.SetVarsConfig{
+		App:  app,
+		Vars: vars,
+	})
+	if err != nil {
+		if cfg != nil {
+			// The config was saved, even though the redeploy failed.
+			return toConfigResp(cfg), err
+		}
+		return configResp{}, err
+	}
+
+	return toConfigResp(cfg), nil
+}
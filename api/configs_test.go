@@ -0,0 +1,177 @@
+package api_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleGetConfig(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		appErr         error
+		cfg            *aura.Config
+		cfgErr         error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			cfg:            &aura.Config{ID: "abc", Version: 2, Vars: aura.Vars{"FOO": "bar"}, CreatedAt: &now},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"version":2,"vars":{"FOO":"bar"},"createdAt":"2022-02-01T04:00:00Z"}`,
+		},
+		{
+			name:           "handles no config",
+			cfgErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"version":0,"vars":{},"createdAt":null}`,
+		},
+		{
+			name:           "handles app not found",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles app find error",
+			appErr:         errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+		{
+			name:           "handles config error",
+			cfgErr:         errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
+			if test.cfg != nil || test.cfgErr != nil {
+				app.On("Config", aura.ConfigsQuery{App: a}).Return(test.cfg, test.cfgErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodGet, srvUrl+"/apps/123/config", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleUpdateConfig(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+	bar := "bar"
+
+	tests := []struct {
+		name           string
+		req            string
+		appErr         error
+		wantVars       map[string]*string
+		cfg            *aura.Config
+		cfgErr         error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			req:            `{"FOO":"bar","BAZ":null}`,
+			wantVars:       map[string]*string{"FOO": &bar, "BAZ": nil},
+			cfg:            &aura.Config{ID: "abc", Version: 3, Vars: aura.Vars{"FOO": "bar"}, CreatedAt: &now},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"version":3,"vars":{"FOO":"bar"},"createdAt":"2022-02-01T04:00:00Z"}`,
+		},
+		{
+			name:           "handles invalid json",
+			req:            `{"FOO":"bar}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid config data"}`,
+		},
+		{
+			name:           "handles app not found",
+			req:            `{"FOO":"bar"}`,
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles app find error",
+			req:            `{"FOO":"bar"}`,
+			appErr:         errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+		{
+			name:           "handles validation error",
+			req:            `{"FOO":"bar"}`,
+			wantVars:       map[string]*string{"FOO": &bar},
+			cfgErr:         aura.ValidationError{},
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid config: validation error"}`,
+		},
+		{
+			name:           "handles redeploy error",
+			req:            `{"FOO":"bar"}`,
+			wantVars:       map[string]*string{"FOO": &bar},
+			cfg:            &aura.Config{ID: "abc", Version: 3, Vars: aura.Vars{"FOO": "bar"}, CreatedAt: &now},
+			cfgErr:         aura.RedeployError{Err: errors.New("test")},
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"config version 3 was saved, but could not be redeployed"}`,
+		},
+		{
+			name:           "handles config error",
+			req:            `{"FOO":"bar"}`,
+			wantVars:       map[string]*string{"FOO": &bar},
+			cfgErr:         errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Maybe().Return(a, test.appErr)
+			if test.wantVars != nil {
+				app.On("SetVars", aura.SetVarsConfig{App: a, Vars: test.wantVars}).Return(test.cfg, test.cfgErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodPatch, srvUrl+"/apps/123/config", []byte(test.req))
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/nrwiersma/aura/pkg/render"
+)
+
+type deploymentResp struct {
+	ID        string       `json:"id"`
+	Image     string       `json:"image"`
+	Platform  string       `json:"platform,omitempty"`
+	Status    string       `json:"status"`
+	Error     string       `json:"error,omitempty"`
+	Release   *releaseResp `json:"release,omitempty"`
+	CreatedAt *time.Time   `json:"createdAt"`
+	UpdatedAt *time.Time   `json:"updatedAt"`
+}
+
+func toDeploymentResp(deployment *aura.Deployment) deploymentResp {
+	resp := deploymentResp{
+		ID:        deployment.ID,
+		Image:     deployment.Image,
+		Platform:  deployment.Platform,
+		Status:    deployment.Status,
+		Error:     deployment.Error,
+		CreatedAt: deployment.CreatedAt,
+		UpdatedAt: deployment.UpdatedAt,
+	}
+	if deployment.Release != nil {
+		release := toReleaseResp(deployment.Release)
+		resp.Release = &release
+	}
+	return resp
+}
+
+type deployAppReq struct {
+	Image string `json:"image"`
+	// Platform is the platform to resolve multi-platform images
+	// to, in the form os/arch[/variant]. It is optional.
+	Platform string `json:"platform"`
+	// OnConflict determines what happens when another deploy of the app
+	// is running. It can be "queue", the default, or "reject".
+	OnConflict string `json:"onConflict"`
+}
+
+func (r deployAppReq) image() (image.Image, error) {
+	img, err := image.Decode(r.Image)
+	if err != nil {
+		return image.Image{}, err
+	}
+	if r.Platform != "" {
+		if img.Platform, err = image.ParsePlatform(r.Platform); err != nil {
+			return image.Image{}, err
+		}
+	}
+	return img, nil
+}
+
+func (r deployAppReq) noWait() (bool, error) {
+	switch r.OnConflict {
+	case "", "queue":
+		return false, nil
+	case "reject":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown conflict behaviour %q", r.OnConflict)
+	}
+}
+
+func (s *Server) handleDeployApp() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		var appReq deployAppReq
+		if err := json.NewDecoder(req.Body).Decode(&appReq); err != nil {
+			log.Debug("Could not unmarshal body", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "invalid app deployment data")
+			return
+		}
+
+		img, err := appReq.image()
+		if err != nil {
+			log.Debug("Invalid deployment", lctx.Error("error", err))
+			render.JSONErrorf(rw, http.StatusBadRequest, "invalid app deploy: %v", err)
+			return
+		}
+		noWait, err := appReq.noWait()
+		if err != nil {
+			log.Debug("Invalid deployment", lctx.Error("error", err))
+			render.JSONErrorf(rw, http.StatusBadRequest, "invalid app deploy: %v", err)
+			return
+		}
+
+		resp, err := s.deployApp(req.Context(), appID, img, noWait)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			case errors.Is(err, aura.ErrConflict):
+				log.Debug("Deploy conflicts with a running deploy")
+				render.JSONError(rw, http.StatusConflict, "a deploy is already running for this app")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid deployment", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid app deploy: %v", err)
+			default:
+				log.Error("Could not deploy app", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		rw.Header().Set("Location", "/apps/"+appID+"/deploys/"+resp.ID)
+		if err = render.JSON(rw, http.StatusAccepted, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) deployApp(ctx context.Context, appID string, img image.Image, noWait bool) (deploymentResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return deploymentResp{}, err
+	}
+
+	deployment, err := s.app.CreateDeployment(ctx, aura.DeployConfig{
+		App:    app,
+		Image:  img,
+		NoWait: noWait,
+	})
+	if err != nil {
+		return deploymentResp{}, err
+	}
+
+	return toDeploymentResp(deployment), nil
+}
+
+type deployStatusEvent struct {
+	Status string `json:"status"`
+}
+
+type deployPullEvent struct {
+	ID       string `json:"id,omitempty"`
+	Status   string `json:"status"`
+	Progress string `json:"progress,omitempty"`
+	Current  int64  `json:"current,omitempty"`
+	Total    int64  `json:"total,omitempty"`
+}
+
+// streamContentType returns the accepted event stream content type
+// of the request, if any.
+func streamContentType(req *http.Request) string {
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		switch mediaType = strings.TrimSpace(mediaType); mediaType {
+		case render.ContentTypeEventStream, render.ContentTypeNDJSON:
+			return mediaType
+		}
+	}
+	return ""
+}
+
+func (s *Server) handleStreamDeployApp() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		var appReq deployAppReq
+		if err := json.NewDecoder(req.Body).Decode(&appReq); err != nil {
+			log.Debug("Could not unmarshal body", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "invalid app deployment data")
+			return
+		}
+
+		img, err := appReq.image()
+		if err != nil {
+			log.Debug("Invalid deployment", lctx.Error("error", err))
+			render.JSONErrorf(rw, http.StatusBadRequest, "invalid app deploy: %v", err)
+			return
+		}
+		noWait, err := appReq.noWait()
+		if err != nil {
+			log.Debug("Invalid deployment", lctx.Error("error", err))
+			render.JSONErrorf(rw, http.StatusBadRequest, "invalid app deploy: %v", err)
+			return
+		}
+
+		events := render.NewEventWriter(rw, streamContentType(req))
+		resp, err := s.streamDeployApp(req.Context(), appID, img, noWait, func(event aura.DeployEvent) {
+			var err error
+			switch {
+			case event.Pull != nil:
+				err = events.Write("pull", deployPullEvent{
+					ID:       event.Pull.ID,
+					Status:   event.Pull.Status,
+					Progress: event.Pull.Progress,
+					Current:  event.Pull.Current,
+					Total:    event.Pull.Total,
+				})
+			default:
+				err = events.Write("status", deployStatusEvent{Status: event.Status})
+			}
+			if err != nil {
+				// The client has most likely gone away, the deployment continues.
+				log.Debug("Could not write deploy event", lctx.Error("error", err))
+			}
+		})
+		if err != nil {
+			switch {
+			case events.Started():
+				log.Error("Could not deploy app", lctx.Error("error", err))
+				_ = events.Write("error", map[string]string{"error": "internal server error"})
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			case errors.Is(err, aura.ErrConflict):
+				log.Debug("Deploy conflicts with a running deploy")
+				render.JSONError(rw, http.StatusConflict, "a deploy is already running for this app")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid deployment", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid app deploy: %v", err)
+			default:
+				log.Error("Could not deploy app", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = events.Write("deployment", resp); err != nil {
+			log.Debug("Could not write deploy event", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) streamDeployApp(ctx context.Context, appID string, img image.Image, noWait bool, progress func(aura.DeployEvent)) (deploymentResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return deploymentResp{}, err
+	}
+
+	deployment, err := s.app.StreamDeployment(ctx, aura.DeployConfig{
+		App:    app,
+		Image:  img,
+		NoWait: noWait,
+	}, progress)
+	if err != nil {
+		return deploymentResp{}, err
+	}
+
+	return toDeploymentResp(deployment), nil
+}
+
+func (s *Server) handleGetDeploy() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+		id := chi.URLParam(req, "id")
+
+		log := s.log.With(lctx.Str("app_id", appID), lctx.Str("deployment_id", id))
+
+		resp, err := s.getDeploy(req.Context(), appID, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App or deployment not found")
+				render.JSONError(rw, http.StatusNotFound, "app or deployment not found")
+			default:
+				log.Error("Could not get deployment", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) getDeploy(ctx context.Context, appID, id string) (deploymentResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return deploymentResp{}, err
+	}
+
+	deployment, err := s.app.Deployment(ctx, aura.DeploymentsQuery{App: app, ID: id})
+	if err != nil {
+		return deploymentResp{}, err
+	}
+
+	return toDeploymentResp(deployment), nil
+}
+
+func (s *Server) handleCancelDeploy() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+		id := chi.URLParam(req, "id")
+
+		log := s.log.With(lctx.Str("app_id", appID), lctx.Str("deployment_id", id))
+
+		resp, err := s.cancelDeploy(req.Context(), appID, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App or deployment not found")
+				render.JSONError(rw, http.StatusNotFound, "app or deployment not found")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid deployment cancel", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid deployment cancel: %v", err)
+			default:
+				log.Error("Could not cancel deployment", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusAccepted, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) cancelDeploy(ctx context.Context, appID, id string) (deploymentResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return deploymentResp{}, err
+	}
+
+	deployment, err := s.app.CancelDeployment(ctx, aura.CancelDeploymentConfig{App: app, ID: id})
+	if err != nil {
+		return deploymentResp{}, err
+	}
+
+	return toDeploymentResp(deployment), nil
+}
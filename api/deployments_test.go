@@ -0,0 +1,443 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleDeployApp(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		req            string
+		appErr         error
+		deployment     *aura.Deployment
+		deploymentErr  error
+		wantImage      string
+		wantPlatform   image.Platform
+		wantNoWait     bool
+		wantStatusCode int
+		wantLocation   string
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			req:            `{"image":"foo/bar:latest"}`,
+			deployment:     &aura.Deployment{ID: "test", AppID: "123", Image: "foo/bar:latest", Status: aura.DeploymentPending},
+			wantImage:      "foo/bar:latest",
+			wantStatusCode: http.StatusAccepted,
+			wantLocation:   "/apps/123/deploys/test",
+			wantResp:       `{"id":"test","image":"foo/bar:latest","status":"pending","createdAt":null,"updatedAt":null}`,
+		},
+		{
+			name:           "handles rejecting conflicts",
+			req:            `{"image":"foo/bar:latest","onConflict":"reject"}`,
+			deployment:     &aura.Deployment{ID: "test", AppID: "123", Image: "foo/bar:latest", Status: aura.DeploymentPending},
+			wantImage:      "foo/bar:latest",
+			wantNoWait:     true,
+			wantStatusCode: http.StatusAccepted,
+			wantLocation:   "/apps/123/deploys/test",
+			wantResp:       `{"id":"test","image":"foo/bar:latest","status":"pending","createdAt":null,"updatedAt":null}`,
+		},
+		{
+			name:           "handles platform",
+			req:            `{"image":"foo/bar:latest","platform":"linux/arm64"}`,
+			deployment:     &aura.Deployment{ID: "test", AppID: "123", Image: "foo/bar:latest", Platform: "linux/arm64", Status: aura.DeploymentPending},
+			wantImage:      "foo/bar:latest",
+			wantPlatform:   image.Platform{OS: "linux", Architecture: "arm64"},
+			wantStatusCode: http.StatusAccepted,
+			wantLocation:   "/apps/123/deploys/test",
+			wantResp:       `{"id":"test","image":"foo/bar:latest","platform":"linux/arm64","status":"pending","createdAt":null,"updatedAt":null}`,
+		},
+		{
+			name:           "handles invalid platform",
+			req:            `{"image":"foo/bar:latest","platform":"linux"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid app deploy: invalid platform \"linux\", expected os/arch[/variant]"}`,
+		},
+		{
+			name:           "handles invalid json",
+			req:            `{"image":"foo/bar:latest}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid app deployment data"}`,
+		},
+		{
+			name:           "handles empty image",
+			req:            `{"image":""}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid app deploy: invalid image format"}`,
+		},
+		{
+			name:           "handles invalid conflict behaviour",
+			req:            `{"image":"foo/bar:latest","onConflict":"wait"}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid app deploy: unknown conflict behaviour \"wait\""}`,
+		},
+		{
+			name:           "handles app not found",
+			req:            `{"image":"foo/bar:latest"}`,
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles app find error",
+			req:            `{"image":"foo/bar:latest"}`,
+			appErr:         errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+		{
+			name:           "handles conflict",
+			req:            `{"image":"foo/bar:latest","onConflict":"reject"}`,
+			deploymentErr:  aura.ErrConflict,
+			wantImage:      "foo/bar:latest",
+			wantNoWait:     true,
+			wantStatusCode: http.StatusConflict,
+			wantResp:       `{"error":"a deploy is already running for this app"}`,
+		},
+		{
+			name:           "handles validation error",
+			req:            `{"image":"foo/bar:latest"}`,
+			deploymentErr:  aura.ValidationError{},
+			wantImage:      "foo/bar:latest",
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid app deploy: validation error"}`,
+		},
+		{
+			name:           "handles deployment error",
+			req:            `{"image":"foo/bar:latest"}`,
+			deploymentErr:  errors.New("test"),
+			wantImage:      "foo/bar:latest",
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Maybe().Return(a, test.appErr)
+
+			if test.wantImage != "" {
+				img, err := image.Decode(test.wantImage)
+				require.NoError(t, err)
+				img.Platform = test.wantPlatform
+
+				app.On("CreateDeployment", aura.DeployConfig{App: a, Image: img, NoWait: test.wantNoWait}).Return(test.deployment, test.deploymentErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodPost, srvUrl+"/apps/123/deploys", []byte(test.req))
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+			assert.Equal(t, test.wantLocation, resp.Header.Get("Location"))
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleGetDeploy(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		appErr         error
+		deployment     *aura.Deployment
+		deploymentErr  error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name: "handles request",
+			deployment: &aura.Deployment{
+				ID:      "test",
+				AppID:   "123",
+				Image:   "foo/bar:latest",
+				Status:  aura.DeploymentSucceeded,
+				Release: &aura.Release{ID: "rel", AppID: "123", Version: 2},
+			},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"test","image":"foo/bar:latest","status":"succeeded","release":{"id":"rel","app":{"id":"","name":"","createdAt":null},"image":"","version":2,"procfile":"","processes":[],"reason":"","createdAt":null},"createdAt":null,"updatedAt":null}`,
+		},
+		{
+			name:           "handles failed deployment",
+			deployment:     &aura.Deployment{ID: "test", AppID: "123", Image: "foo/bar:latest", Status: aura.DeploymentFailed, Error: "could not extract"},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"test","image":"foo/bar:latest","status":"failed","error":"could not extract","createdAt":null,"updatedAt":null}`,
+		},
+		{
+			name:           "handles app not found",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or deployment not found"}`,
+		},
+		{
+			name:           "handles deployment not found",
+			deploymentErr:  aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or deployment not found"}`,
+		},
+		{
+			name:           "handles deployment error",
+			deploymentErr:  errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
+			app.On("Deployment", aura.DeploymentsQuery{App: a, ID: "test"}).Maybe().Return(test.deployment, test.deploymentErr)
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodGet, srvUrl+"/apps/123/deploys/test", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleCancelDeploy(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		appErr         error
+		deployment     *aura.Deployment
+		deploymentErr  error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			deployment:     &aura.Deployment{ID: "test", AppID: "123", Image: "foo/bar:latest", Status: aura.DeploymentCancelled},
+			wantStatusCode: http.StatusAccepted,
+			wantResp:       `{"id":"test","image":"foo/bar:latest","status":"cancelled","createdAt":null,"updatedAt":null}`,
+		},
+		{
+			name:           "handles app not found",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or deployment not found"}`,
+		},
+		{
+			name:           "handles deployment not found",
+			deploymentErr:  aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or deployment not found"}`,
+		},
+		{
+			name:           "handles validation error",
+			deploymentErr:  aura.ValidationError{},
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid deployment cancel: validation error"}`,
+		},
+		{
+			name:           "handles deployment error",
+			deploymentErr:  errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
+			app.On("CancelDeployment", aura.CancelDeploymentConfig{App: a, ID: "test"}).Maybe().Return(test.deployment, test.deploymentErr)
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodPost, srvUrl+"/apps/123/deploys/test/cancel", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleStreamDeployApp(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	progress := func(fn func(aura.DeployEvent)) {
+		fn(aura.DeployEvent{Status: aura.DeploymentResolving})
+		fn(aura.DeployEvent{Status: aura.DeploymentResolving, Pull: &aura.PullProgress{ID: "abc", Status: "Downloading", Current: 1, Total: 2}})
+		fn(aura.DeployEvent{Status: aura.DeploymentExtracting})
+	}
+
+	tests := []struct {
+		name            string
+		accept          string
+		idempotencyKey  string
+		req             string
+		appErr          error
+		deployment      *aura.Deployment
+		deploymentErr   error
+		wantImage       string
+		wantNoWait      bool
+		wantStatusCode  int
+		wantContentType string
+		wantResp        string
+	}{
+		{
+			name:            "handles server-sent events",
+			accept:          "text/event-stream",
+			req:             `{"image":"foo/bar:latest"}`,
+			deployment:      &aura.Deployment{ID: "test", AppID: "123", Image: "foo/bar:latest", Status: aura.DeploymentSucceeded},
+			wantImage:       "foo/bar:latest",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/event-stream",
+			wantResp: "event: status\ndata: {\"status\":\"resolving\"}\n\n" +
+				"event: pull\ndata: {\"id\":\"abc\",\"status\":\"Downloading\",\"current\":1,\"total\":2}\n\n" +
+				"event: status\ndata: {\"status\":\"extracting\"}\n\n" +
+				"event: deployment\ndata: {\"id\":\"test\",\"image\":\"foo/bar:latest\",\"status\":\"succeeded\",\"createdAt\":null,\"updatedAt\":null}\n\n",
+		},
+		{
+			name:            "handles json lines",
+			accept:          "application/json;q=0.5, application/x-ndjson",
+			req:             `{"image":"foo/bar:latest"}`,
+			deployment:      &aura.Deployment{ID: "test", AppID: "123", Image: "foo/bar:latest", Status: aura.DeploymentFailed, Error: "test"},
+			wantImage:       "foo/bar:latest",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/x-ndjson",
+			wantResp: `{"event":"status","data":{"status":"resolving"}}` + "\n" +
+				`{"event":"pull","data":{"id":"abc","status":"Downloading","current":1,"total":2}}` + "\n" +
+				`{"event":"status","data":{"status":"extracting"}}` + "\n" +
+				`{"event":"deployment","data":{"id":"test","image":"foo/bar:latest","status":"failed","error":"test","createdAt":null,"updatedAt":null}}` + "\n",
+		},
+		{
+			name:            "handles error after the stream started",
+			accept:          "application/x-ndjson",
+			req:             `{"image":"foo/bar:latest"}`,
+			deploymentErr:   errors.New("test"),
+			wantImage:       "foo/bar:latest",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/x-ndjson",
+			wantResp: `{"event":"status","data":{"status":"resolving"}}` + "\n" +
+				`{"event":"pull","data":{"id":"abc","status":"Downloading","current":1,"total":2}}` + "\n" +
+				`{"event":"status","data":{"status":"extracting"}}` + "\n" +
+				`{"event":"error","data":{"error":"internal server error"}}` + "\n",
+		},
+		{
+			name:            "handles invalid json",
+			accept:          "text/event-stream",
+			req:             `{"image":"foo/bar:latest}`,
+			wantStatusCode:  http.StatusBadRequest,
+			wantContentType: "application/json",
+			wantResp:        `{"error":"invalid app deployment data"}`,
+		},
+		{
+			name:            "handles conflict",
+			accept:          "text/event-stream",
+			req:             `{"image":"foo/bar:latest","onConflict":"reject"}`,
+			deploymentErr:   aura.ErrConflict,
+			wantImage:       "foo/bar:latest",
+			wantNoWait:      true,
+			wantStatusCode:  http.StatusConflict,
+			wantContentType: "application/json",
+			wantResp:        `{"error":"a deploy is already running for this app"}`,
+		},
+		{
+			name:            "handles idempotency key",
+			accept:          "text/event-stream",
+			idempotencyKey:  "test-key",
+			req:             `{"image":"foo/bar:latest"}`,
+			wantStatusCode:  http.StatusBadRequest,
+			wantContentType: "application/json",
+			wantResp:        `{"error":"idempotency keys are not supported on streamed deploys"}`,
+		},
+		{
+			name:            "handles app not found",
+			accept:          "text/event-stream",
+			req:             `{"image":"foo/bar:latest"}`,
+			appErr:          aura.ErrNotFound,
+			wantStatusCode:  http.StatusNotFound,
+			wantContentType: "application/json",
+			wantResp:        `{"error":"app not found"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Maybe().Return(a, test.appErr)
+
+			if test.wantImage != "" {
+				img, err := image.Decode(test.wantImage)
+				require.NoError(t, err)
+
+				deployCfg := aura.DeployConfig{App: a, Image: img, NoWait: test.wantNoWait}
+				if errors.Is(test.deploymentErr, aura.ErrConflict) {
+					app.On("StreamDeployment", deployCfg).Return(test.deployment, test.deploymentErr)
+				} else {
+					app.On("StreamDeployment", deployCfg).Return(test.deployment, test.deploymentErr, progress)
+				}
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srvUrl+"/apps/123/deploys", strings.NewReader(test.req))
+			require.NoError(t, err)
+			req.Header.Set("Accept", test.accept)
+			if test.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", test.idempotencyKey)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+			assert.Equal(t, test.wantContentType, resp.Header.Get("Content-Type"))
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
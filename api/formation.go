@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/render"
+)
+
+type formationResp struct {
+	Process  string `json:"process"`
+	Quantity int    `json:"quantity"`
+	Size     string `json:"size"`
+}
+
+func toFormationResp(formation []*aura.Formation) []formationResp {
+	resp := make([]formationResp, 0, len(formation))
+	for _, f := range formation {
+		resp = append(resp, formationResp{
+			Process:  f.Process,
+			Quantity: f.Quantity,
+			Size:     f.Size,
+		})
+	}
+	return resp
+}
+
+func (s *Server) handleGetFormation() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		resp, err := s.getFormation(req.Context(), appID)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App or release not found")
+				render.JSONError(rw, http.StatusNotFound, "app or release not found")
+			default:
+				log.Error("Could not get formation", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) getFormation(ctx context.Context, appID string) ([]formationResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return nil, err
+	}
+
+	formation, err := s.app.Formation(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+
+	return toFormationResp(formation), nil
+}
+
+func (s *Server) handleUpdateFormation() http.HandlerFunc {
+	type formationReq struct {
+		Process  string  `json:"process"`
+		Quantity *int    `json:"quantity"`
+		Size     *string `json:"size"`
+	}
+
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		var formationData []formationReq
+		if err := json.NewDecoder(req.Body).Decode(&formationData); err != nil {
+			log.Debug("Could not unmarshal body", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "invalid formation data")
+			return
+		}
+
+		updates := make([]aura.FormationUpdate, 0, len(formationData))
+		for _, f := range formationData {
+			updates = append(updates, aura.FormationUpdate{
+				Process:  f.Process,
+				Quantity: f.Quantity,
+				Size:     f.Size,
+			})
+		}
+
+		resp, err := s.updateFormation(req.Context(), appID, updates)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App or release not found")
+				render.JSONError(rw, http.StatusNotFound, "app or release not found")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid formation", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid formation: %v", err)
+			default:
+				log.Error("Could not update formation", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) updateFormation(ctx context.Context, appID string, updates []aura.FormationUpdate) ([]formationResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return nil, err
+	}
+
+	formation, err := s.app.UpdateFormation(ctx, aura.UpdateFormationConfig{
+		App:     app,
+		Updates: updates,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toFormationResp(formation), nil
+}
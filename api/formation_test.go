@@ -0,0 +1,157 @@
+package api_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleGetFormation(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		appErr         error
+		formation      []*aura.Formation
+		formationErr   error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name: "handles request",
+			formation: []*aura.Formation{
+				{Process: "web", Quantity: 2, Size: "medium"},
+				{Process: "worker", Quantity: 1},
+			},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `[{"process":"web","quantity":2,"size":"medium"},{"process":"worker","quantity":1,"size":""}]`,
+		},
+		{
+			name:           "handles app not found",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or release not found"}`,
+		},
+		{
+			name:           "handles no release",
+			formationErr:   aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or release not found"}`,
+		},
+		{
+			name:           "handles formation error",
+			formationErr:   errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
+			if test.formation != nil || test.formationErr != nil {
+				app.On("Formation", a).Return(test.formation, test.formationErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodGet, srvUrl+"/apps/123/formation", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleUpdateFormation(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+	two, size := 2, "medium"
+
+	tests := []struct {
+		name           string
+		body           []byte
+		appErr         error
+		formation      []*aura.Formation
+		formationErr   error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			body:           []byte(`[{"process":"web","quantity":2,"size":"medium"}]`),
+			formation:      []*aura.Formation{{Process: "web", Quantity: 2, Size: "medium"}},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `[{"process":"web","quantity":2,"size":"medium"}]`,
+		},
+		{
+			name:           "handles bad body",
+			body:           []byte(`{"process":"web"}`),
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid formation data"}`,
+		},
+		{
+			name:           "handles app not found",
+			body:           []byte(`[{"process":"web","quantity":2,"size":"medium"}]`),
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or release not found"}`,
+		},
+		{
+			name:           "handles validation error",
+			body:           []byte(`[{"process":"web","quantity":2,"size":"medium"}]`),
+			formationErr:   aura.ValidationError{},
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid formation: validation error"}`,
+		},
+		{
+			name:           "handles update error",
+			body:           []byte(`[{"process":"web","quantity":2,"size":"medium"}]`),
+			formationErr:   errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Maybe().Return(a, test.appErr)
+			if test.formation != nil || test.formationErr != nil {
+				app.On("UpdateFormation", aura.UpdateFormationConfig{
+					App:     a,
+					Updates: []aura.FormationUpdate{{Process: "web", Quantity: &two, Size: &size}},
+				}).Return(test.formation, test.formationErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodPatch, srvUrl+"/apps/123/formation", test.body)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/render"
+)
+
+const (
+	headerIdempotencyKey      = "Idempotency-Key"
+	headerIdempotencyReplayed = "Idempotency-Replayed"
+
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotent replays the recorded response of a request made with an
+// idempotency key that was already used for the method and path.
+//
+// Requests without an idempotency key are passed through.
+func (s *Server) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(headerIdempotencyKey)
+		if key == "" {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		log := s.log.With(lctx.Str("idempotency_key", key))
+
+		if len(key) > maxIdempotencyKeyLen {
+			render.JSONErrorf(rw, http.StatusBadRequest, "idempotency key must be at most %d characters", maxIdempotencyKeyLen)
+			return
+		}
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			log.Debug("Could not read body", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "could not read request body")
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		idemReq, started, err := s.app.StartIdempotentRequest(req.Context(), &aura.IdempotentRequest{
+			Key:         key,
+			Method:      req.Method,
+			Path:        req.URL.Path,
+			Fingerprint: fingerprint(req, body),
+		})
+		if err != nil {
+			log.Error("Could not start idempotent request", lctx.Error("error", err))
+			render.JSONInternalServerError(rw)
+			return
+		}
+		if !started {
+			switch {
+			case idemReq.Fingerprint != fingerprint(req, body):
+				render.JSONError(rw, http.StatusUnprocessableEntity, "idempotency key was used for a different request")
+			case !idemReq.Done():
+				render.JSONError(rw, http.StatusConflict, "a request with this idempotency key is in progress")
+			default:
+				replay(rw, idemReq)
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(rec, req)
+
+		// The request is finished, even if the context was cancelled.
+		ctx := context.Background()
+		if rec.status >= http.StatusInternalServerError {
+			// Server errors are not recorded, so the request can be retried.
+			if err = s.app.AbortIdempotentRequest(ctx, idemReq); err != nil {
+				log.Error("Could not abort idempotent request", lctx.Error("error", err))
+			}
+			return
+		}
+
+		idemReq.StatusCode = rec.status
+		idemReq.ContentType = rec.Header().Get("Content-Type")
+		idemReq.Location = rec.Header().Get("Location")
+		idemReq.Body = rec.body.Bytes()
+		if err = s.app.FinishIdempotentRequest(ctx, idemReq); err != nil {
+			log.Error("Could not finish idempotent request", lctx.Error("error", err))
+		}
+	})
+}
+
+func fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, req.Method+" "+req.URL.Path+"\n")
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(rw http.ResponseWriter, req *aura.IdempotentRequest) {
+	if req.ContentType != "" {
+		rw.Header().Set("Content-Type", req.ContentType)
+	}
+	if req.Location != "" {
+		rw.Header().Set("Location", req.Location)
+	}
+	rw.Header().Set(headerIdempotencyReplayed, "true")
+	rw.WriteHeader(req.StatusCode)
+	_, _ = rw.Write(req.Body)
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
@@ -0,0 +1,234 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Idempotency(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	const body = `{"name":"test-app"}`
+	fingerprint := sha256.Sum256([]byte("POST /apps/\n" + body))
+
+	tests := []struct {
+		name           string
+		key            string
+		startReq       *aura.IdempotentRequest
+		started        bool
+		startErr       error
+		createErr      error
+		wantCreate     bool
+		wantFinish     bool
+		wantAbort      bool
+		wantStatusCode int
+		wantReplayed   string
+		wantResp       string
+	}{
+		{
+			name:           "handles request without key",
+			wantCreate:     true,
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"123","name":"test-app","createdAt":"2022-02-01T04:00:00Z"}`,
+		},
+		{
+			name:           "handles first request",
+			key:            "test",
+			startReq:       &aura.IdempotentRequest{Key: "test", Method: http.MethodPost, Path: "/apps/"},
+			started:        true,
+			wantCreate:     true,
+			wantFinish:     true,
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"123","name":"test-app","createdAt":"2022-02-01T04:00:00Z"}`,
+		},
+		{
+			name:           "handles server error",
+			key:            "test",
+			startReq:       &aura.IdempotentRequest{Key: "test", Method: http.MethodPost, Path: "/apps/"},
+			started:        true,
+			createErr:      errors.New("test"),
+			wantCreate:     true,
+			wantAbort:      true,
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+		{
+			name: "replays finished request",
+			key:  "test",
+			startReq: &aura.IdempotentRequest{
+				Fingerprint: hex.EncodeToString(fingerprint[:]),
+				StatusCode:  http.StatusOK,
+				ContentType: "application/json",
+				Body:        []byte(`{"id":"123","name":"test-app","createdAt":"2022-02-01T04:00:00Z"}`),
+			},
+			wantStatusCode: http.StatusOK,
+			wantReplayed:   "true",
+			wantResp:       `{"id":"123","name":"test-app","createdAt":"2022-02-01T04:00:00Z"}`,
+		},
+		{
+			name:           "handles request in progress",
+			key:            "test",
+			startReq:       &aura.IdempotentRequest{Fingerprint: hex.EncodeToString(fingerprint[:])},
+			wantStatusCode: http.StatusConflict,
+			wantResp:       `{"error":"a request with this idempotency key is in progress"}`,
+		},
+		{
+			name:           "handles different request",
+			key:            "test",
+			startReq:       &aura.IdempotentRequest{Fingerprint: "other", StatusCode: http.StatusOK},
+			wantStatusCode: http.StatusUnprocessableEntity,
+			wantResp:       `{"error":"idempotency key was used for a different request"}`,
+		},
+		{
+			name:           "handles long key",
+			key:            strings.Repeat("a", 256),
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"idempotency key must be at most 255 characters"}`,
+		},
+		{
+			name:           "handles start error",
+			key:            "test",
+			startErr:       errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			app := &mockApp{}
+			if test.wantCreate {
+				a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+				if test.createErr != nil {
+					a = nil
+				}
+				app.On("Create", aura.CreateConfig{Name: "test-app"}).Return(a, test.createErr)
+			}
+			isReq := mock.MatchedBy(func(req *aura.IdempotentRequest) bool {
+				return req.Key == test.key && req.Method == http.MethodPost && req.Path == "/apps/"
+			})
+			if test.startReq != nil || test.startErr != nil {
+				app.On("StartIdempotentRequest", isReq).Return(test.startReq, test.started, test.startErr)
+			}
+			if test.wantFinish {
+				app.On("FinishIdempotentRequest", mock.MatchedBy(func(req *aura.IdempotentRequest) bool {
+					return req.StatusCode == http.StatusOK &&
+						req.ContentType == "application/json" &&
+						bytes.Equal(req.Body, []byte(test.wantResp))
+				})).Return(nil)
+			}
+			if test.wantAbort {
+				app.On("AbortIdempotentRequest", isReq).Return(nil)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srvUrl+"/apps/", strings.NewReader(body))
+			require.NoError(t, err)
+			if test.key != "" {
+				req.Header.Set("Idempotency-Key", test.key)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+			assert.Equal(t, test.wantReplayed, resp.Header.Get("Idempotency-Replayed"))
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_IdempotencyReplaysRetriedUpdates(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+	a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+	foo := "foo"
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		setup    func(app *mockApp)
+		wantResp string
+	}{
+		{
+			name: "config",
+			path: "/apps/123/config",
+			body: `{"FOO":"foo"}`,
+			setup: func(app *mockApp) {
+				app.On("SetVars", aura.SetVarsConfig{App: a, Vars: map[string]*string{"FOO": &foo}}).
+					Return(&aura.Config{ID: "abc", Version: 2, Vars: aura.Vars{"FOO": "foo"}, CreatedAt: &now}, nil).
+					Once()
+			},
+			wantResp: `{"version":2,"vars":{"FOO":"foo"},"createdAt":"2022-02-01T04:00:00Z"}`,
+		},
+		{
+			name: "app",
+			path: "/apps/123",
+			body: `{"description":"foo"}`,
+			setup: func(app *mockApp) {
+				app.On("Update", aura.UpdateConfig{App: a, Description: &foo}).
+					Return(&aura.App{ID: "123", Name: "test-app", Description: "foo", CreatedAt: &now}, nil).
+					Once()
+			},
+			wantResp: `{"id":"123","name":"test-app","description":"foo","createdAt":"2022-02-01T04:00:00Z"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, nil).Once()
+			test.setup(app)
+
+			// The first request is recorded when finished, the retry replays it.
+			fingerprint := sha256.Sum256([]byte("PATCH " + test.path + "\n" + test.body))
+			recorded := &aura.IdempotentRequest{}
+			app.On("StartIdempotentRequest", mock.Anything).
+				Return(&aura.IdempotentRequest{Key: "test", Fingerprint: hex.EncodeToString(fingerprint[:])}, true, nil).
+				Once()
+			app.On("FinishIdempotentRequest", mock.Anything).Run(func(args mock.Arguments) {
+				*recorded = *args.Get(0).(*aura.IdempotentRequest)
+			}).Return(nil).Once()
+			app.On("StartIdempotentRequest", mock.Anything).Return(recorded, false, nil).Once()
+
+			srvUrl := setupTestServer(t, app)
+
+			for i := 0; i < 2; i++ {
+				req, err := http.NewRequestWithContext(context.Background(), http.MethodPatch, srvUrl+test.path, strings.NewReader(test.body))
+				require.NoError(t, err)
+				req.Header.Set("Idempotency-Key", "test")
+
+				resp, err := http.DefaultClient.Do(req)
+				require.NoError(t, err)
+				got, err := ioutil.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+				require.NoError(t, err)
+
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, test.wantResp, string(got))
+			}
+			app.AssertExpectations(t)
+		})
+	}
+}
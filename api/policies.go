@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/render"
+)
+
+type imagePolicyResp struct {
+	RequireDigest bool     `json:"requireDigest"`
+	ForbidLatest  bool     `json:"forbidLatest"`
+	Allow         []string `json:"allow"`
+	Deny          []string `json:"deny"`
+}
+
+func toImagePolicyResp(p aura.ImagePolicy) imagePolicyResp {
+	resp := imagePolicyResp{
+		RequireDigest: p.RequireDigest,
+		ForbidLatest:  p.ForbidLatest,
+		Allow:         p.Allow,
+		Deny:          p.Deny,
+	}
+	if resp.Allow == nil {
+		resp.Allow = []string{}
+	}
+	if resp.Deny == nil {
+		resp.Deny = []string{}
+	}
+	return resp
+}
+
+func (s *Server) handleGetPolicy() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		app, err := s.app.App(req.Context(), aura.AppsQuery{IDOrName: appID})
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			default:
+				log.Error("Could not get app", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, toImagePolicyResp(app.ImagePolicy)); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+type imagePolicyReq struct {
+	RequireDigest bool     `json:"requireDigest"`
+	ForbidLatest  bool     `json:"forbidLatest"`
+	Allow         []string `json:"allow"`
+	Deny          []string `json:"deny"`
+}
+
+func (s *Server) handleSetPolicy() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		var policyReq imagePolicyReq
+		if err := json.NewDecoder(req.Body).Decode(&policyReq); err != nil {
+			log.Debug("Could not unmarshal body", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "invalid image policy data")
+			return
+		}
+
+		resp, err := s.setPolicy(req.Context(), appID, policyReq)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid image policy", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid image policy: %v", err)
+			default:
+				log.Error("Could not set image policy", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) setPolicy(ctx context.Context, appID string, policyReq imagePolicyReq) (imagePolicyResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return imagePolicyResp{}, err
+	}
+
+	app, err = s.app.SetImagePolicy(ctx, aura.SetImagePolicyConfig{
+		App: app,
+		Policy: aura.ImagePolicy{
+			RequireDigest: policyReq.RequireDigest,
+			ForbidLatest:  policyReq.ForbidLatest,
+			Allow:         policyReq.Allow,
+			Deny:          policyReq.Deny,
+		},
+	})
+	if err != nil {
+		return imagePolicyResp{}, err
+	}
+	return toImagePolicyResp(app.ImagePolicy), nil
+}
@@ -0,0 +1,154 @@
+package api_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleGetPolicy(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		app            *aura.App
+		appErr         error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name: "handles request",
+			app: &aura.App{ID: "123", Name: "test-app", CreatedAt: &now, ImagePolicy: aura.ImagePolicy{
+				RequireDigest: true,
+				Allow:         []string{"ghcr.io/foo/*"},
+			}},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"requireDigest":true,"forbidLatest":false,"allow":["ghcr.io/foo/*"],"deny":[]}`,
+		},
+		{
+			name:           "handles no policy",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"requireDigest":false,"forbidLatest":false,"allow":[],"deny":[]}`,
+		},
+		{
+			name:           "handles app not found",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles app error",
+			appErr:         errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(test.app, test.appErr)
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodGet, srvUrl+"/apps/123/policy", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleSetPolicy(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		body           []byte
+		appErr         error
+		setErr         error
+		wantSet        bool
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			body:           []byte(`{"forbidLatest":true,"deny":["*.example.com"]}`),
+			wantSet:        true,
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"requireDigest":false,"forbidLatest":true,"allow":[],"deny":["*.example.com"]}`,
+		},
+		{
+			name:           "handles bad body",
+			body:           []byte(`{`),
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid image policy data"}`,
+		},
+		{
+			name:           "handles app not found",
+			body:           []byte(`{"forbidLatest":true,"deny":["*.example.com"]}`),
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles validation error",
+			body:           []byte(`{"forbidLatest":true,"deny":["*.example.com"]}`),
+			setErr:         aura.ValidationError{},
+			wantSet:        true,
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid image policy: validation error"}`,
+		},
+		{
+			name:           "handles set error",
+			body:           []byte(`{"forbidLatest":true,"deny":["*.example.com"]}`),
+			setErr:         errors.New("test"),
+			wantSet:        true,
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+			policy := aura.ImagePolicy{ForbidLatest: true, Deny: []string{"*.example.com"}}
+
+			app := &mockApp{}
+			if test.wantSet || test.appErr != nil {
+				app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
+			}
+			if test.wantSet {
+				updated := *a
+				updated.ImagePolicy = policy
+				app.On("SetImagePolicy", aura.SetImagePolicyConfig{App: a, Policy: policy}).Return(&updated, test.setErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodPut, srvUrl+"/apps/123/policy", test.body)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
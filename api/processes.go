@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/render"
+)
+
+type processResp struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	Command   string     `json:"command"`
+	Version   int        `json:"version"`
+	State     string     `json:"state"`
+	CreatedAt *time.Time `json:"createdAt"`
+}
+
+func toProcessResp(proc *aura.Process) processResp {
+	return processResp{
+		ID:        proc.ID,
+		Type:      proc.Type,
+		Command:   proc.Command,
+		Version:   proc.Version,
+		State:     proc.State,
+		CreatedAt: proc.CreatedAt,
+	}
+}
+
+func (s *Server) handleGetProcesses() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		app, err := s.app.App(req.Context(), aura.AppsQuery{IDOrName: appID})
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			default:
+				log.Error("Could not get app", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		procs, err := s.app.Processes(req.Context(), app)
+		if err != nil {
+			log.Error("Could not get processes", lctx.Error("error", err))
+			render.JSONInternalServerError(rw)
+			return
+		}
+
+		resp := make([]processResp, 0, len(procs))
+		for _, proc := range procs {
+			resp = append(resp, toProcessResp(proc))
+		}
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package api_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleGetProcesses(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		appErr         error
+		procs          []*aura.Process
+		procsErr       error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			procs:          []*aura.Process{{ID: "abc", Type: "web", Command: "./app", Version: 2, State: "running", CreatedAt: &now}},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `[{"id":"abc","type":"web","command":"./app","version":2,"state":"running","createdAt":"2022-02-01T04:00:00Z"}]`,
+		},
+		{
+			name:           "handles app not found",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles app find error",
+			appErr:         errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+		{
+			name:           "handles processes error",
+			procsErr:       errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
+			if test.procs != nil || test.procsErr != nil {
+				app.On("Processes", a).Return(test.procs, test.procsErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodGet, srvUrl+"/apps/123/processes", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/render"
+)
+
+type registryCredentialResp struct {
+	Registry  string     `json:"registry"`
+	Username  string     `json:"username"`
+	UpdatedAt *time.Time `json:"updatedAt"`
+}
+
+func toRegistryCredentialResp(cred *aura.RegistryCredential) registryCredentialResp {
+	return registryCredentialResp{
+		Registry:  cred.Registry,
+		Username:  cred.Username,
+		UpdatedAt: cred.UpdatedAt,
+	}
+}
+
+func (s *Server) handleGetRegistryCredentials() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		resp, err := s.getRegistryCredentials(req.Context(), appID)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			default:
+				log.Error("Could not get registry credentials", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) getRegistryCredentials(ctx context.Context, appID string) ([]registryCredentialResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := s.app.RegistryCredentials(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]registryCredentialResp, 0, len(creds))
+	for _, cred := range creds {
+		resp = append(resp, toRegistryCredentialResp(cred))
+	}
+	return resp, nil
+}
+
+type registryCredentialReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (s *Server) handleSetRegistryCredential() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+		registry := chi.URLParam(req, "registry")
+
+		log := s.log.With(lctx.Str("app_id", appID), lctx.Str("registry", registry))
+
+		var credReq registryCredentialReq
+		if err := json.NewDecoder(req.Body).Decode(&credReq); err != nil {
+			log.Debug("Could not unmarshal body", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "invalid registry credential data")
+			return
+		}
+
+		if err := s.setRegistryCredential(req.Context(), appID, registry, credReq); err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			case errors.Is(err, aura.ErrNoKeyring):
+				log.Debug("Secrets are not enabled")
+				render.JSONError(rw, http.StatusNotImplemented, "secrets are not enabled")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid registry credential", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid registry credential: %v", err)
+			default:
+				log.Error("Could not set registry credential", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) setRegistryCredential(ctx context.Context, appID, registry string, credReq registryCredentialReq) error {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.app.SetRegistryCredential(ctx, aura.SetRegistryCredentialConfig{
+		App:      app,
+		Registry: registry,
+		Username: credReq.Username,
+		Password: credReq.Password,
+	})
+	return err
+}
+
+func (s *Server) handleDeleteRegistryCredential() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+		registry := chi.URLParam(req, "registry")
+
+		log := s.log.With(lctx.Str("app_id", appID), lctx.Str("registry", registry))
+
+		if err := s.deleteRegistryCredential(req.Context(), appID, registry); err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App or registry credential not found")
+				render.JSONError(rw, http.StatusNotFound, "app or registry credential not found")
+			default:
+				log.Error("Could not delete registry credential", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) deleteRegistryCredential(ctx context.Context, appID, registry string) error {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return err
+	}
+
+	return s.app.DeleteRegistryCredential(ctx, aura.DeleteRegistryCredentialConfig{
+		App:      app,
+		Registry: registry,
+	})
+}
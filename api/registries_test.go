@@ -0,0 +1,230 @@
+package api_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleGetRegistryCredentials(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		appErr         error
+		creds          []*aura.RegistryCredential
+		credsErr       error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name: "handles request",
+			creds: []*aura.RegistryCredential{
+				{ID: "abc", Registry: "ghcr.io", Username: "bob", KeyID: "key1", Password: []byte("encrypted"), UpdatedAt: &now},
+			},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `[{"registry":"ghcr.io","username":"bob","updatedAt":"2022-02-01T04:00:00Z"}]`,
+		},
+		{
+			name:           "handles no credentials",
+			creds:          []*aura.RegistryCredential{},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `[]`,
+		},
+		{
+			name:           "handles app not found",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles credentials error",
+			credsErr:       errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
+			if test.creds != nil || test.credsErr != nil {
+				app.On("RegistryCredentials", a).Return(test.creds, test.credsErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodGet, srvUrl+"/apps/123/registry-credentials", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleSetRegistryCredential(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		body           []byte
+		appErr         error
+		setErr         error
+		wantSet        bool
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			body:           []byte(`{"username":"bob","password":"hunter2"}`),
+			wantSet:        true,
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "handles bad body",
+			body:           []byte(`{`),
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid registry credential data"}`,
+		},
+		{
+			name:           "handles app not found",
+			body:           []byte(`{"username":"bob","password":"hunter2"}`),
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles no keyring",
+			body:           []byte(`{"username":"bob","password":"hunter2"}`),
+			setErr:         aura.ErrNoKeyring,
+			wantSet:        true,
+			wantStatusCode: http.StatusNotImplemented,
+			wantResp:       `{"error":"secrets are not enabled"}`,
+		},
+		{
+			name:           "handles validation error",
+			body:           []byte(`{"username":"bob","password":"hunter2"}`),
+			setErr:         aura.ValidationError{},
+			wantSet:        true,
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid registry credential: validation error"}`,
+		},
+		{
+			name:           "handles set error",
+			body:           []byte(`{"username":"bob","password":"hunter2"}`),
+			setErr:         errors.New("test"),
+			wantSet:        true,
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			if test.wantSet || test.appErr != nil {
+				app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
+			}
+			if test.wantSet {
+				app.On("SetRegistryCredential", aura.SetRegistryCredentialConfig{
+					App:      a,
+					Registry: "ghcr.io",
+					Username: "bob",
+					Password: "hunter2",
+				}).Return(&aura.RegistryCredential{ID: "abc", Registry: "ghcr.io"}, test.setErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodPut, srvUrl+"/apps/123/registry-credentials/ghcr.io", test.body)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleDeleteRegistryCredential(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		app            *aura.App
+		appErr         error
+		deleteErr      error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "handles app not found",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or registry credential not found"}`,
+		},
+		{
+			name:           "handles credential not found",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			deleteErr:      aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or registry credential not found"}`,
+		},
+		{
+			name:           "handles delete error",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			deleteErr:      errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(test.app, test.appErr)
+			if test.app != nil {
+				app.On("DeleteRegistryCredential", aura.DeleteRegistryCredentialConfig{App: test.app, Registry: "ghcr.io"}).Return(test.deleteErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodDelete, srvUrl+"/apps/123/registry-credentials/ghcr.io", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,17 +12,35 @@ import (
 	"github.com/go-chi/chi/v5"
 	lctx "github.com/hamba/logger/v2/ctx"
 	"github.com/nrwiersma/aura"
-	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/nrwiersma/aura/pkg/procfile"
 	"github.com/nrwiersma/aura/pkg/render"
 )
 
+type releaseProcessResp struct {
+	Name        string  `json:"name"`
+	Command     string  `json:"command"`
+	Port        int     `json:"port,omitempty"`
+	HealthCheck string  `json:"healthCheck,omitempty"`
+	Instances   int     `json:"instances,omitempty"`
+	Memory      int64   `json:"memory,omitempty"`
+	CPU         float64 `json:"cpu,omitempty"`
+	Schedule    string  `json:"schedule,omitempty"`
+}
+
 type releaseResp struct {
-	ID        string     `json:"id"`
-	App       appResp    `json:"app,omitempty"`
-	Image     string     `json:"image"`
-	Version   int        `json:"version"`
-	Procfile  string     `json:"procfile"`
-	CreatedAt *time.Time `json:"createdAt"`
+	ID              string               `json:"id"`
+	App             appResp              `json:"app,omitempty"`
+	Image           string               `json:"image"`
+	IndexDigest     string               `json:"indexDigest,omitempty"`
+	Platform        string               `json:"platform,omitempty"`
+	Version         int                  `json:"version"`
+	Procfile        string               `json:"procfile"`
+	Processes       []releaseProcessResp `json:"processes"`
+	Reason          string               `json:"reason"`
+	RollbackVersion *int                 `json:"rollbackVersion,omitempty"`
+	Status          string               `json:"status,omitempty"`
+	Output          string               `json:"output,omitempty"`
+	CreatedAt       *time.Time           `json:"createdAt"`
 }
 
 func toReleaseResp(release *aura.Release) releaseResp {
@@ -29,7 +48,14 @@ func toReleaseResp(release *aura.Release) releaseResp {
 		ID:        release.ID,
 		Version:   release.Version,
 		Procfile:  string(release.Procfile),
+		Processes: []releaseProcessResp{},
+		Reason:    release.Reason,
 		CreatedAt: release.CreatedAt,
+
+		IndexDigest:     release.IndexDigest,
+		RollbackVersion: release.RollbackVersion,
+		Status:          release.Status,
+		Output:          string(release.Output),
 	}
 	if release.App != nil {
 		resp.App = toAppResp(release.App)
@@ -37,6 +63,25 @@ func toReleaseResp(release *aura.Release) releaseResp {
 	if release.Image != nil {
 		resp.Image = release.Image.String()
 	}
+	if release.Platform != nil {
+		resp.Platform = release.Platform.String()
+	}
+	// Releases are only created with a valid procfile, but older
+	// releases may not have been validated. Those have no processes.
+	if procs, err := procfile.Parse(release.Procfile); err == nil {
+		for _, proc := range procs {
+			resp.Processes = append(resp.Processes, releaseProcessResp{
+				Name:        proc.Name,
+				Command:     proc.Command,
+				Port:        proc.Port,
+				HealthCheck: proc.HealthCheck,
+				Instances:   proc.Instances,
+				Memory:      proc.Memory,
+				CPU:         proc.CPU,
+				Schedule:    proc.Schedule,
+			})
+		}
+	}
 	return resp
 }
 
@@ -46,7 +91,7 @@ func (s *Server) handleGetReleases() http.HandlerFunc {
 
 		log := s.log.With(lctx.Str("app_id", appID))
 
-		app, err := s.app.App(req.Context(), aura.AppsQuery{ID: appID})
+		app, err := s.app.App(req.Context(), aura.AppsQuery{IDOrName: appID})
 		if err != nil {
 			switch {
 			case errors.Is(err, aura.ErrNotFound):
@@ -91,7 +136,7 @@ func (s *Server) handleGetRelease() http.HandlerFunc {
 
 		log := s.log.With(lctx.Str("app_id", appID), lctx.Int("version", ver))
 
-		app, err := s.app.App(req.Context(), aura.AppsQuery{ID: appID})
+		app, err := s.app.App(req.Context(), aura.AppsQuery{IDOrName: appID})
 		if err != nil {
 			switch {
 			case errors.Is(err, aura.ErrNotFound):
@@ -125,9 +170,9 @@ func (s *Server) handleGetRelease() http.HandlerFunc {
 	}
 }
 
-func (s *Server) handlerDeployApp() http.HandlerFunc {
-	type deployAppReq struct {
-		Image string `json:"image"`
+func (s *Server) handleRedeployApp() http.HandlerFunc {
+	type redeployAppReq struct {
+		Reason string `json:"reason"`
 	}
 
 	return func(rw http.ResponseWriter, req *http.Request) {
@@ -135,49 +180,99 @@ func (s *Server) handlerDeployApp() http.HandlerFunc {
 
 		log := s.log.With(lctx.Str("app_id", appID))
 
-		var appReq deployAppReq
-		if err := json.NewDecoder(req.Body).Decode(&appReq); err != nil {
+		var appReq redeployAppReq
+		if err := json.NewDecoder(req.Body).Decode(&appReq); err != nil && !errors.Is(err, io.EOF) {
 			log.Debug("Could not unmarshal body", lctx.Error("error", err))
-			render.JSONError(rw, http.StatusBadRequest, "invalid app deployment data")
+			render.JSONError(rw, http.StatusBadRequest, "invalid app redeploy data")
+			return
+		}
+
+		resp, err := s.redeployApp(req.Context(), appID, appReq.Reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App or release not found")
+				render.JSONError(rw, http.StatusNotFound, "app or release not found")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid redeploy", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid app redeploy: %v", err)
+			default:
+				log.Error("Could not redeploy app", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
 			return
 		}
+	}
+}
+
+func (s *Server) redeployApp(ctx context.Context, appID, reason string) (releaseResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return releaseResp{}, err
+	}
+
+	release, err := s.app.Redeploy(ctx, aura.RedeployConfig{
+		App:    app,
+		Reason: reason,
+	})
+	if err != nil {
+		return releaseResp{}, err
+	}
+
+	return toReleaseResp(release), nil
+}
+
+func (s *Server) handleRollbackApp() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
 
-		img, err := image.Decode(appReq.Image)
+		verStr := chi.URLParam(req, "version")
+		ver, err := strconv.Atoi(verStr)
 		if err != nil {
-			s.log.Debug("Invalid deployment", lctx.Error("error", err))
-			render.JSONErrorf(rw, http.StatusBadRequest, "invalid app deploy: %v", err)
+			s.log.Debug("Could not convert version to int", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "version must be a positive integer")
 			return
 		}
 
-		resp, err := s.deployApp(req.Context(), appID, img)
+		log := s.log.With(lctx.Str("app_id", appID), lctx.Int("version", ver))
+
+		resp, err := s.rollbackApp(req.Context(), appID, ver)
 		if err != nil {
 			switch {
 			case errors.Is(err, aura.ErrNotFound):
-				log.Debug("App not found")
-				render.JSONError(rw, http.StatusNotFound, "app not found")
+				log.Debug("App or release not found")
+				render.JSONError(rw, http.StatusNotFound, "app or release not found")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid rollback", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid app rollback: %v", err)
 			default:
-				s.log.Error("Could not deploy app", lctx.Error("error", err))
+				log.Error("Could not rollback app", lctx.Error("error", err))
 				render.JSONInternalServerError(rw)
 			}
 			return
 		}
 
 		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
-			s.log.Error("Could not write response", lctx.Error("error", err))
+			log.Error("Could not write response", lctx.Error("error", err))
 			return
 		}
 	}
 }
 
-func (s *Server) deployApp(ctx context.Context, appID string, img image.Image) (releaseResp, error) {
-	app, err := s.app.App(ctx, aura.AppsQuery{ID: appID})
+func (s *Server) rollbackApp(ctx context.Context, appID string, ver int) (releaseResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
 	if err != nil {
 		return releaseResp{}, err
 	}
 
-	release, err := s.app.Deploy(ctx, aura.DeployConfig{
-		App:   app,
-		Image: img,
+	release, err := s.app.Rollback(ctx, aura.RollbackConfig{
+		App:     app,
+		Version: ver,
 	})
 	if err != nil {
 		return releaseResp{}, err
@@ -28,7 +28,13 @@ func TestServer_HandleGetReleases(t *testing.T) {
 			name:           "handles request",
 			releases:       []*aura.Release{{ID: "test", AppID: "123", Version: 2}},
 			wantStatusCode: http.StatusOK,
-			wantResp:       `[{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"","version":2,"procfile":"","createdAt":null}]`,
+			wantResp:       `[{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"","version":2,"procfile":"","processes":[],"reason":"","createdAt":null}]`,
+		},
+		{
+			name:           "handles request with procfile",
+			releases:       []*aura.Release{{ID: "test", AppID: "123", Version: 2, Procfile: []byte("web: ./app\nworker: ./worker")}},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `[{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"","version":2,"procfile":"web: ./app\nworker: ./worker","processes":[{"name":"web","command":"./app"},{"name":"worker","command":"./worker"}],"reason":"","createdAt":null}]`,
 		},
 		{
 			name:           "handles app not found",
@@ -53,10 +59,10 @@ func TestServer_HandleGetReleases(t *testing.T) {
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
-			a := &aura.App{ID: "123", Name: "test app", CreatedAt: &now}
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
 
 			app := &mockApp{}
-			app.On("App", aura.AppsQuery{ID: "123"}).Return(a, test.appErr)
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
 			if test.releases != nil || test.releasesErr != nil {
 				app.On("Releases", aura.ReleasesQuery{App: a}).Return(test.releases, test.releasesErr)
 			}
@@ -91,7 +97,26 @@ func TestServer_HandleGetRelease(t *testing.T) {
 			name:           "handles request",
 			release:        &aura.Release{ID: "test", AppID: "123", Version: 2},
 			wantStatusCode: http.StatusOK,
-			wantResp:       `{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"","version":2,"procfile":"","createdAt":null}`,
+			wantResp:       `{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"","version":2,"procfile":"","processes":[],"reason":"","createdAt":null}`,
+		},
+		{
+			name: "handles request with platform",
+			release: &aura.Release{
+				ID:          "test",
+				AppID:       "123",
+				Image:       &image.Image{Repository: "foo/bar", Digest: "sha256:c3ab8"},
+				IndexDigest: "sha256:a1b2c",
+				Platform:    &image.Platform{OS: "linux", Architecture: "arm64"},
+				Version:     2,
+			},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"foo/bar@sha256:c3ab8","indexDigest":"sha256:a1b2c","platform":"linux/arm64","version":2,"procfile":"","processes":[],"reason":"","createdAt":null}`,
+		},
+		{
+			name:           "handles request with extended procfile",
+			release:        &aura.Release{ID: "test", AppID: "123", Version: 2, Procfile: []byte("web:\n  command: ./app\n  port: 8080\n  memory: 1k")},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"","version":2,"procfile":"web:\n  command: ./app\n  port: 8080\n  memory: 1k","processes":[{"name":"web","command":"./app","port":8080,"memory":1024}],"reason":"","createdAt":null}`,
 		},
 		{
 			name:           "handles app not found",
@@ -122,10 +147,10 @@ func TestServer_HandleGetRelease(t *testing.T) {
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
-			a := &aura.App{ID: "123", Name: "test app", CreatedAt: &now}
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
 
 			app := &mockApp{}
-			app.On("App", aura.AppsQuery{ID: "123"}).Return(a, test.appErr)
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
 			if test.release != nil || test.releaseErr != nil {
 				app.On("Release", aura.ReleasesQuery{App: a, Version: 2}).Return(test.release, test.releaseErr)
 			}
@@ -145,58 +170,74 @@ func TestServer_HandleGetRelease(t *testing.T) {
 	}
 }
 
-func TestServer_HandleDeployApp(t *testing.T) {
+func TestServer_HandleRedeployApp(t *testing.T) {
 	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
 
 	tests := []struct {
 		name           string
 		req            string
 		appErr         error
+		wantReason     string
 		release        *aura.Release
 		releaseErr     error
-		wantImage      string
 		wantStatusCode int
 		wantResp       string
 	}{
 		{
 			name:           "handles request",
-			req:            `{"image":"foo/bar:latest"}`,
-			release:        &aura.Release{ID: "test", AppID: "123", Version: 2},
-			wantImage:      "foo/bar:latest",
+			req:            `{"reason":"restart"}`,
+			wantReason:     "restart",
+			release:        &aura.Release{ID: "test", AppID: "123", Version: 3, Reason: "restart"},
 			wantStatusCode: http.StatusOK,
-			wantResp:       `{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"","version":2,"procfile":"","createdAt":null}`,
+			wantResp:       `{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"","version":3,"procfile":"","processes":[],"reason":"restart","createdAt":null}`,
 		},
 		{
-			name:           "handles invalid json",
-			req:            `{"image":"foo/bar:latest}`,
-			wantStatusCode: http.StatusBadRequest,
-			wantResp:       `{"error":"invalid app deployment data"}`,
+			name:           "handles request without body",
+			release:        &aura.Release{ID: "test", AppID: "123", Version: 3, Reason: "redeploy"},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"","version":3,"procfile":"","processes":[],"reason":"redeploy","createdAt":null}`,
 		},
 		{
-			name:           "handles empty image",
-			req:            `{"image":""}`,
+			name:           "handles invalid json",
+			req:            `{"reason":"restart}`,
 			wantStatusCode: http.StatusBadRequest,
-			wantResp:       `{"error":"invalid app deploy: invalid image format"}`,
+			wantResp:       `{"error":"invalid app redeploy data"}`,
 		},
 		{
 			name:           "handles app not found",
-			req:            `{"image":"foo/bar:latest"}`,
+			req:            `{"reason":"restart"}`,
 			appErr:         aura.ErrNotFound,
 			wantStatusCode: http.StatusNotFound,
-			wantResp:       `{"error":"app not found"}`,
+			wantResp:       `{"error":"app or release not found"}`,
 		},
 		{
 			name:           "handles app find error",
-			req:            `{"image":"foo/bar:latest"}`,
+			req:            `{"reason":"restart"}`,
 			appErr:         errors.New("test"),
 			wantStatusCode: http.StatusInternalServerError,
 			wantResp:       `{"error":"internal server error"}`,
 		},
 		{
-			name:           "handles release error",
-			req:            `{"image":"foo/bar:latest"}`,
+			name:           "handles no current release",
+			req:            `{"reason":"restart"}`,
+			wantReason:     "restart",
+			releaseErr:     aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or release not found"}`,
+		},
+		{
+			name:           "handles validation error",
+			req:            `{"reason":"restart"}`,
+			wantReason:     "restart",
+			releaseErr:     aura.ValidationError{},
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid app redeploy: validation error"}`,
+		},
+		{
+			name:           "handles redeploy error",
+			req:            `{"reason":"restart"}`,
+			wantReason:     "restart",
 			releaseErr:     errors.New("test"),
-			wantImage:      "foo/bar:latest",
 			wantStatusCode: http.StatusInternalServerError,
 			wantResp:       `{"error":"internal server error"}`,
 		},
@@ -205,21 +246,103 @@ func TestServer_HandleDeployApp(t *testing.T) {
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
-			a := &aura.App{ID: "123", Name: "test app", CreatedAt: &now}
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
 
 			app := &mockApp{}
-			app.On("App", aura.AppsQuery{ID: "123"}).Maybe().Return(a, test.appErr)
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Maybe().Return(a, test.appErr)
+			if test.release != nil || test.releaseErr != nil {
+				app.On("Redeploy", aura.RedeployConfig{App: a, Reason: test.wantReason}).Return(test.release, test.releaseErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			var body []byte
+			if test.req != "" {
+				body = []byte(test.req)
+			}
+			resp := requireDoRequest(t, http.MethodPost, srvUrl+"/apps/123/releases/current/redeploy", body)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
 
-			if test.wantImage != "" {
-				img, err := image.Decode(test.wantImage)
-				require.NoError(t, err)
+func TestServer_HandleRollbackApp(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+	ver := 2
 
-				app.On("Deploy", aura.DeployConfig{App: a, Image: img}).Return(test.release, test.releaseErr)
+	tests := []struct {
+		name           string
+		version        string
+		appErr         error
+		release        *aura.Release
+		releaseErr     error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			version:        "2",
+			release:        &aura.Release{ID: "test", AppID: "123", Version: 4, Reason: "rollback", RollbackVersion: &ver},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"test","app":{"id":"","name":"","createdAt":null},"image":"","version":4,"procfile":"","processes":[],"reason":"rollback","rollbackVersion":2,"createdAt":null}`,
+		},
+		{
+			name:           "handles invalid version",
+			version:        "foo",
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"version must be a positive integer"}`,
+		},
+		{
+			name:           "handles app not found",
+			version:        "2",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or release not found"}`,
+		},
+		{
+			name:           "handles release not found",
+			version:        "2",
+			releaseErr:     aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or release not found"}`,
+		},
+		{
+			name:           "handles validation error",
+			version:        "2",
+			releaseErr:     aura.ValidationError{},
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid app rollback: validation error"}`,
+		},
+		{
+			name:           "handles rollback error",
+			version:        "2",
+			releaseErr:     errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Maybe().Return(a, test.appErr)
+			if test.release != nil || test.releaseErr != nil {
+				app.On("Rollback", aura.RollbackConfig{App: a, Version: 2}).Return(test.release, test.releaseErr)
 			}
 
 			srvUrl := setupTestServer(t, app)
 
-			resp := requireDoRequest(t, http.MethodPost, srvUrl+"/apps/123/deploys", []byte(test.req))
+			resp := requireDoRequest(t, http.MethodPost, srvUrl+"/apps/123/releases/"+test.version+"/rollback", nil)
 			t.Cleanup(func() { _ = resp.Body.Close() })
 
 			require.Equal(t, test.wantStatusCode, resp.StatusCode)
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/render"
+)
+
+type runResp struct {
+	ID       string `json:"id"`
+	Command  string `json:"command"`
+	Attach   bool   `json:"attach"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+	Output   string `json:"output,omitempty"`
+}
+
+func toRunResp(run *aura.Run) runResp {
+	return runResp{
+		ID:       run.ID,
+		Command:  run.Command,
+		Attach:   run.Attach,
+		ExitCode: run.ExitCode,
+		Output:   string(run.Output),
+	}
+}
+
+func (s *Server) handleRunApp() http.HandlerFunc {
+	type runReq struct {
+		Command string `json:"command"`
+		Attach  bool   `json:"attach"`
+	}
+
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		var runData runReq
+		if err := json.NewDecoder(req.Body).Decode(&runData); err != nil {
+			log.Debug("Could not unmarshal body", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "invalid run data")
+			return
+		}
+
+		resp, err := s.runApp(req.Context(), appID, runData.Command, runData.Attach)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App or release not found")
+				render.JSONError(rw, http.StatusNotFound, "app or release not found")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid run", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid run: %v", err)
+			default:
+				log.Error("Could not run process", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		// Detached runs are still running.
+		status := http.StatusAccepted
+		if resp.Attach {
+			status = http.StatusOK
+		}
+		if err = render.JSON(rw, status, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) runApp(ctx context.Context, appID, cmd string, attach bool) (runResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return runResp{}, err
+	}
+
+	run, err := s.app.Run(ctx, aura.RunConfig{
+		App:     app,
+		Command: cmd,
+		Attach:  attach,
+	})
+	if err != nil {
+		return runResp{}, err
+	}
+
+	return toRunResp(run), nil
+}
@@ -0,0 +1,102 @@
+package api_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleRunApp(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+	exitCode := 1
+
+	tests := []struct {
+		name           string
+		body           []byte
+		wantCfg        *aura.RunConfig
+		appErr         error
+		run            *aura.Run
+		runErr         error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles attached run",
+			body:           []byte(`{"command":"rake db:migrate","attach":true}`),
+			wantCfg:        &aura.RunConfig{Command: "rake db:migrate", Attach: true},
+			run:            &aura.Run{ID: "abc", Command: "rake db:migrate", Attach: true, ExitCode: &exitCode, Output: []byte("failed\n")},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `{"id":"abc","command":"rake db:migrate","attach":true,"exitCode":1,"output":"failed\n"}`,
+		},
+		{
+			name:           "handles detached run",
+			body:           []byte(`{"command":"./job"}`),
+			wantCfg:        &aura.RunConfig{Command: "./job"},
+			run:            &aura.Run{ID: "abc", Command: "./job"},
+			wantStatusCode: http.StatusAccepted,
+			wantResp:       `{"id":"abc","command":"./job","attach":false}`,
+		},
+		{
+			name:           "handles bad body",
+			body:           []byte(`{"command":`),
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid run data"}`,
+		},
+		{
+			name:           "handles app not found",
+			body:           []byte(`{"command":"./job"}`),
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or release not found"}`,
+		},
+		{
+			name:           "handles validation error",
+			body:           []byte(`{"command":"./job"}`),
+			wantCfg:        &aura.RunConfig{Command: "./job"},
+			runErr:         aura.ValidationError{},
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid run: validation error"}`,
+		},
+		{
+			name:           "handles run error",
+			body:           []byte(`{"command":"./job"}`),
+			wantCfg:        &aura.RunConfig{Command: "./job"},
+			runErr:         errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Maybe().Return(a, test.appErr)
+			if test.wantCfg != nil {
+				cfg := *test.wantCfg
+				cfg.App = a
+				app.On("Run", cfg).Return(test.run, test.runErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodPost, srvUrl+"/apps/123/runs", test.body)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/render"
+)
+
+type secretResp struct {
+	Name string `json:"name"`
+}
+
+func toSecretResp(secret *aura.Secret) secretResp {
+	return secretResp{
+		Name: secret.Name,
+	}
+}
+
+func (s *Server) handleGetSecrets() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+
+		log := s.log.With(lctx.Str("app_id", appID))
+
+		resp, err := s.getSecrets(req.Context(), appID)
+		if err != nil {
+			switch {
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			default:
+				log.Error("Could not get secrets", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		if err = render.JSON(rw, http.StatusOK, resp); err != nil {
+			log.Error("Could not write response", lctx.Error("error", err))
+			return
+		}
+	}
+}
+
+func (s *Server) getSecrets(ctx context.Context, appID string) ([]secretResp, error) {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := s.app.Secrets(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]secretResp, 0, len(secrets))
+	for _, secret := range secrets {
+		resp = append(resp, toSecretResp(secret))
+	}
+	return resp, nil
+}
+
+func (s *Server) handleSetSecret() http.HandlerFunc {
+	type secretReq struct {
+		Value string `json:"value"`
+	}
+
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+		name := chi.URLParam(req, "name")
+
+		log := s.log.With(lctx.Str("app_id", appID), lctx.Str("secret", name))
+
+		var secretData secretReq
+		if err := json.NewDecoder(req.Body).Decode(&secretData); err != nil {
+			log.Debug("Could not unmarshal body", lctx.Error("error", err))
+			render.JSONError(rw, http.StatusBadRequest, "invalid secret data")
+			return
+		}
+
+		if err := s.setSecret(req.Context(), appID, name, secretData.Value); err != nil {
+			switch {
+			case errors.As(err, &aura.RedeployError{}):
+				log.Error("Could not redeploy secret", lctx.Error("error", err))
+				render.JSONError(rw, http.StatusInternalServerError, "the secret was saved, but could not be redeployed")
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App not found")
+				render.JSONError(rw, http.StatusNotFound, "app not found")
+			case errors.Is(err, aura.ErrNoKeyring):
+				log.Debug("Secrets are not enabled")
+				render.JSONError(rw, http.StatusNotImplemented, "secrets are not enabled")
+			case errors.As(err, &aura.ValidationError{}):
+				log.Debug("Invalid secret", lctx.Error("error", err))
+				render.JSONErrorf(rw, http.StatusBadRequest, "invalid secret: %v", err)
+			default:
+				log.Error("Could not set secret", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) setSecret(ctx context.Context, appID, name, value string) error {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.app.SetSecret(ctx, aura.SetSecretConfig{
+		App:   app,
+		Name:  name,
+		Value: value,
+	})
+	return err
+}
+
+func (s *Server) handleDeleteSecret() http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		appID := chi.URLParam(req, "app")
+		name := chi.URLParam(req, "name")
+
+		log := s.log.With(lctx.Str("app_id", appID), lctx.Str("secret", name))
+
+		if err := s.deleteSecret(req.Context(), appID, name); err != nil {
+			switch {
+			case errors.As(err, &aura.RedeployError{}):
+				log.Error("Could not redeploy secret removal", lctx.Error("error", err))
+				render.JSONError(rw, http.StatusInternalServerError, "the secret was deleted, but could not be redeployed")
+			case errors.Is(err, aura.ErrNotFound):
+				log.Debug("App or secret not found")
+				render.JSONError(rw, http.StatusNotFound, "app or secret not found")
+			default:
+				log.Error("Could not delete secret", lctx.Error("error", err))
+				render.JSONInternalServerError(rw)
+			}
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) deleteSecret(ctx context.Context, appID, name string) error {
+	app, err := s.app.App(ctx, aura.AppsQuery{IDOrName: appID})
+	if err != nil {
+		return err
+	}
+
+	return s.app.DeleteSecret(ctx, aura.DeleteSecretConfig{
+		App:  app,
+		Name: name,
+	})
+}
@@ -0,0 +1,242 @@
+package api_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_HandleGetSecrets(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		appErr         error
+		secrets        []*aura.Secret
+		secretsErr     error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name: "handles request",
+			secrets: []*aura.Secret{
+				{ID: "abc", Name: "API_KEY", KeyID: "key1", Value: []byte("encrypted"), CreatedAt: &now},
+				{ID: "def", Name: "DB_PASS", KeyID: "key1", Value: []byte("encrypted"), CreatedAt: &now},
+			},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `[{"name":"API_KEY"},{"name":"DB_PASS"}]`,
+		},
+		{
+			name:           "handles no secrets",
+			secrets:        []*aura.Secret{},
+			wantStatusCode: http.StatusOK,
+			wantResp:       `[]`,
+		},
+		{
+			name:           "handles app not found",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles secrets error",
+			secretsErr:     errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
+			if test.secrets != nil || test.secretsErr != nil {
+				app.On("Secrets", a).Return(test.secrets, test.secretsErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodGet, srvUrl+"/apps/123/secrets", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleSetSecret(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		body           []byte
+		appErr         error
+		setErr         error
+		wantSet        bool
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			body:           []byte(`{"value":"hunter2"}`),
+			wantSet:        true,
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "handles bad body",
+			body:           []byte(`{`),
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid secret data"}`,
+		},
+		{
+			name:           "handles app not found",
+			body:           []byte(`{"value":"hunter2"}`),
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app not found"}`,
+		},
+		{
+			name:           "handles no keyring",
+			body:           []byte(`{"value":"hunter2"}`),
+			setErr:         aura.ErrNoKeyring,
+			wantSet:        true,
+			wantStatusCode: http.StatusNotImplemented,
+			wantResp:       `{"error":"secrets are not enabled"}`,
+		},
+		{
+			name:           "handles validation error",
+			body:           []byte(`{"value":"hunter2"}`),
+			setErr:         aura.ValidationError{},
+			wantSet:        true,
+			wantStatusCode: http.StatusBadRequest,
+			wantResp:       `{"error":"invalid secret: validation error"}`,
+		},
+		{
+			name:           "handles redeploy error",
+			body:           []byte(`{"value":"hunter2"}`),
+			setErr:         aura.RedeployError{Err: errors.New("test")},
+			wantSet:        true,
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"the secret was saved, but could not be redeployed"}`,
+		},
+		{
+			name:           "handles set error",
+			body:           []byte(`{"value":"hunter2"}`),
+			setErr:         errors.New("test"),
+			wantSet:        true,
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			a := &aura.App{ID: "123", Name: "test-app", CreatedAt: &now}
+
+			app := &mockApp{}
+			if test.wantSet || test.appErr != nil {
+				app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(a, test.appErr)
+			}
+			if test.wantSet {
+				app.On("SetSecret", aura.SetSecretConfig{App: a, Name: "DB_PASS", Value: "hunter2"}).
+					Return(&aura.Secret{ID: "abc", Name: "DB_PASS"}, test.setErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodPut, srvUrl+"/apps/123/secrets/DB_PASS", test.body)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServer_HandleDeleteSecret(t *testing.T) {
+	now := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		app            *aura.App
+		appErr         error
+		deleteErr      error
+		wantStatusCode int
+		wantResp       string
+	}{
+		{
+			name:           "handles request",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "handles app not found",
+			appErr:         aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or secret not found"}`,
+		},
+		{
+			name:           "handles secret not found",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			deleteErr:      aura.ErrNotFound,
+			wantStatusCode: http.StatusNotFound,
+			wantResp:       `{"error":"app or secret not found"}`,
+		},
+		{
+			name:           "handles redeploy error",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			deleteErr:      aura.RedeployError{Err: errors.New("test")},
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"the secret was deleted, but could not be redeployed"}`,
+		},
+		{
+			name:           "handles delete error",
+			app:            &aura.App{ID: "123", Name: "test-app", CreatedAt: &now},
+			deleteErr:      errors.New("test"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantResp:       `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			app := &mockApp{}
+			app.On("App", aura.AppsQuery{IDOrName: "123"}).Return(test.app, test.appErr)
+			if test.app != nil {
+				app.On("DeleteSecret", aura.DeleteSecretConfig{App: test.app, Name: "DB_PASS"}).Return(test.deleteErr)
+			}
+
+			srvUrl := setupTestServer(t, app)
+
+			resp := requireDoRequest(t, http.MethodDelete, srvUrl+"/apps/123/secrets/DB_PASS", nil)
+			t.Cleanup(func() { _ = resp.Body.Close() })
+
+			require.Equal(t, test.wantStatusCode, resp.StatusCode)
+
+			got, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantResp, string(got))
+			app.AssertExpectations(t)
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	mw "github.com/hamba/pkg/v2/http/middleware"
 	"github.com/hamba/statter/v2"
 	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/render"
 )
 
 // Delegate represents an aura delegate.
@@ -16,10 +17,33 @@ type Delegate interface {
 	App(ctx context.Context, q aura.AppsQuery) (*aura.App, error)
 	Apps(ctx context.Context, q aura.AppsQuery) ([]*aura.App, error)
 	Create(ctx context.Context, cfg aura.CreateConfig) (*aura.App, error)
+	Update(ctx context.Context, cfg aura.UpdateConfig) (*aura.App, error)
 	Destroy(ctx context.Context, cfg aura.DestroyConfig) error
+	Restore(ctx context.Context, cfg aura.RestoreConfig) (*aura.App, error)
 	Release(ctx context.Context, q aura.ReleasesQuery) (*aura.Release, error)
 	Releases(ctx context.Context, q aura.ReleasesQuery) ([]*aura.Release, error)
-	Deploy(ctx context.Context, cfg aura.DeployConfig) (*aura.Release, error)
+	Deployment(ctx context.Context, q aura.DeploymentsQuery) (*aura.Deployment, error)
+	CreateDeployment(ctx context.Context, cfg aura.DeployConfig) (*aura.Deployment, error)
+	StreamDeployment(ctx context.Context, cfg aura.DeployConfig, progress func(aura.DeployEvent)) (*aura.Deployment, error)
+	CancelDeployment(ctx context.Context, cfg aura.CancelDeploymentConfig) (*aura.Deployment, error)
+	Redeploy(ctx context.Context, cfg aura.RedeployConfig) (*aura.Release, error)
+	Rollback(ctx context.Context, cfg aura.RollbackConfig) (*aura.Release, error)
+	Processes(ctx context.Context, app *aura.App) ([]*aura.Process, error)
+	Run(ctx context.Context, cfg aura.RunConfig) (*aura.Run, error)
+	Formation(ctx context.Context, app *aura.App) ([]*aura.Formation, error)
+	UpdateFormation(ctx context.Context, cfg aura.UpdateFormationConfig) ([]*aura.Formation, error)
+	Config(ctx context.Context, q aura.ConfigsQuery) (*aura.Config, error)
+	SetVars(ctx context.Context, cfg aura.SetVarsConfig) (*aura.Config, error)
+	Secrets(ctx context.Context, app *aura.App) ([]*aura.Secret, error)
+	SetSecret(ctx context.Context, cfg aura.SetSecretConfig) (*aura.Secret, error)
+	DeleteSecret(ctx context.Context, cfg aura.DeleteSecretConfig) error
+	RegistryCredentials(ctx context.Context, app *aura.App) ([]*aura.RegistryCredential, error)
+	SetRegistryCredential(ctx context.Context, cfg aura.SetRegistryCredentialConfig) (*aura.RegistryCredential, error)
+	DeleteRegistryCredential(ctx context.Context, cfg aura.DeleteRegistryCredentialConfig) error
+	SetImagePolicy(ctx context.Context, cfg aura.SetImagePolicyConfig) (*aura.App, error)
+	StartIdempotentRequest(ctx context.Context, req *aura.IdempotentRequest) (*aura.IdempotentRequest, bool, error)
+	FinishIdempotentRequest(ctx context.Context, req *aura.IdempotentRequest) error
+	AbortIdempotentRequest(ctx context.Context, req *aura.IdempotentRequest) error
 }
 
 // Server serves api requests.
@@ -49,17 +73,65 @@ func (s *Server) routes(stats *statter.Statter) http.Handler {
 	mux.Route("/apps", func(r chi.Router) {
 		r.With(mw.Stats("get_apps", stats)).Get("/", s.handleGetApps())
 		r.With(mw.Stats("get_app", stats)).Get("/{app}", s.handleGetApp())
-		r.With(mw.Stats("create_app", stats)).Post("/", s.handleCreateApp())
-		r.With(mw.Stats("destroy_app", stats)).Delete("/{app}", s.handleDestroyApp())
+		r.With(mw.Stats("create_app", stats), s.idempotent).Post("/", s.handleCreateApp())
+		r.With(mw.Stats("update_app", stats), s.idempotent).Patch("/{app}", s.handleUpdateApp())
+		r.With(mw.Stats("destroy_app", stats), s.idempotent).Delete("/{app}", s.handleDestroyApp())
+		r.With(mw.Stats("restore_app", stats), s.idempotent).Post("/{app}/restore", s.handleRestoreApp())
 
 		r.With(mw.Stats("get_releases", stats)).Get("/{app}/releases", s.handleGetReleases())
 		r.With(mw.Stats("get_release", stats)).Get("/{app}/releases/{version}", s.handleGetRelease())
-		r.With(mw.Stats("deploy_app", stats)).Post("/{app}/deploys", s.handlerDeployApp())
+		r.Post("/{app}/deploys", s.handleDeployOrStream(
+			mw.WithStats("deploy_app", stats, s.idempotent(s.handleDeployApp())),
+			s.handleStreamDeployApp(),
+		))
+		r.With(mw.Stats("get_deploy", stats)).Get("/{app}/deploys/{id}", s.handleGetDeploy())
+		r.With(mw.Stats("cancel_deploy", stats)).Post("/{app}/deploys/{id}/cancel", s.handleCancelDeploy())
+		r.With(mw.Stats("redeploy_app", stats), s.idempotent).Post("/{app}/releases/current/redeploy", s.handleRedeployApp())
+		r.With(mw.Stats("rollback_app", stats), s.idempotent).Post("/{app}/releases/{version}/rollback", s.handleRollbackApp())
+
+		r.With(mw.Stats("get_processes", stats)).Get("/{app}/processes", s.handleGetProcesses())
+		r.With(mw.Stats("run_app", stats)).Post("/{app}/runs", s.handleRunApp())
+
+		r.With(mw.Stats("get_formation", stats)).Get("/{app}/formation", s.handleGetFormation())
+		r.With(mw.Stats("update_formation", stats)).Patch("/{app}/formation", s.handleUpdateFormation())
+
+		r.With(mw.Stats("get_config", stats)).Get("/{app}/config", s.handleGetConfig())
+		r.With(mw.Stats("update_config", stats), s.idempotent).Patch("/{app}/config", s.handleUpdateConfig())
+
+		r.With(mw.Stats("get_secrets", stats)).Get("/{app}/secrets", s.handleGetSecrets())
+		r.With(mw.Stats("set_secret", stats)).Put("/{app}/secrets/{name}", s.handleSetSecret())
+		r.With(mw.Stats("delete_secret", stats)).Delete("/{app}/secrets/{name}", s.handleDeleteSecret())
+
+		r.With(mw.Stats("get_registry_credentials", stats)).Get("/{app}/registry-credentials", s.handleGetRegistryCredentials())
+		r.With(mw.Stats("set_registry_credential", stats)).Put("/{app}/registry-credentials/{registry}", s.handleSetRegistryCredential())
+		r.With(mw.Stats("delete_registry_credential", stats)).Delete("/{app}/registry-credentials/{registry}", s.handleDeleteRegistryCredential())
+
+		r.With(mw.Stats("get_policy", stats)).Get("/{app}/policy", s.handleGetPolicy())
+		r.With(mw.Stats("set_policy", stats)).Put("/{app}/policy", s.handleSetPolicy())
 	})
 
 	return mux
 }
 
+// handleDeployOrStream routes streaming deploy requests to stream.
+//
+// The stream is not wrapped with stats, as the stats response
+// writer cannot be flushed. A stream cannot be replayed, so
+// streaming requests with an idempotency key are rejected.
+func (s *Server) handleDeployOrStream(deploy, stream http.Handler) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if streamContentType(req) != "" {
+			if req.Header.Get(headerIdempotencyKey) != "" {
+				render.JSONError(rw, http.StatusBadRequest, "idempotency keys are not supported on streamed deploys")
+				return
+			}
+			stream.ServeHTTP(rw, req)
+			return
+		}
+		deploy.ServeHTTP(rw, req)
+	}
+}
+
 // ServeHTTP serves an HTTP request.
 func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	s.h.ServeHTTP(rw, req)
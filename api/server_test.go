@@ -91,11 +91,27 @@ func (m *mockApp) Create(_ context.Context, cfg aura.CreateConfig) (*aura.App, e
 	return args.Get(0).(*aura.App), args.Error(1)
 }
 
+func (m *mockApp) Update(_ context.Context, cfg aura.UpdateConfig) (*aura.App, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.App), args.Error(1)
+}
+
 func (m *mockApp) Destroy(_ context.Context, cfg aura.DestroyConfig) error {
 	args := m.Called(cfg)
 	return args.Error(0)
 }
 
+func (m *mockApp) Restore(_ context.Context, cfg aura.RestoreConfig) (*aura.App, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.App), args.Error(1)
+}
+
 func (m *mockApp) Release(_ context.Context, q aura.ReleasesQuery) (*aura.Release, error) {
 	args := m.Called(q)
 	if args.Get(0) == nil {
@@ -112,10 +128,169 @@ func (m *mockApp) Releases(_ context.Context, q aura.ReleasesQuery) ([]*aura.Rel
 	return args.Get(0).([]*aura.Release), args.Error(1)
 }
 
-func (m *mockApp) Deploy(_ context.Context, cfg aura.DeployConfig) (*aura.Release, error) {
+func (m *mockApp) Deployment(_ context.Context, q aura.DeploymentsQuery) (*aura.Deployment, error) {
+	args := m.Called(q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.Deployment), args.Error(1)
+}
+
+func (m *mockApp) StreamDeployment(_ context.Context, cfg aura.DeployConfig, progress func(aura.DeployEvent)) (*aura.Deployment, error) {
+	args := m.Called(cfg)
+	if len(args) > 2 {
+		args.Get(2).(func(func(aura.DeployEvent)))(progress)
+	}
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.Deployment), args.Error(1)
+}
+
+func (m *mockApp) CreateDeployment(_ context.Context, cfg aura.DeployConfig) (*aura.Deployment, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.Deployment), args.Error(1)
+}
+
+func (m *mockApp) CancelDeployment(_ context.Context, cfg aura.CancelDeploymentConfig) (*aura.Deployment, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.Deployment), args.Error(1)
+}
+
+func (m *mockApp) Redeploy(_ context.Context, cfg aura.RedeployConfig) (*aura.Release, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.Release), args.Error(1)
+}
+
+func (m *mockApp) Rollback(_ context.Context, cfg aura.RollbackConfig) (*aura.Release, error) {
 	args := m.Called(cfg)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*aura.Release), args.Error(1)
 }
+
+func (m *mockApp) Processes(_ context.Context, app *aura.App) ([]*aura.Process, error) {
+	args := m.Called(app)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*aura.Process), args.Error(1)
+}
+
+func (m *mockApp) Run(_ context.Context, cfg aura.RunConfig) (*aura.Run, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.Run), args.Error(1)
+}
+
+func (m *mockApp) Formation(_ context.Context, app *aura.App) ([]*aura.Formation, error) {
+	args := m.Called(app)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*aura.Formation), args.Error(1)
+}
+
+func (m *mockApp) UpdateFormation(_ context.Context, cfg aura.UpdateFormationConfig) ([]*aura.Formation, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*aura.Formation), args.Error(1)
+}
+
+func (m *mockApp) Config(_ context.Context, q aura.ConfigsQuery) (*aura.Config, error) {
+	args := m.Called(q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.Config), args.Error(1)
+}
+
+func (m *mockApp) SetVars(_ context.Context, cfg aura.SetVarsConfig) (*aura.Config, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.Config), args.Error(1)
+}
+
+func (m *mockApp) Secrets(_ context.Context, app *aura.App) ([]*aura.Secret, error) {
+	args := m.Called(app)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*aura.Secret), args.Error(1)
+}
+
+func (m *mockApp) SetSecret(_ context.Context, cfg aura.SetSecretConfig) (*aura.Secret, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.Secret), args.Error(1)
+}
+
+func (m *mockApp) DeleteSecret(_ context.Context, cfg aura.DeleteSecretConfig) error {
+	args := m.Called(cfg)
+	return args.Error(0)
+}
+
+func (m *mockApp) RegistryCredentials(_ context.Context, app *aura.App) ([]*aura.RegistryCredential, error) {
+	args := m.Called(app)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*aura.RegistryCredential), args.Error(1)
+}
+
+func (m *mockApp) SetRegistryCredential(_ context.Context, cfg aura.SetRegistryCredentialConfig) (*aura.RegistryCredential, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.RegistryCredential), args.Error(1)
+}
+
+func (m *mockApp) DeleteRegistryCredential(_ context.Context, cfg aura.DeleteRegistryCredentialConfig) error {
+	args := m.Called(cfg)
+	return args.Error(0)
+}
+
+func (m *mockApp) SetImagePolicy(_ context.Context, cfg aura.SetImagePolicyConfig) (*aura.App, error) {
+	args := m.Called(cfg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*aura.App), args.Error(1)
+}
+
+func (m *mockApp) StartIdempotentRequest(_ context.Context, req *aura.IdempotentRequest) (*aura.IdempotentRequest, bool, error) {
+	args := m.Called(req)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*aura.IdempotentRequest), args.Bool(1), args.Error(2)
+}
+
+func (m *mockApp) FinishIdempotentRequest(_ context.Context, req *aura.IdempotentRequest) error {
+	args := m.Called(req)
+	return args.Error(0)
+}
+
+func (m *mockApp) AbortIdempotentRequest(_ context.Context, req *aura.IdempotentRequest) error {
+	args := m.Called(req)
+	return args.Error(0)
+}
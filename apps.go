@@ -2,26 +2,79 @@ package aura
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	lctx "github.com/hamba/logger/v2/ctx"
 	"github.com/segmentio/ksuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// Labels contains application labels.
+type Labels map[string]string
+
+// Scan decodes labels from a database field.
+func (l *Labels) Scan(src any) error {
+	var b []byte
+	switch val := src.(type) {
+	case string:
+		b = []byte(val)
+	case []byte:
+		b = val
+	default:
+		return nil
+	}
+
+	return json.Unmarshal(b, l)
+}
+
+// Value encodes labels into a database field.
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		l = Labels{}
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return driver.Value(string(b)), nil
+}
+
 // App contains the info of an application.
 type App struct {
-	ID        string
-	Name      string
-	CreatedAt *time.Time
-	DeletedAt *time.Time
+	ID          string
+	Name        string
+	Description string
+	Owner       string
+	Labels      Labels
+	ImagePolicy ImagePolicy
+	CreatedAt   *time.Time
+	UpdatedAt   *time.Time
+	DeletedAt   *time.Time
 }
 
 // BeforeCreate is a pre-creation hook.
 func (a *App) BeforeCreate(_ *gorm.DB) error {
 	a.ID = ksuid.New().String()
 
+	if a.Labels == nil {
+		a.Labels = Labels{}
+	}
+
 	now := time.Now().UTC()
 	a.CreatedAt = &now
+	a.UpdatedAt = &now
+
+	return nil
+}
+
+// BeforeUpdate is a pre-update hook.
+func (a *App) BeforeUpdate(_ *gorm.DB) error {
+	now := time.Now().UTC()
+	a.UpdatedAt = &now
 
 	return nil
 }
@@ -33,7 +86,8 @@ type appService struct {
 func (s *appService) First(ctx context.Context, scope scope) (*App, error) {
 	var app *App
 	scope = composedScope{order("name"), scope}
-	return app, s.db.WithContext(ctx).Scopes(scope.scope).First(&app).Error
+	// Take is used as First orders by the primary key before the scopes are applied.
+	return app, s.db.WithContext(ctx).Scopes(scope.scope).Take(&app).Error
 }
 
 func (s *appService) Find(ctx context.Context, scope scope) ([]*App, error) {
@@ -46,12 +100,95 @@ func (s *appService) Create(ctx context.Context, app *App) (*App, error) {
 	return app, s.db.WithContext(ctx).Create(app).Error
 }
 
-func (s *appService) Update(ctx context.Context, app *App) error {
-	return s.db.WithContext(ctx).Save(app).Error
+// Update updates the given columns of the application, so concurrent
+// changes to other columns are kept.
+func (s *appService) Update(ctx context.Context, app *App, columns ...string) error {
+	columns = append(columns, "updated_at")
+	return s.db.WithContext(ctx).Model(app).Select(columns).Updates(app).Error
+}
+
+// Modify changes the application with fn, updating the given columns.
+// The application is read and updated under a row lock, so changes
+// based on its current values are not lost in a race.
+func (s *appService) Modify(ctx context.Context, id string, columns []string, fn func(app *App)) (*App, error) {
+	tx := s.db.WithContext(ctx).Begin()
+	defer func() { _ = tx.Rollback() }()
+
+	var app *App
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Take(&app).Error; err != nil {
+		return nil, err
+	}
+
+	fn(app)
+
+	columns = append(columns, "updated_at")
+	if err := tx.Model(app).Select(columns).Updates(app).Error; err != nil {
+		return nil, err
+	}
+
+	return app, tx.Commit().Error
 }
 
 func (s *appService) Delete(ctx context.Context, app *App) error {
 	now := time.Now().UTC()
 	app.DeletedAt = &now
-	return s.Update(ctx, app)
+	return s.Update(ctx, app, "deleted_at")
+}
+
+func (s *appService) Restore(ctx context.Context, app *App) error {
+	app.DeletedAt = nil
+	return s.Update(ctx, app, "deleted_at")
+}
+
+// Purge permanently deletes the apps deleted before the given time,
+// returning the number of purged apps. The app data is removed by
+// the database cascade.
+func (s *appService) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res := s.db.WithContext(ctx).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Delete(&App{})
+	return res.RowsAffected, res.Error
+}
+
+// appPurgeInterval is the interval deleted applications are purged at.
+const appPurgeInterval = time.Hour
+
+// PurgeApps permanently deletes the applications that were deleted
+// longer than the retention period ago, along with their releases,
+// configs and other data.
+func (a *Aura) PurgeApps(ctx context.Context) (int64, error) {
+	n, err := a.apps.Purge(ctx, time.Now().UTC().Add(-a.appRetention))
+	if err != nil {
+		return 0, fmt.Errorf("could not purge apps: %w", err)
+	}
+	return n, nil
+}
+
+// RunAppPurger purges deleted applications periodically until the
+// context is cancelled. If the retention is zero, apps are never purged.
+func (a *Aura) RunAppPurger(ctx context.Context) {
+	if a.appRetention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(appPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := a.PurgeApps(ctx)
+		switch {
+		case err == nil:
+			if n > 0 {
+				a.log.Info("Purged deleted apps", lctx.Int64("count", n))
+			}
+		case ctx.Err() != nil:
+			return
+		default:
+			a.log.Error("Could not purge apps", lctx.Error("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
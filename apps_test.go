@@ -0,0 +1,68 @@
+package aura_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/memory"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAura_PurgeApps(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched, aura.WithAppRetention(time.Nanosecond))
+
+	app1, err := a.Create(context.Background(), aura.CreateConfig{Name: "test1-app"})
+	require.NoError(t, err)
+	app2, err := a.Create(context.Background(), aura.CreateConfig{Name: "test2-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app2, Image: img})
+	require.NoError(t, err)
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app2})
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+
+	n, err := a.PurgeApps(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = a.App(context.Background(), aura.AppsQuery{ID: app2.ID, IncludeDeleted: true})
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+	releases, err := a.Releases(context.Background(), aura.ReleasesQuery{App: app2})
+	require.NoError(t, err)
+	assert.Len(t, releases, 0)
+	_, err = a.App(context.Background(), aura.AppsQuery{ID: app1.ID})
+	assert.NoError(t, err)
+}
+
+func TestAura_PurgeAppsKeepsAppsInRetention(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched, aura.WithAppRetention(time.Hour))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app})
+	require.NoError(t, err)
+
+	n, err := a.PurgeApps(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	_, err = a.App(context.Background(), aura.AppsQuery{ID: app.ID, IncludeDeleted: true})
+	assert.NoError(t, err)
+}
@@ -4,39 +4,173 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
 
+	"github.com/hamba/logger/v2"
 	errorsx "github.com/hamba/pkg/v2/errors"
 	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/nrwiersma/aura/pkg/keyring"
+	"github.com/nrwiersma/aura/pkg/procfile"
 	"gorm.io/gorm"
 )
 
-// ErrNotFound is returned when a record is not found.
-const ErrNotFound = errorsx.Error("not found")
+// Errors returned by aura.
+const (
+	// ErrNotFound is returned when a record is not found.
+	ErrNotFound = errorsx.Error("not found")
+	// ErrNoKeyring is returned when secrets are used without a keyring.
+	ErrNoKeyring = errorsx.Error("no keyring configured")
+	// ErrConflict is returned when a change conflicts with the current state,
+	// such as a duplicate app name or a running deploy.
+	ErrConflict = errorsx.Error("conflict")
+)
 
 // Registry represents an image registry.
+//
+// The registry auth is nil if there are no credentials for the
+// registry of the image.
 type Registry interface {
-	Resolve(ctx context.Context, img image.Image) (image.Image, error)
-	ExtractProcfile(ctx context.Context, img string) ([]byte, error)
+	Resolve(ctx context.Context, img image.Image, auth *RegistryAuth, progress func(PullProgress)) (image.Image, error)
+	ExtractProcfile(ctx context.Context, img string, auth *RegistryAuth) ([]byte, error)
+}
+
+// Scheduler represents a deployment scheduler.
+type Scheduler interface {
+	Submit(ctx context.Context, app *App, release *Release, formation []*Formation) error
+	Remove(ctx context.Context, app *App) error
+	Processes(ctx context.Context, app *App) ([]*Process, error)
+	Run(ctx context.Context, app *App, release *Release, run *Run) error
 }
 
 // Aura manages the deployment of applications.
 type Aura struct {
-	db  *DB
-	reg Registry
+	db    *DB
+	reg   Registry
+	sched Scheduler
+
+	apps        *appService
+	releases    *releaseService
+	configs     *configService
+	secrets     *secretService
+	regCreds    *registryCredentialService
+	formations  *formationService
+	deployments *deploymentService
+	idempotency *idempotencyService
+
+	deployNotify  chan struct{}
+	deployTimeout time.Duration
+
+	idempotencyWindow time.Duration
+
+	appRetention time.Duration
+
+	locks     deployLocker
+	runningMu sync.Mutex
+	running   map[string]*runningDeploy
+
+	keys *keyring.Keyring
+
+	registryAuths map[string]RegistryAuth
 
-	apps     *appService
-	releases *releaseService
+	imagePolicy ImagePolicy
+
+	log *logger.Logger
+}
+
+// defaultIdempotencyWindow is the default time idempotency keys are kept.
+const defaultIdempotencyWindow = 24 * time.Hour
+
+// defaultAppRetention is the default time deleted applications are kept.
+const defaultAppRetention = 30 * 24 * time.Hour
+
+// Option configures aura.
+type Option func(*Aura)
+
+// WithKeyring sets the keyring used to encrypt secrets.
+// Without a keyring secrets cannot be used.
+func WithKeyring(keys *keyring.Keyring) Option {
+	return func(a *Aura) {
+		a.keys = keys
+	}
+}
+
+// WithRegistryAuths sets the server wide registry credentials, keyed
+// by registry host. Credentials set on an application take precedence.
+func WithRegistryAuths(auths map[string]RegistryAuth) Option {
+	return func(a *Aura) {
+		a.registryAuths = make(map[string]RegistryAuth, len(auths))
+		for reg, auth := range auths {
+			a.registryAuths[RegistryHost(reg)] = auth
+		}
+	}
+}
+
+// WithImagePolicy sets the server wide image policy. It is enforced
+// in addition to the policy set on an application.
+func WithImagePolicy(p ImagePolicy) Option {
+	return func(a *Aura) {
+		a.imagePolicy = p
+	}
+}
+
+// WithDeployTimeout sets the maximum duration of a deploy.
+func WithDeployTimeout(d time.Duration) Option {
+	return func(a *Aura) {
+		a.deployTimeout = d
+	}
+}
+
+// WithIdempotencyWindow sets how long idempotency keys are kept.
+func WithIdempotencyWindow(d time.Duration) Option {
+	return func(a *Aura) {
+		a.idempotencyWindow = d
+	}
+}
+
+// WithAppRetention sets how long deleted applications are kept
+// before they are purged. Zero disables purging.
+func WithAppRetention(d time.Duration) Option {
+	return func(a *Aura) {
+		a.appRetention = d
+	}
+}
+
+// WithLogger sets the logger used for background work.
+func WithLogger(log *logger.Logger) Option {
+	return func(a *Aura) {
+		a.log = log
+	}
 }
 
 // New returns an app handler.
-func New(db *DB, reg Registry) *Aura {
+func New(db *DB, reg Registry, sched Scheduler, opts ...Option) *Aura {
 	aura := &Aura{
-		db:  db,
-		reg: reg,
+		db:                db,
+		reg:               reg,
+		sched:             sched,
+		deployNotify:      make(chan struct{}, 1),
+		locks:             newDeployLocker(db),
+		running:           map[string]*runningDeploy{},
+		idempotencyWindow: defaultIdempotencyWindow,
+		appRetention:      defaultAppRetention,
+		log:               logger.New(io.Discard, logger.LogfmtFormat(), logger.Error),
 	}
 
 	aura.apps = &appService{db: db}
 	aura.releases = &releaseService{db: db}
+	aura.configs = &configService{db: db}
+	aura.secrets = &secretService{db: db}
+	aura.regCreds = &registryCredentialService{db: db}
+	aura.formations = &formationService{db: db}
+	aura.deployments = &deploymentService{db: db}
+	aura.idempotency = &idempotencyService{db: db}
+
+	for _, opt := range opts {
+		opt(aura)
+	}
 
 	return aura
 }
@@ -46,10 +180,25 @@ type AppsQuery struct {
 	ID string
 
 	Name string
+
+	// IDOrName matches an application by either its ID or name.
+	IDOrName string
+
+	// IncludeDeleted includes deleted applications.
+	IncludeDeleted bool
 }
 
 func (q AppsQuery) scope(db *gorm.DB) *gorm.DB {
-	scope := composedScope{isNull("deleted_at")}
+	var scope composedScope
+
+	if !q.IncludeDeleted {
+		scope = append(scope, isNull("deleted_at"))
+	} else {
+		// A deleted application can share its name with a live application
+		// and other deleted applications. The live application comes first,
+		// then the most recently deleted.
+		scope = append(scope, order("deleted_at IS NOT NULL, deleted_at DESC"))
+	}
 
 	if q.ID != "" {
 		scope = append(scope, idEquals(q.ID))
@@ -59,6 +208,12 @@ func (q AppsQuery) scope(db *gorm.DB) *gorm.DB {
 		scope = append(scope, fieldEquals("name", q.Name))
 	}
 
+	if q.IDOrName != "" {
+		scope = append(scope, scopeFunc(func(db *gorm.DB) *gorm.DB {
+			return db.Where("id = ? OR name = ?", q.IDOrName, q.IDOrName)
+		}))
+	}
+
 	return scope.scope(db)
 }
 
@@ -88,28 +243,183 @@ type CreateConfig struct {
 
 // Validate validates a create configuration.
 func (c CreateConfig) Validate() error {
-	if c.Name == "" {
+	return validateAppName(c.Name)
+}
+
+// appNameRegexp matches DNS label safe application names.
+var appNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+func validateAppName(name string) error {
+	if name == "" {
 		return errors.New("app name is required")
 	}
+	if len(name) > 50 {
+		return errors.New("app name must be at most 50 characters")
+	}
+	if !appNameRegexp.MatchString(name) {
+		return errors.New("app name must contain only lowercase letters, digits and hyphens, and start and end with a letter or digit")
+	}
 
 	return nil
 }
 
 // Create creates an application.
+//
+// Application names are unique, if an application with the
+// name exists, ErrConflict is returned.
 func (a *Aura) Create(ctx context.Context, cfg CreateConfig) (*App, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, ValidationError{err: err}
 	}
 
+	if err := a.ensureAppNameFree(ctx, cfg.Name); err != nil {
+		return nil, err
+	}
+
 	app, err := a.apps.Create(ctx, &App{
 		Name: cfg.Name,
 	})
 	if err != nil {
+		// The name may have been taken in a race, caught by the unique index.
+		if nameErr := a.ensureAppNameFree(ctx, cfg.Name); nameErr != nil {
+			return nil, nameErr
+		}
 		return nil, fmt.Errorf("could not create app: %w", err)
 	}
 	return app, nil
 }
 
+func (a *Aura) ensureAppNameFree(ctx context.Context, name string) error {
+	_, err := a.apps.First(ctx, AppsQuery{Name: name})
+	switch {
+	case err == nil:
+		return ErrConflict
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil
+	default:
+		return fmt.Errorf("could not find app: %w", err)
+	}
+}
+
+// UpdateConfig contains application update configuration.
+//
+// Only the non-nil fields are updated.
+type UpdateConfig struct {
+	App *App
+
+	Name        *string
+	Description *string
+	Owner       *string
+
+	// Labels contains the labels to set. A nil value unsets the label.
+	Labels map[string]*string
+}
+
+// Validate validates an update configuration.
+func (c UpdateConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if c.Name == nil && c.Description == nil && c.Owner == nil && len(c.Labels) == 0 {
+		return errors.New("at least one field to update is required")
+	}
+	if c.Name != nil {
+		if err := validateAppName(*c.Name); err != nil {
+			return err
+		}
+	}
+	if c.Owner != nil && len(*c.Owner) > 255 {
+		return errors.New("owner must be at most 255 characters")
+	}
+	for k, v := range c.Labels {
+		if len(k) > 63 || !labelNameRegexp.MatchString(k) {
+			return fmt.Errorf("invalid label name %q", k)
+		}
+		if v != nil && len(*v) > 255 {
+			return fmt.Errorf("label %q must be at most 255 characters", k)
+		}
+	}
+
+	return nil
+}
+
+var labelNameRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+
+// Update updates the name and metadata of an application.
+//
+// Application names are unique, if another application has
+// the new name, ErrConflict is returned.
+func (a *Aura) Update(ctx context.Context, cfg UpdateConfig) (*App, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+
+	renamed := cfg.Name != nil && *cfg.Name != cfg.App.Name
+	if renamed {
+		if err := a.ensureAppNameFree(ctx, *cfg.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only the changed columns are updated, from the current application,
+	// so concurrent changes are not overwritten.
+	var columns []string
+	if cfg.Name != nil {
+		columns = append(columns, "name")
+	}
+	if cfg.Description != nil {
+		columns = append(columns, "description")
+	}
+	if cfg.Owner != nil {
+		columns = append(columns, "owner")
+	}
+	if len(cfg.Labels) > 0 {
+		columns = append(columns, "labels")
+	}
+
+	app, err := a.apps.Modify(ctx, cfg.App.ID, columns, func(app *App) {
+		if cfg.Name != nil {
+			app.Name = *cfg.Name
+		}
+		if cfg.Description != nil {
+			app.Description = *cfg.Description
+		}
+		if cfg.Owner != nil {
+			app.Owner = *cfg.Owner
+		}
+		if len(cfg.Labels) > 0 {
+			labels := Labels{}
+			for k, v := range app.Labels {
+				labels[k] = v
+			}
+			for k, v := range cfg.Labels {
+				if v == nil {
+					delete(labels, k)
+					continue
+				}
+				labels[k] = *v
+			}
+			app.Labels = labels
+		}
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, ErrNotFound
+		case renamed:
+			// The name may have been taken in a race, caught by the unique index.
+			if nameErr := a.ensureAppNameFree(ctx, *cfg.Name); nameErr != nil {
+				return nil, nameErr
+			}
+		}
+		return nil, fmt.Errorf("could not update app: %w", err)
+	}
+	return app, nil
+}
+
 // DestroyConfig contains application removal configuration.
 type DestroyConfig struct {
 	App *App
@@ -128,25 +438,116 @@ func (c DestroyConfig) Validate() error {
 }
 
 // Destroy removes an application and any existing deployment.
+//
+// Pending and running deployments of the application are cancelled,
+// and any other deploy is waited for, so no processes are scheduled
+// once the application is removed.
 func (a *Aura) Destroy(ctx context.Context, cfg DestroyConfig) error {
 	if err := cfg.Validate(); err != nil {
 		return ValidationError{err: err}
 	}
 
-	if err := a.apps.Delete(ctx, cfg.App); err != nil {
+	if err := a.cancelDeployments(ctx, cfg.App); err != nil {
+		return err
+	}
+
+	unlock, err := a.lock(ctx, cfg.App.ID, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err = a.sched.Remove(ctx, cfg.App); err != nil {
+		return fmt.Errorf("could not remove app from scheduler: %w", err)
+	}
+
+	if err = a.apps.Delete(ctx, cfg.App); err != nil {
 		return fmt.Errorf("could not delete app: %w", err)
 	}
 
-	// TODO: Remove the application from the deployment.
+	return nil
+}
+
+// RestoreConfig contains application restore configuration.
+type RestoreConfig struct {
+	App *App
+}
+
+// Validate validates a restore configuration.
+func (c RestoreConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if c.App.DeletedAt == nil {
+		return errors.New("the application is not deleted")
+	}
 
 	return nil
 }
 
+// Restore restores a deleted application, running its current
+// release again if it has one.
+//
+// If another application has taken the name of the deleted
+// application, ErrConflict is returned.
+func (a *Aura) Restore(ctx context.Context, cfg RestoreConfig) (*App, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+
+	if err := a.ensureAppNameFree(ctx, cfg.App.Name); err != nil {
+		return nil, err
+	}
+
+	unlock, err := a.lock(ctx, cfg.App.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	app := *cfg.App
+	if err = a.apps.Restore(ctx, &app); err != nil {
+		// The name may have been taken in a race, caught by the unique index.
+		if nameErr := a.ensureAppNameFree(ctx, app.Name); nameErr != nil {
+			return nil, nameErr
+		}
+		return nil, fmt.Errorf("could not restore app: %w", err)
+	}
+
+	release, err := a.currentRelease(ctx, &app)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return &app, nil
+		default:
+			return nil, err
+		}
+	}
+
+	formation, err := a.formation(ctx, &app, release)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.prepareRelease(ctx, &app, release); err != nil {
+		return nil, err
+	}
+
+	if err = a.sched.Submit(ctx, &app, release, formation); err != nil {
+		return nil, fmt.Errorf("could not submit release: %w", err)
+	}
+
+	return &app, nil
+}
+
 // ReleasesQuery contains a release query.
 type ReleasesQuery struct {
 	App *App
 
 	Version int
+	Status  string
 }
 
 func (q ReleasesQuery) scope(db *gorm.DB) *gorm.DB {
@@ -160,9 +561,26 @@ func (q ReleasesQuery) scope(db *gorm.DB) *gorm.DB {
 		scope = append(scope, fieldEquals("version", q.Version))
 	}
 
+	if q.Status != "" {
+		scope = append(scope, fieldEquals("status", q.Status))
+	}
+
 	return scope.scope(db)
 }
 
+// ReleaseProcess is the name of the procfile process run
+// before a release is deployed.
+const ReleaseProcess = "release"
+
+// Release reasons.
+const (
+	ReasonDeploy       = "deploy"
+	ReasonRedeploy     = "redeploy"
+	ReasonConfigChange = "config change"
+	ReasonSecretChange = "secret change"
+	ReasonRollback     = "rollback"
+)
+
 // Release returns the first application matching the query.
 func (a *Aura) Release(ctx context.Context, q ReleasesQuery) (*Release, error) {
 	release, err := a.releases.First(ctx, q)
@@ -187,6 +605,10 @@ type DeployConfig struct {
 	App *App
 
 	Image image.Image
+
+	// NoWait rejects the deploy with ErrConflict when another deploy
+	// of the application is running, instead of waiting for it.
+	NoWait bool
 }
 
 // Validate validates a deploy configuration.
@@ -202,31 +624,340 @@ func (c DeployConfig) Validate() error {
 }
 
 // Deploy creates a release and deploys it.
+//
+// If the procfile has a release process, it is run before the release
+// is deployed. When it exits non-zero, the release is recorded as failed
+// and a ReleasePhaseError is returned.
 func (a *Aura) Deploy(ctx context.Context, cfg DeployConfig) (*Release, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, ValidationError{err: err}
 	}
 
-	img, err := a.reg.Resolve(ctx, cfg.Image)
+	unlock, err := a.lockActiveApp(ctx, cfg.App, !cfg.NoWait)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ctx, cancel := a.deployContext(ctx)
+	defer cancel()
+
+	return a.deploy(ctx, cfg, func(DeployEvent) {})
+}
+
+// deploy deploys the image, reporting each deployment step and
+// the image pull progress to progress.
+func (a *Aura) deploy(ctx context.Context, cfg DeployConfig, progress func(DeployEvent)) (*Release, error) {
+	if err := a.checkImagePolicy(cfg.App, cfg.Image); err != nil {
+		return nil, err
+	}
+
+	progress(DeployEvent{Status: DeploymentResolving})
+	auth, err := a.registryAuth(ctx, cfg.App, cfg.Image.Registry)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := a.reg.Resolve(ctx, cfg.Image, auth, func(p PullProgress) {
+		progress(DeployEvent{Status: DeploymentResolving, Pull: &p})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("could not resolve image: %w", err)
 	}
 
-	procFile, err := a.reg.ExtractProcfile(ctx, img.String())
+	progress(DeployEvent{Status: DeploymentExtracting})
+	procFile, err := a.reg.ExtractProcfile(ctx, img.String(), auth)
 	if err != nil {
 		return nil, fmt.Errorf("could not extract procfile: %w", err)
 	}
 
-	release, err := a.releases.Create(ctx, &Release{
-		AppID:    cfg.App.ID,
-		Image:    &img,
-		Procfile: procFile,
-	})
+	if _, err = procfile.Parse(procFile); err != nil {
+		return nil, ValidationError{err: fmt.Errorf("invalid procfile: %w", err)}
+	}
+
+	progress(DeployEvent{Status: DeploymentReleasing})
+	appCfg, err := a.latestConfig(ctx, cfg.App)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &Release{
+		AppID:       cfg.App.ID,
+		Image:       &img,
+		IndexDigest: img.IndexDigest,
+		Procfile:    procFile,
+		ConfigID:    configID(appCfg),
+		Config:      appCfg,
+		Reason:      ReasonDeploy,
+	}
+	if !img.Platform.IsZero() {
+		release.Platform = &img.Platform
+	}
+	return a.release(ctx, cfg.App, release, true)
+}
+
+// RedeployConfig contains application redeploy configuration.
+type RedeployConfig struct {
+	App *App
+
+	// Reason is the reason for the redeploy. It defaults to "redeploy".
+	Reason string
+}
+
+// Validate validates a redeploy configuration.
+func (c RedeployConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if len(c.Reason) > 50 {
+		return errors.New("the reason must be at most 50 characters")
+	}
+
+	return nil
+}
+
+// Redeploy creates a new release from the current release and deploys it.
+//
+// The image and procfile of the current release are reused, while the
+// latest config is applied.
+func (a *Aura) Redeploy(ctx context.Context, cfg RedeployConfig) (*Release, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+
+	unlock, err := a.lockActiveApp(ctx, cfg.App, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	current, err := a.currentRelease(ctx, cfg.App)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.checkReleasePolicy(cfg.App, current); err != nil {
+		return nil, err
+	}
+
+	reason := cfg.Reason
+	if reason == "" {
+		reason = ReasonRedeploy
+	}
+	return a.redeploy(ctx, cfg.App, current, reason)
+}
+
+func (a *Aura) redeploy(ctx context.Context, app *App, current *Release, reason string) (*Release, error) {
+	appCfg, err := a.latestConfig(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.release(ctx, app, &Release{
+		AppID:       app.ID,
+		Image:       current.Image,
+		IndexDigest: current.IndexDigest,
+		Platform:    current.Platform,
+		Procfile:    current.Procfile,
+		ConfigID:    configID(appCfg),
+		Config:      appCfg,
+		Reason:      reason,
+	}, false)
+}
+
+// RollbackConfig contains application rollback configuration.
+type RollbackConfig struct {
+	App *App
+
+	// Version is the release version to roll back to.
+	Version int
+}
+
+// Validate validates a rollback configuration.
+func (c RollbackConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if c.Version <= 0 {
+		return errors.New("the version must be a positive integer")
+	}
+
+	return nil
+}
+
+// Rollback creates a new release from a previous release version and deploys it.
+//
+// The image, procfile and config of the previous release are reused.
+func (a *Aura) Rollback(ctx context.Context, cfg RollbackConfig) (*Release, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+
+	unlock, err := a.lockActiveApp(ctx, cfg.App, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	prev, err := a.Release(ctx, ReleasesQuery{App: cfg.App, Version: cfg.Version})
+	if err != nil {
+		return nil, err
+	}
+	// Pending releases were never submitted, and failed releases cannot be.
+	if prev.Status != ReleaseActive {
+		return nil, ValidationError{err: fmt.Errorf("release %d is %s and cannot be rolled back to", prev.Version, prev.Status)}
+	}
+	if err = a.checkReleasePolicy(cfg.App, prev); err != nil {
+		return nil, err
+	}
+
+	ver := prev.Version
+	return a.release(ctx, cfg.App, &Release{
+		AppID:           cfg.App.ID,
+		Image:           prev.Image,
+		IndexDigest:     prev.IndexDigest,
+		Platform:        prev.Platform,
+		Procfile:        prev.Procfile,
+		ConfigID:        prev.ConfigID,
+		Config:          prev.Config,
+		Reason:          ReasonRollback,
+		RollbackVersion: &ver,
+	}, false)
+}
+
+// release creates the release and submits it to the scheduler.
+//
+// If phase is true and the procfile has a release process, it is run
+// to completion before the release is submitted.
+func (a *Aura) release(ctx context.Context, app *App, release *Release, phase bool) (*Release, error) {
+	var releaseCmd string
+	if phase {
+		releaseCmd = releaseCommand(release.Procfile)
+	}
+
+	// The release only becomes current once it is submitted.
+	release.Status = ReleasePending
+
+	if err := a.prepareRelease(ctx, app, release); err != nil {
+		return nil, err
+	}
+	release, err := a.releases.Create(ctx, release)
 	if err != nil {
 		return nil, fmt.Errorf("could not create release: %w", err)
 	}
 
-	// TODO: Deploy the release with the deployment.
+	if releaseCmd != "" {
+		if err = a.releasePhase(ctx, app, release, releaseCmd); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = a.submitRelease(ctx, app, release); err != nil {
+		release.Status = ReleaseFailed
+		// The submit error is more important than recording the failure.
+		_ = a.releases.Update(ctx, release)
+		return nil, err
+	}
+
+	release.Status = ReleaseActive
+	if err = a.releases.Update(ctx, release); err != nil {
+		return nil, fmt.Errorf("could not update release: %w", err)
+	}
+	return release, nil
+}
+
+// submitRelease submits the release with the formation of the app.
+func (a *Aura) submitRelease(ctx context.Context, app *App, release *Release) error {
+	formation, err := a.formation(ctx, app, release)
+	if err != nil {
+		return err
+	}
 
+	if err = a.sched.Submit(ctx, app, release, formation); err != nil {
+		return fmt.Errorf("could not submit release: %w", err)
+	}
+	return nil
+}
+
+// releasePhase runs the release command of a release, marking the
+// release as failed if it does not succeed.
+func (a *Aura) releasePhase(ctx context.Context, app *App, release *Release, cmd string) error {
+	run := &Run{
+		Command: cmd,
+		Attach:  true,
+	}
+	if err := a.sched.Run(ctx, app, release, run); err != nil {
+		release.Status = ReleaseFailed
+		release.Output = []byte(err.Error())
+		// The run error is more important than recording the failure.
+		_ = a.releases.Update(ctx, release)
+		return fmt.Errorf("could not run release phase: %w", err)
+	}
+
+	release.Output = run.Output
+	if run.ExitCode != nil && *run.ExitCode != 0 {
+		release.Status = ReleaseFailed
+	}
+	if err := a.releases.Update(ctx, release); err != nil {
+		return fmt.Errorf("could not update release: %w", err)
+	}
+
+	if release.Status == ReleaseFailed {
+		return ReleasePhaseError{Release: release, ExitCode: *run.ExitCode}
+	}
+	return nil
+}
+
+// prepareRelease sets the secrets and registry credentials
+// needed to schedule a release.
+func (a *Aura) prepareRelease(ctx context.Context, app *App, release *Release) error {
+	if err := a.loadSecrets(ctx, app, release); err != nil {
+		return err
+	}
+
+	release.Auth = nil
+	if release.Image == nil {
+		return nil
+	}
+	auth, err := a.registryAuth(ctx, app, release.Image.Registry)
+	if err != nil {
+		return err
+	}
+	release.Auth = auth
+	return nil
+}
+
+// releaseCommand returns the command of the release process in the
+// procfile, if there is one.
+func releaseCommand(procFile []byte) string {
+	procs, err := procfile.Parse(procFile)
+	if err != nil {
+		return ""
+	}
+
+	for _, proc := range procs {
+		if proc.Name == ReleaseProcess {
+			return proc.Command
+		}
+	}
+	return ""
+}
+
+// currentRelease returns the latest active release of an application.
+func (a *Aura) currentRelease(ctx context.Context, app *App) (*Release, error) {
+	release, err := a.releases.Latest(ctx, ReleasesQuery{App: app, Status: ReleaseActive})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("could not find current release: %w", err)
+		}
+	}
 	return release, nil
 }
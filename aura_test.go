@@ -4,11 +4,16 @@ import (
 	"context"
 	"errors"
 	"io"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hamba/logger/v2"
 	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/memory"
 	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/nrwiersma/aura/pkg/keyring"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -24,8 +29,8 @@ func TestAura_Create(t *testing.T) {
 	}{
 		{
 			name:     "handles creating an app",
-			appName:  "test app",
-			wantName: "test app",
+			appName:  "test-app",
+			wantName: "test-app",
 			wantErr:  require.NoError,
 		},
 		{
@@ -33,6 +38,26 @@ func TestAura_Create(t *testing.T) {
 			appName: "",
 			wantErr: require.Error,
 		},
+		{
+			name:    "handles name with spaces",
+			appName: "test app",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles name with uppercase letters",
+			appName: "Test-App",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles name starting with a hyphen",
+			appName: "-test-app",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles name that is too long",
+			appName: strings.Repeat("a", 51),
+			wantErr: require.Error,
+		},
 	}
 
 	for _, test := range tests {
@@ -40,8 +65,9 @@ func TestAura_Create(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			db := testDB(t)
 			reg := &mockRegistry{}
+			sched := memory.NewScheduler()
 
-			a := aura.New(db, reg)
+			a := aura.New(db, reg, sched)
 
 			app, err := a.Create(context.Background(), aura.CreateConfig{Name: test.appName})
 
@@ -55,15 +81,191 @@ func TestAura_Create(t *testing.T) {
 	}
 }
 
+func TestAura_CreateHandlesDuplicateName(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	_, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+
+	assert.ErrorIs(t, err, aura.ErrConflict)
+}
+
+func TestAura_CreateReusesDestroyedAppName(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app})
+	require.NoError(t, err)
+
+	got, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+
+	require.NoError(t, err)
+	assert.NotEqual(t, app.ID, got.ID)
+}
+
+func TestAura_Update(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	name, desc, owner, team, tier := "new-app", "A test app", "team-a", "a", "web"
+	app, err = a.Update(context.Background(), aura.UpdateConfig{
+		App:    app,
+		Labels: map[string]*string{"team": &team, "tier": &tier},
+	})
+	require.NoError(t, err)
+
+	got, err := a.Update(context.Background(), aura.UpdateConfig{
+		App:         app,
+		Name:        &name,
+		Description: &desc,
+		Owner:       &owner,
+		Labels:      map[string]*string{"tier": nil},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "new-app", got.Name)
+	assert.Equal(t, "A test app", got.Description)
+	assert.Equal(t, "team-a", got.Owner)
+	assert.Equal(t, aura.Labels{"team": "a"}, got.Labels)
+	assert.True(t, got.UpdatedAt.After(*got.CreatedAt))
+	found, err := a.App(context.Background(), aura.AppsQuery{IDOrName: "new-app"})
+	require.NoError(t, err)
+	assert.Equal(t, got, found)
+}
+
+func TestAura_UpdateKeepsConcurrentChanges(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	// Each change is made from the same, stale copy of the app.
+	policy := aura.ImagePolicy{ForbidLatest: true}
+	desc, team, tier := "A test app", "a", "web"
+	_, err = a.SetImagePolicy(context.Background(), aura.SetImagePolicyConfig{App: app, Policy: policy})
+	require.NoError(t, err)
+	_, err = a.Update(context.Background(), aura.UpdateConfig{App: app, Labels: map[string]*string{"team": &team}})
+	require.NoError(t, err)
+	_, err = a.Update(context.Background(), aura.UpdateConfig{App: app, Labels: map[string]*string{"tier": &tier}})
+	require.NoError(t, err)
+	_, err = a.Update(context.Background(), aura.UpdateConfig{App: app, Description: &desc})
+	require.NoError(t, err)
+
+	got, err := a.App(context.Background(), aura.AppsQuery{ID: app.ID})
+	require.NoError(t, err)
+	assert.Equal(t, policy, got.ImagePolicy)
+	assert.Equal(t, aura.Labels{"team": "a", "tier": "web"}, got.Labels)
+	assert.Equal(t, "A test app", got.Description)
+
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app})
+	require.NoError(t, err)
+
+	got, err = a.App(context.Background(), aura.AppsQuery{ID: app.ID, IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.NotNil(t, got.DeletedAt)
+	assert.Equal(t, policy, got.ImagePolicy)
+	assert.Equal(t, "A test app", got.Description)
+}
+
+func TestAura_UpdateHandlesDuplicateName(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	_, err := a.Create(context.Background(), aura.CreateConfig{Name: "test1-app"})
+	require.NoError(t, err)
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test2-app"})
+	require.NoError(t, err)
+
+	name := "test1-app"
+	_, err = a.Update(context.Background(), aura.UpdateConfig{App: app, Name: &name})
+
+	assert.ErrorIs(t, err, aura.ErrConflict)
+}
+
+func TestAura_UpdateHandlesValidationError(t *testing.T) {
+	name, badName, longOwner, label := "test-app", "Test App", strings.Repeat("a", 256), "foo"
+
+	tests := []struct {
+		name string
+		cfg  aura.UpdateConfig
+	}{
+		{
+			name: "handles no app",
+			cfg:  aura.UpdateConfig{Name: &name},
+		},
+		{
+			name: "handles invalid app",
+			cfg:  aura.UpdateConfig{App: &aura.App{}, Name: &name},
+		},
+		{
+			name: "handles no fields",
+			cfg:  aura.UpdateConfig{App: &aura.App{ID: "123"}},
+		},
+		{
+			name: "handles invalid name",
+			cfg:  aura.UpdateConfig{App: &aura.App{ID: "123"}, Name: &badName},
+		},
+		{
+			name: "handles owner that is too long",
+			cfg:  aura.UpdateConfig{App: &aura.App{ID: "123"}, Owner: &longOwner},
+		},
+		{
+			name: "handles invalid label name",
+			cfg:  aura.UpdateConfig{App: &aura.App{ID: "123"}, Labels: map[string]*string{"-foo": &label}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			reg := &mockRegistry{}
+			sched := memory.NewScheduler()
+
+			a := aura.New(db, reg, sched)
+
+			_, err := a.Update(context.Background(), test.cfg)
+
+			require.Error(t, err)
+			assert.ErrorAs(t, err, &aura.ValidationError{})
+		})
+	}
+}
+
 func TestAura_Apps(t *testing.T) {
 	db := testDB(t)
 	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
 
-	a := aura.New(db, reg)
+	a := aura.New(db, reg, sched)
 
-	app1, err := a.Create(context.Background(), aura.CreateConfig{Name: "test1 app"})
+	app1, err := a.Create(context.Background(), aura.CreateConfig{Name: "test1-app"})
 	require.NoError(t, err)
-	app2, err := a.Create(context.Background(), aura.CreateConfig{Name: "test2 app"})
+	app2, err := a.Create(context.Background(), aura.CreateConfig{Name: "test2-app"})
 	require.NoError(t, err)
 
 	apps, err := a.Apps(context.Background(), aura.AppsQuery{})
@@ -76,10 +278,11 @@ func TestAura_Apps(t *testing.T) {
 func TestAura_App(t *testing.T) {
 	db := testDB(t)
 	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
 
-	a := aura.New(db, reg)
+	a := aura.New(db, reg, sched)
 
-	want, err := a.Create(context.Background(), aura.CreateConfig{Name: "test app"})
+	want, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
 	require.NoError(t, err)
 
 	got, err := a.App(context.Background(), aura.AppsQuery{ID: want.ID})
@@ -91,25 +294,46 @@ func TestAura_App(t *testing.T) {
 func TestAura_AppByName(t *testing.T) {
 	db := testDB(t)
 	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
 
-	a := aura.New(db, reg)
+	a := aura.New(db, reg, sched)
 
-	want, err := a.Create(context.Background(), aura.CreateConfig{Name: "test app"})
+	want, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
 	require.NoError(t, err)
 
-	got, err := a.App(context.Background(), aura.AppsQuery{Name: "test app"})
+	got, err := a.App(context.Background(), aura.AppsQuery{Name: "test-app"})
 
 	require.NoError(t, err)
 	assert.Equal(t, want, got)
 }
 
+func TestAura_AppByIDOrName(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	want, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	gotByID, err := a.App(context.Background(), aura.AppsQuery{IDOrName: want.ID})
+	require.NoError(t, err)
+	gotByName, err := a.App(context.Background(), aura.AppsQuery{IDOrName: "test-app"})
+	require.NoError(t, err)
+
+	assert.Equal(t, want, gotByID)
+	assert.Equal(t, want, gotByName)
+}
+
 func TestAura_AppHandlesNoApp(t *testing.T) {
 	db := testDB(t)
 	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
 
-	a := aura.New(db, reg)
+	a := aura.New(db, reg, sched)
 
-	_, err := a.App(context.Background(), aura.AppsQuery{Name: "test app"})
+	_, err := a.App(context.Background(), aura.AppsQuery{Name: "test-app"})
 
 	require.Error(t, err)
 }
@@ -117,10 +341,13 @@ func TestAura_AppHandlesNoApp(t *testing.T) {
 func TestAura_Destroy(t *testing.T) {
 	db := testDB(t)
 	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
 
-	a := aura.New(db, reg)
+	a := aura.New(db, reg, sched)
 
-	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test11 app"})
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test11-app"})
+	require.NoError(t, err)
+	err = sched.Submit(context.Background(), app, &aura.Release{ID: "123", AppID: app.ID, Version: 1, Procfile: []byte("web: ./app")}, nil)
 	require.NoError(t, err)
 
 	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app})
@@ -128,28 +355,329 @@ func TestAura_Destroy(t *testing.T) {
 	require.NoError(t, err)
 	got, err := a.Apps(context.Background(), aura.AppsQuery{ID: app.ID})
 	assert.Len(t, got, 0)
+	procs, err := sched.Processes(context.Background(), app)
+	require.NoError(t, err)
+	assert.Len(t, procs, 0)
+}
+
+func TestAura_DestroyCancelsDeployments(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{block: true, resolving: make(chan struct{}, 1)}
+	sched := &mockScheduler{}
+	sched.On("Remove", mock.Anything).Return(nil)
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	running, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	runDeployWorkers(t, a)
+	<-reg.resolving
+
+	pending, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app})
+
+	require.NoError(t, err)
+	got, err := a.Deployment(context.Background(), aura.DeploymentsQuery{ID: running.ID})
+	require.NoError(t, err)
+	assert.Equal(t, aura.DeploymentCancelled, got.Status)
+	got, err = a.Deployment(context.Background(), aura.DeploymentsQuery{ID: pending.ID})
+	require.NoError(t, err)
+	assert.Equal(t, aura.DeploymentCancelled, got.Status)
+	sched.AssertNotCalled(t, "Submit", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAura_DeployHandlesDestroyedApp(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, memory.NewScheduler())
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app})
+	require.NoError(t, err)
+
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_ReleaseChangesWaitForDeploy(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(ctx context.Context, a *aura.Aura, app *aura.App) error
+	}{
+		{
+			name: "destroy",
+			fn: func(ctx context.Context, a *aura.Aura, app *aura.App) error {
+				return a.Destroy(ctx, aura.DestroyConfig{App: app})
+			},
+		},
+		{
+			name: "redeploy",
+			fn: func(ctx context.Context, a *aura.Aura, app *aura.App) error {
+				_, err := a.Redeploy(ctx, aura.RedeployConfig{App: app})
+				return err
+			},
+		},
+		{
+			name: "rollback",
+			fn: func(ctx context.Context, a *aura.Aura, app *aura.App) error {
+				_, err := a.Rollback(ctx, aura.RollbackConfig{App: app, Version: 1})
+				return err
+			},
+		},
+		{
+			name: "set vars",
+			fn: func(ctx context.Context, a *aura.Aura, app *aura.App) error {
+				val := "bar"
+				_, err := a.SetVars(ctx, aura.SetVarsConfig{App: app, Vars: map[string]*string{"FOO": &val}})
+				return err
+			},
+		},
+		{
+			name: "update formation",
+			fn: func(ctx context.Context, a *aura.Aura, app *aura.App) error {
+				qty := 2
+				_, err := a.UpdateFormation(ctx, aura.UpdateFormationConfig{App: app, Updates: []aura.FormationUpdate{{Process: "web", Quantity: &qty}}})
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			img, err := image.Decode("foo/bar:latest")
+			require.NoError(t, err)
+
+			db := testDB(t)
+			reg := &mockRegistry{block: true, resolving: make(chan struct{}, 1)}
+
+			a := aura.New(db, reg, &mockScheduler{})
+
+			app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+
+				_, _ = a.Deploy(ctx, aura.DeployConfig{App: app, Image: img})
+			}()
+			t.Cleanup(func() {
+				cancel()
+				<-done
+			})
+			<-reg.resolving
+
+			waitCtx, waitCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer waitCancel()
+
+			err = test.fn(waitCtx, a, app)
+
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		})
+	}
+}
+
+func TestAura_DestroyHandlesSchedulerError(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := &mockScheduler{}
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	sched.On("Remove", app).Return(errors.New("test"))
+
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app})
+
+	require.Error(t, err)
+	got, err := a.Apps(context.Background(), aura.AppsQuery{ID: app.ID})
+	assert.Len(t, got, 1)
 }
 
 func TestAura_DestroyHandlesBadConfig(t *testing.T) {
 	db := testDB(t)
 	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
 
-	a := aura.New(db, reg)
+	a := aura.New(db, reg, sched)
 
 	err := a.Destroy(context.Background(), aura.DestroyConfig{})
 
 	require.Error(t, err)
 }
 
-func TestAura_DestroyHandlesInvalidConfigApp(t *testing.T) {
-	db := testDB(t)
-	reg := &mockRegistry{}
+func TestAura_DestroyHandlesInvalidConfigApp(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	err := a.Destroy(context.Background(), aura.DestroyConfig{App: &aura.App{}})
+
+	require.Error(t, err)
+}
+
+func TestAura_AppsIncludesDeletedApps(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app1, err := a.Create(context.Background(), aura.CreateConfig{Name: "test1-app"})
+	require.NoError(t, err)
+	app2, err := a.Create(context.Background(), aura.CreateConfig{Name: "test2-app"})
+	require.NoError(t, err)
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app2})
+	require.NoError(t, err)
+
+	apps, err := a.Apps(context.Background(), aura.AppsQuery{})
+	require.NoError(t, err)
+	assert.Len(t, apps, 1)
+	apps, err = a.Apps(context.Background(), aura.AppsQuery{IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, apps, 2)
+	assert.Equal(t, app1.ID, apps[0].ID)
+	assert.Equal(t, app2.ID, apps[1].ID)
+	assert.NotNil(t, apps[1].DeletedAt)
+}
+
+func TestAura_Restore(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app})
+	require.NoError(t, err)
+	deleted, err := a.App(context.Background(), aura.AppsQuery{ID: app.ID, IncludeDeleted: true})
+	require.NoError(t, err)
+
+	got, err := a.Restore(context.Background(), aura.RestoreConfig{App: deleted})
+
+	require.NoError(t, err)
+	assert.Nil(t, got.DeletedAt)
+	found, err := a.App(context.Background(), aura.AppsQuery{IDOrName: "test-app"})
+	require.NoError(t, err)
+	assert.Equal(t, got, found)
+	procs, err := sched.Processes(context.Background(), got)
+	require.NoError(t, err)
+	assert.Len(t, procs, 1)
+}
+
+func TestAura_RestoreHandlesTakenName(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app})
+	require.NoError(t, err)
+	_, err = a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Restore(context.Background(), aura.RestoreConfig{App: app})
+
+	assert.ErrorIs(t, err, aura.ErrConflict)
+}
+
+func TestAura_RestoreHandlesReusedName(t *testing.T) {
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, memory.NewScheduler())
+
+	first, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: first})
+	require.NoError(t, err)
+	second, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: second})
+	require.NoError(t, err)
+	live, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	got, err := a.App(context.Background(), aura.AppsQuery{IDOrName: "test-app", IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, live.ID, got.ID)
+
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: live})
+	require.NoError(t, err)
+	got, err = a.App(context.Background(), aura.AppsQuery{IDOrName: "test-app", IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, live.ID, got.ID)
+
+	deleted, err := a.App(context.Background(), aura.AppsQuery{ID: first.ID, IncludeDeleted: true})
+	require.NoError(t, err)
+	restored, err := a.Restore(context.Background(), aura.RestoreConfig{App: deleted})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, restored.ID)
+	got, err = a.App(context.Background(), aura.AppsQuery{IDOrName: "test-app"})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, got.ID)
+}
+
+func TestAura_RestoreHandlesValidationError(t *testing.T) {
+	tests := []struct {
+		name string
+		app  *aura.App
+	}{
+		{
+			name: "handles no app",
+		},
+		{
+			name: "handles invalid app",
+			app:  &aura.App{},
+		},
+		{
+			name: "handles app that is not deleted",
+			app:  &aura.App{ID: "123", Name: "test-app"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			reg := &mockRegistry{}
+			sched := memory.NewScheduler()
 
-	a := aura.New(db, reg)
+			a := aura.New(db, reg, sched)
 
-	err := a.Destroy(context.Background(), aura.DestroyConfig{App: &aura.App{}})
+			_, err := a.Restore(context.Background(), aura.RestoreConfig{App: test.app})
 
-	require.Error(t, err)
+			require.Error(t, err)
+			assert.ErrorAs(t, err, &aura.ValidationError{})
+		})
+	}
 }
 
 func TestAura_Deploy(t *testing.T) {
@@ -159,6 +687,7 @@ func TestAura_Deploy(t *testing.T) {
 		resolveErr   error
 		procfile     []byte
 		extractErr   error
+		submitErr    error
 		wantImage    string
 		wantVersion  int
 		wantProcfile []byte
@@ -167,10 +696,10 @@ func TestAura_Deploy(t *testing.T) {
 		{
 			name:         "handles creating an app",
 			image:        "foo/bar:latest",
-			procfile:     []byte("test"),
-			wantImage:    "foo/bar:latest",
+			procfile:     []byte("web: ./app"),
+			wantImage:    "docker.io/foo/bar:latest",
 			wantVersion:  1,
-			wantProcfile: []byte("test"),
+			wantProcfile: []byte("web: ./app"),
 			wantErr:      require.NoError,
 		},
 		{
@@ -182,10 +711,17 @@ func TestAura_Deploy(t *testing.T) {
 		{
 			name:       "handles extracting procfile error",
 			image:      "foo/bar:latest",
-			procfile:   []byte("test"),
+			procfile:   []byte("web: ./app"),
 			extractErr: errors.New("test"),
 			wantErr:    require.Error,
 		},
+		{
+			name:      "handles submit error",
+			image:     "foo/bar:latest",
+			procfile:  []byte("web: ./app"),
+			submitErr: errors.New("test"),
+			wantErr:   require.Error,
+		},
 	}
 
 	for _, test := range tests {
@@ -201,9 +737,12 @@ func TestAura_Deploy(t *testing.T) {
 				reg.On("ExtractProcfile", img.String()).Return(test.procfile, test.extractErr)
 			}
 
-			a := aura.New(db, reg)
+			sched := &mockScheduler{}
+			sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(test.submitErr)
 
-			app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test app"})
+			a := aura.New(db, reg, sched)
+
+			app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
 
 			got, err := a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
 
@@ -214,11 +753,195 @@ func TestAura_Deploy(t *testing.T) {
 				assert.Equal(t, test.wantProcfile, got.Procfile)
 				assert.NotNil(t, app.ID)
 				assert.NotNil(t, app.CreatedAt)
+				sched.AssertCalled(t, "Submit", app, got, mock.Anything)
 			}
 		})
 	}
 }
 
+func TestAura_DeployRecordsPlatform(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest", Platform: image.Platform{OS: "linux", Architecture: "arm64"}}
+	resolved := image.Image{
+		Registry:    "docker.io",
+		Repository:  "foo/bar",
+		Digest:      "sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2",
+		IndexDigest: "sha256:a1b2c3",
+		Platform:    image.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+	}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(resolved, nil)
+	reg.On("ExtractProcfile", resolved.String()).Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	require.NoError(t, err)
+	got, err := a.Release(context.Background(), aura.ReleasesQuery{App: app, Version: 1})
+	require.NoError(t, err)
+	assert.Equal(t, resolved.String(), got.Image.String())
+	assert.Equal(t, "sha256:a1b2c3", got.IndexDigest)
+	require.NotNil(t, got.Platform)
+	assert.Equal(t, "linux/arm64/v8", got.Platform.String())
+	redeployed, err := a.Redeploy(context.Background(), aura.RedeployConfig{App: app})
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:a1b2c3", redeployed.IndexDigest)
+	assert.Equal(t, got.Platform, redeployed.Platform)
+}
+
+func TestAura_DeployRunsReleasePhase(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("release: ./migrate\nweb: ./app"), nil)
+	sched := &mockScheduler{}
+	sched.On("Run", mock.Anything, mock.Anything, mock.MatchedBy(func(run *aura.Run) bool {
+		return run.Command == "./migrate" && run.Attach
+	})).Run(func(args mock.Arguments) {
+		run := args.Get(2).(*aura.Run)
+		exitCode := 0
+		run.ExitCode = &exitCode
+		run.Output = []byte("migrated")
+	}).Return(nil)
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	got, err := a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	require.NoError(t, err)
+	assert.Equal(t, aura.ReleaseActive, got.Status)
+	assert.Equal(t, []byte("migrated"), got.Output)
+	sched.AssertCalled(t, "Submit", app, got, mock.MatchedBy(func(formation []*aura.Formation) bool {
+		// The release process is not part of the formation.
+		return len(formation) == 1 && formation[0].Process == "web"
+	}))
+}
+
+func TestAura_DeployHandlesFailedReleasePhase(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("release: ./migrate\nweb: ./app"), nil)
+	sched := &mockScheduler{}
+	sched.On("Run", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		run := args.Get(2).(*aura.Run)
+		exitCode := 1
+		run.ExitCode = &exitCode
+		run.Output = []byte("migration failed")
+	}).Return(nil)
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	var phaseErr aura.ReleasePhaseError
+	require.ErrorAs(t, err, &phaseErr)
+	assert.Equal(t, 1, phaseErr.ExitCode)
+	sched.AssertNotCalled(t, "Submit", mock.Anything, mock.Anything, mock.Anything)
+	release, err := a.Release(context.Background(), aura.ReleasesQuery{App: app, Version: 1})
+	require.NoError(t, err)
+	assert.Equal(t, aura.ReleaseFailed, release.Status)
+	assert.Equal(t, []byte("migration failed"), release.Output)
+	_, err = a.Redeploy(context.Background(), aura.RedeployConfig{App: app})
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+	_, err = a.Rollback(context.Background(), aura.RollbackConfig{App: app, Version: 1})
+	assert.ErrorAs(t, err, &aura.ValidationError{})
+}
+
+func TestAura_DeployHandlesReleasePhaseRunError(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("release: ./migrate\nweb: ./app"), nil)
+	sched := &mockScheduler{}
+	sched.On("Run", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("test"))
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	require.Error(t, err)
+	release, err := a.Release(context.Background(), aura.ReleasesQuery{App: app, Version: 1})
+	require.NoError(t, err)
+	assert.Equal(t, aura.ReleaseFailed, release.Status)
+}
+
+func TestAura_DeployHandlesSubmitError(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("test")).Once()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	require.Error(t, err)
+	release, err := a.Release(context.Background(), aura.ReleasesQuery{App: app, Version: 2})
+	require.NoError(t, err)
+	assert.Equal(t, aura.ReleaseFailed, release.Status)
+	current, err := a.Release(context.Background(), aura.ReleasesQuery{App: app, Status: aura.ReleaseActive})
+	require.NoError(t, err)
+	assert.Equal(t, 1, current.Version)
+}
+
+func TestAura_DeployHandlesInvalidProcfile(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", "docker.io/foo/bar:latest").Return([]byte("web: ./app\nweb: ./other"), nil)
+	sched := &mockScheduler{}
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	require.Error(t, err)
+	assert.ErrorAs(t, err, &aura.ValidationError{})
+	assert.EqualError(t, err, `invalid procfile: line 2: duplicate process "web"`)
+	got, err := a.Releases(context.Background(), aura.ReleasesQuery{App: app})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	sched.AssertNotCalled(t, "Submit", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestAura_DeployHandlesValidationError(t *testing.T) {
 	tests := []struct {
 		name string
@@ -242,8 +965,9 @@ func TestAura_DeployHandlesValidationError(t *testing.T) {
 
 			db := testDB(t)
 			reg := &mockRegistry{}
+			sched := memory.NewScheduler()
 
-			a := aura.New(db, reg)
+			a := aura.New(db, reg, sched)
 
 			_, err = a.Deploy(context.Background(), aura.DeployConfig{App: test.app, Image: img})
 
@@ -253,16 +977,17 @@ func TestAura_DeployHandlesValidationError(t *testing.T) {
 }
 
 func TestAura_Releases(t *testing.T) {
-	img := image.Image{Repository: "foo/bar", Tag: "latest"}
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
 
 	db := testDB(t)
 	reg := &mockRegistry{}
 	reg.On("Resolve", img).Return(img, nil)
-	reg.On("ExtractProcfile", "foo/bar:latest").Return([]byte("test"), nil)
+	reg.On("ExtractProcfile", "docker.io/foo/bar:latest").Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
 
-	a := aura.New(db, reg)
+	a := aura.New(db, reg, sched)
 
-	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test app"})
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
 	require.NoError(t, err)
 
 	release1, err := a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
@@ -281,16 +1006,17 @@ func TestAura_Releases(t *testing.T) {
 }
 
 func TestAura_Release(t *testing.T) {
-	img := image.Image{Repository: "foo/bar", Tag: "latest"}
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
 
 	db := testDB(t)
 	reg := &mockRegistry{}
 	reg.On("Resolve", img).Return(img, nil)
-	reg.On("ExtractProcfile", "foo/bar:latest").Return([]byte("test"), nil)
+	reg.On("ExtractProcfile", "docker.io/foo/bar:latest").Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
 
-	a := aura.New(db, reg)
+	a := aura.New(db, reg, sched)
 
-	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test app"})
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
 	require.NoError(t, err)
 	want, err := a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
 	require.NoError(t, err)
@@ -302,6 +1028,247 @@ func TestAura_Release(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestAura_Redeploy(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Digest: "sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil).Once()
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil).Once()
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	got, err := a.Redeploy(context.Background(), aura.RedeployConfig{App: app, Reason: "restart"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Version)
+	assert.Equal(t, img.String(), got.Image.String())
+	assert.Equal(t, []byte("web: ./app"), got.Procfile)
+	assert.Equal(t, "restart", got.Reason)
+	procs, err := sched.Processes(context.Background(), app)
+	require.NoError(t, err)
+	require.Len(t, procs, 1)
+	assert.Equal(t, 2, procs[0].Version)
+	reg.AssertExpectations(t)
+}
+
+func TestAura_RedeployDefaultsReason(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	got, err := a.Redeploy(context.Background(), aura.RedeployConfig{App: app})
+
+	require.NoError(t, err)
+	assert.Equal(t, aura.ReasonRedeploy, got.Reason)
+}
+
+func TestAura_RedeployHandlesNoRelease(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Redeploy(context.Background(), aura.RedeployConfig{App: app})
+
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_RedeployHandlesValidationError(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	_, err := a.Redeploy(context.Background(), aura.RedeployConfig{App: &aura.App{}})
+
+	require.Error(t, err)
+	assert.ErrorAs(t, err, &aura.ValidationError{})
+}
+
+func TestAura_Rollback(t *testing.T) {
+	img1 := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "1.0.0"}
+	img2 := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "2.0.0"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img1).Return(img1, nil).Once()
+	reg.On("ExtractProcfile", img1.String()).Return([]byte("web: ./app"), nil).Once()
+	reg.On("Resolve", img2).Return(img2, nil).Once()
+	reg.On("ExtractProcfile", img2.String()).Return([]byte("web: ./app2"), nil).Once()
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	foo, bar := "foo", "bar"
+	cfg, err := a.SetVars(context.Background(), aura.SetVarsConfig{App: app, Vars: map[string]*string{"FOO": &foo}})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img1})
+	require.NoError(t, err)
+	_, err = a.SetVars(context.Background(), aura.SetVarsConfig{App: app, Vars: map[string]*string{"FOO": &bar}})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img2})
+	require.NoError(t, err)
+
+	got, err := a.Rollback(context.Background(), aura.RollbackConfig{App: app, Version: 1})
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, got.Version)
+	assert.Equal(t, img1.String(), got.Image.String())
+	assert.Equal(t, []byte("web: ./app"), got.Procfile)
+	require.NotNil(t, got.ConfigID)
+	assert.Equal(t, cfg.ID, *got.ConfigID)
+	assert.Equal(t, aura.ReasonRollback, got.Reason)
+	require.NotNil(t, got.RollbackVersion)
+	assert.Equal(t, 1, *got.RollbackVersion)
+	release, err := a.Release(context.Background(), aura.ReleasesQuery{App: app, Version: 4})
+	require.NoError(t, err)
+	assert.Equal(t, 1, *release.RollbackVersion)
+	assert.Equal(t, aura.Vars{"FOO": "foo"}, release.Config.Vars)
+	procs, err := sched.Processes(context.Background(), app)
+	require.NoError(t, err)
+	require.Len(t, procs, 1)
+	assert.Equal(t, 4, procs[0].Version)
+	assert.Equal(t, "./app", procs[0].Command)
+	reg.AssertExpectations(t)
+}
+
+func TestAura_RollbackHandlesNoRelease(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Rollback(context.Background(), aura.RollbackConfig{App: app, Version: 1})
+
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_RollbackHandlesInactiveRelease(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+	}{
+		{
+			name:   "pending",
+			status: aura.ReleasePending,
+		},
+		{
+			name:   "failed",
+			status: aura.ReleaseFailed,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+			db := testDB(t)
+			reg := &mockRegistry{}
+			reg.On("Resolve", img).Return(img, nil)
+			reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+			sched := &mockScheduler{}
+			sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			a := aura.New(db, reg, sched)
+
+			app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+			require.NoError(t, err)
+			for i := 0; i < 2; i++ {
+				_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+				require.NoError(t, err)
+			}
+			err = db.Model(&aura.Release{}).Where("app_id = ? AND version = 1", app.ID).Update("status", test.status).Error
+			require.NoError(t, err)
+
+			_, err = a.Rollback(context.Background(), aura.RollbackConfig{App: app, Version: 1})
+
+			assert.ErrorAs(t, err, &aura.ValidationError{})
+			sched.AssertNumberOfCalls(t, "Submit", 2)
+		})
+	}
+}
+
+func TestAura_RollbackHandlesValidationError(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  aura.RollbackConfig
+	}{
+		{
+			name: "no app",
+			cfg:  aura.RollbackConfig{Version: 1},
+		},
+		{
+			name: "invalid app",
+			cfg:  aura.RollbackConfig{App: &aura.App{}, Version: 1},
+		},
+		{
+			name: "invalid version",
+			cfg:  aura.RollbackConfig{App: &aura.App{ID: "123"}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			reg := &mockRegistry{}
+			sched := memory.NewScheduler()
+
+			a := aura.New(db, reg, sched)
+
+			_, err := a.Rollback(context.Background(), test.cfg)
+
+			assert.ErrorAs(t, err, &aura.ValidationError{})
+		})
+	}
+}
+
+func runDeployWorkers(t *testing.T, a *aura.Aura) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		a.RunDeployWorkers(ctx, 2)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
 func testDB(t *testing.T) *aura.DB {
 	t.Helper()
 
@@ -310,25 +1277,108 @@ func testDB(t *testing.T) *aura.DB {
 	db, err := aura.NewDB(sqlite.Open("file::memory:"), log)
 	require.NoError(t, err)
 
+	// The in-memory database only exists on a single connection.
+	sqlDB, err := db.DB.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	// Cascading deletes need foreign keys to be enforced.
+	err = db.Exec("PRAGMA foreign_keys = ON").Error
+	require.NoError(t, err)
+
 	err = db.Migrate()
 	require.NoError(t, err)
 
 	return db
 }
 
+const (
+	testKey1 = "key1:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	testKey2 = "key2:ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+func testKeyring(t *testing.T, keys ...string) *keyring.Keyring {
+	t.Helper()
+
+	ks := make([]keyring.Key, 0, len(keys))
+	for _, s := range keys {
+		key, err := keyring.ParseKey(s)
+		require.NoError(t, err)
+		ks = append(ks, key)
+	}
+
+	kr, err := keyring.New(ks...)
+	require.NoError(t, err)
+	return kr
+}
+
 type mockRegistry struct {
 	mock.Mock
+
+	pulls     []aura.PullProgress
+	block     bool
+	resolving chan struct{}
+
+	authMu sync.Mutex
+	auths  []*aura.RegistryAuth
 }
 
-func (m *mockRegistry) Resolve(_ context.Context, img image.Image) (image.Image, error) {
+func (m *mockRegistry) Resolve(ctx context.Context, img image.Image, auth *aura.RegistryAuth, progress func(aura.PullProgress)) (image.Image, error) {
+	m.recordAuth(auth)
+	for _, p := range m.pulls {
+		progress(p)
+	}
+	if m.resolving != nil {
+		m.resolving <- struct{}{}
+	}
+	if m.block {
+		<-ctx.Done()
+		return img, ctx.Err()
+	}
+
 	args := m.Called(img)
 	return args.Get(0).(image.Image), args.Error(1)
 }
 
-func (m *mockRegistry) ExtractProcfile(_ context.Context, img string) ([]byte, error) {
+func (m *mockRegistry) recordAuth(auth *aura.RegistryAuth) {
+	m.authMu.Lock()
+	defer m.authMu.Unlock()
+
+	m.auths = append(m.auths, auth)
+}
+
+func (m *mockRegistry) ExtractProcfile(_ context.Context, img string, auth *aura.RegistryAuth) ([]byte, error) {
+	m.recordAuth(auth)
 	args := m.Called(img)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]byte), args.Error(1)
 }
+
+type mockScheduler struct {
+	mock.Mock
+}
+
+func (m *mockScheduler) Submit(_ context.Context, app *aura.App, release *aura.Release, formation []*aura.Formation) error {
+	args := m.Called(app, release, formation)
+	return args.Error(0)
+}
+
+func (m *mockScheduler) Remove(_ context.Context, app *aura.App) error {
+	args := m.Called(app)
+	return args.Error(0)
+}
+
+func (m *mockScheduler) Processes(_ context.Context, app *aura.App) ([]*aura.Process, error) {
+	args := m.Called(app)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*aura.Process), args.Error(1)
+}
+
+func (m *mockScheduler) Run(_ context.Context, app *aura.App, release *aura.Release, run *aura.Run) error {
+	args := m.Called(app, release, run)
+	return args.Error(0)
+}
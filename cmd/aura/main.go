@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"runtime/debug"
 	"syscall"
+	"time"
 
 	"github.com/ettle/strcase"
 	"github.com/hamba/cmd/v2"
@@ -18,6 +19,27 @@ const (
 	flagAddr          = "addr"
 	flagDBDSN         = "db.dsn"
 	flagDBAutoMigrate = "db.auto-migrate"
+
+	flagRegistry         = "registry"
+	flagRegistryConfig   = "registry.config"
+	flagRegistryAuth     = "registry.auth"
+	flagRegistryPlatform = "registry.platform"
+	flagRegistryRealm    = "registry.token-realm"
+
+	flagDeployWorkers = "deploy.workers"
+	flagDeployTimeout = "deploy.timeout"
+
+	flagIdempotencyWindow = "idempotency.window"
+
+	flagAppsRetention = "apps.retention"
+
+	flagPolicyRequireDigest = "policy.require-digest"
+	flagPolicyForbidLatest  = "policy.forbid-latest"
+	flagPolicyAllow         = "policy.allow"
+	flagPolicyDeny          = "policy.deny"
+
+	flagSecretsKey     = "secrets.key"
+	flagSecretsKeyFile = "secrets.key-file"
 )
 
 var version = "¯\\_(ツ)_/¯"
@@ -41,6 +63,86 @@ var flags = cmd.Flags{
 		Value:   true,
 		EnvVars: []string{strcase.ToSNAKE(flagDBAutoMigrate)},
 	},
+	&cli.StringFlag{
+		Name:    flagRegistry,
+		Usage:   "The image registry implementation, either docker (using the docker daemon) or oci",
+		Value:   "docker",
+		EnvVars: []string{strcase.ToSNAKE(flagRegistry)},
+	},
+	&cli.StringFlag{
+		Name:    flagRegistryConfig,
+		Usage:   "The path to a docker config.json file containing registry credentials",
+		EnvVars: []string{strcase.ToSNAKE(flagRegistryConfig)},
+	},
+	&cli.StringSliceFlag{
+		Name:    flagRegistryAuth,
+		Usage:   "The credentials of a registry in the form registry=username:password. These take precedence over the config file",
+		EnvVars: []string{strcase.ToSNAKE(flagRegistryAuth)},
+	},
+	&cli.StringFlag{
+		Name:    flagRegistryPlatform,
+		Usage:   "The platform multi-platform images are resolved to, in the form os/arch[/variant]. Defaults to linux/amd64 for the oci registry, and the daemon platform for docker",
+		EnvVars: []string{strcase.ToSNAKE(flagRegistryPlatform)},
+	},
+	&cli.StringSliceFlag{
+		Name:    flagRegistryRealm,
+		Usage:   "A token service trusted with the credentials of a registry in the form registry=realm-host. By default, credentials are only sent to token services on the registry host and Docker Hub",
+		EnvVars: []string{strcase.ToSNAKE(flagRegistryRealm)},
+	},
+	&cli.IntFlag{
+		Name:    flagDeployWorkers,
+		Usage:   "The number of workers processing deployments",
+		Value:   2,
+		EnvVars: []string{strcase.ToSNAKE(flagDeployWorkers)},
+	},
+	&cli.DurationFlag{
+		Name:    flagDeployTimeout,
+		Usage:   "The maximum duration of a deploy. Zero disables the timeout",
+		Value:   15 * time.Minute,
+		EnvVars: []string{strcase.ToSNAKE(flagDeployTimeout)},
+	},
+	&cli.DurationFlag{
+		Name:    flagIdempotencyWindow,
+		Usage:   "The duration idempotency keys are kept for",
+		Value:   24 * time.Hour,
+		EnvVars: []string{strcase.ToSNAKE(flagIdempotencyWindow)},
+	},
+	&cli.DurationFlag{
+		Name:    flagAppsRetention,
+		Usage:   "The duration deleted apps are kept for before being purged. Zero disables purging",
+		Value:   30 * 24 * time.Hour,
+		EnvVars: []string{strcase.ToSNAKE(flagAppsRetention)},
+	},
+	&cli.BoolFlag{
+		Name:    flagPolicyRequireDigest,
+		Usage:   "Determines if deployed images must be referenced by digest",
+		EnvVars: []string{strcase.ToSNAKE(flagPolicyRequireDigest)},
+	},
+	&cli.BoolFlag{
+		Name:    flagPolicyForbidLatest,
+		Usage:   "Determines if deploying the latest tag is forbidden",
+		EnvVars: []string{strcase.ToSNAKE(flagPolicyForbidLatest)},
+	},
+	&cli.StringSliceFlag{
+		Name:    flagPolicyAllow,
+		Usage:   "The glob patterns of registries or repositories images can be deployed from, e.g. ghcr.io/my-org/*",
+		EnvVars: []string{strcase.ToSNAKE(flagPolicyAllow)},
+	},
+	&cli.StringSliceFlag{
+		Name:    flagPolicyDeny,
+		Usage:   "The glob patterns of registries or repositories images cannot be deployed from. These take precedence over policy.allow",
+		EnvVars: []string{strcase.ToSNAKE(flagPolicyDeny)},
+	},
+	&cli.StringSliceFlag{
+		Name:    flagSecretsKey,
+		Usage:   "The master keys used to encrypt secrets, in the form id:base64-key. The first key is the primary key",
+		EnvVars: []string{strcase.ToSNAKE(flagSecretsKey)},
+	},
+	&cli.StringFlag{
+		Name:    flagSecretsKeyFile,
+		Usage:   "The file containing the master keys used to encrypt secrets, one per line. Keys are used after any secrets.key keys",
+		EnvVars: []string{strcase.ToSNAKE(flagSecretsKeyFile)},
+	},
 }.Merge(cmd.LogFlags, cmd.StatsFlags)
 
 func main() {
@@ -60,6 +162,13 @@ func realMain(args []string) (code int) {
 	app.Version = version
 	app.Flags = flags
 	app.Action = runServer
+	app.Commands = []*cli.Command{
+		{
+			Name:   "reencrypt-secrets",
+			Usage:  "Re-encrypt all secret data keys with the primary master key",
+			Action: runReencryptSecrets,
+		},
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/docker"
+	"github.com/nrwiersma/aura/oci"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/urfave/cli/v2"
+)
+
+func newRegistry(c *cli.Context) (aura.Registry, error) {
+	var platform image.Platform
+	if s := c.String(flagRegistryPlatform); s != "" {
+		var err error
+		if platform, err = image.ParsePlatform(s); err != nil {
+			return nil, err
+		}
+	}
+
+	type tokenRealm struct {
+		registry string
+		host     string
+	}
+	var realms []tokenRealm
+	for _, s := range c.StringSlice(flagRegistryRealm) {
+		reg, host, ok := strings.Cut(s, "=")
+		if !ok || reg == "" || host == "" {
+			return nil, fmt.Errorf("invalid registry token realm %q, expected registry=realm-host", s)
+		}
+		realms = append(realms, tokenRealm{registry: reg, host: host})
+	}
+
+	switch kind := c.String(flagRegistry); kind {
+	case "docker":
+		opts := []docker.Option{docker.WithPlatform(platform)}
+		for _, realm := range realms {
+			opts = append(opts, docker.WithTokenRealm(realm.registry, realm.host))
+		}
+		return docker.NewRegistry(opts...)
+	case "oci":
+		var opts []oci.Option
+		if !platform.IsZero() {
+			opts = append(opts, oci.WithPlatform(platform))
+		}
+		for _, realm := range realms {
+			opts = append(opts, oci.WithTokenRealm(realm.registry, realm.host))
+		}
+		return oci.NewRegistry(opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown registry %q", kind)
+	}
+}
+
+func newRegistryAuths(c *cli.Context) (map[string]aura.RegistryAuth, error) {
+	auths := map[string]aura.RegistryAuth{}
+
+	if path := c.String(flagRegistryConfig); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open registry config: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		if auths, err = aura.ParseDockerConfig(f); err != nil {
+			return nil, fmt.Errorf("could not parse registry config: %w", err)
+		}
+	}
+
+	for _, s := range c.StringSlice(flagRegistryAuth) {
+		reg, creds, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid registry auth for %q, expected registry=username:password", reg)
+		}
+		user, pass, ok := strings.Cut(creds, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid registry auth for %q, expected registry=username:password", reg)
+		}
+		auths[aura.RegistryHost(reg)] = aura.RegistryAuth{Username: user, Password: pass}
+	}
+
+	return auths, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hamba/cmd/v2"
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/keyring"
+	"github.com/urfave/cli/v2"
+)
+
+// newKeyring returns the keyring configured from the key flags,
+// or nil if no keys are configured.
+//
+// Keys given as flags come before keys in the key file,
+// the first key being the primary key.
+func newKeyring(c *cli.Context) (*keyring.Keyring, error) {
+	var keys []keyring.Key
+	for _, s := range c.StringSlice(flagSecretsKey) {
+		key, err := keyring.ParseKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse secrets key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if path := c.String(flagSecretsKeyFile); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open secrets key file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		fileKeys, err := keyring.ParseKeys(f)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse secrets key file: %w", err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	kr, err := keyring.New(keys...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create keyring: %w", err)
+	}
+	return kr, nil
+}
+
+func runReencryptSecrets(c *cli.Context) error {
+	ctx := c.Context
+
+	log, err := cmd.NewLogger(c)
+	if err != nil {
+		return err
+	}
+	log = log.With(lctx.Str("app", "aura"))
+
+	keys, err := newKeyring(c)
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		return errors.New("no secrets keys configured")
+	}
+
+	db, err := aura.OpenDB(c.String(flagDBDSN), log)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool(flagDBAutoMigrate) {
+		if err = db.Migrate(); err != nil {
+			return err
+		}
+	}
+
+	// Re-encryption only needs the database.
+	app := aura.New(db, nil, nil, aura.WithKeyring(keys))
+
+	n, err := app.ReencryptSecrets(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Re-encrypted secrets", lctx.Int("count", n), lctx.Str("key", keys.Primary()))
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -42,12 +44,67 @@ func runServer(c *cli.Context) error {
 		}
 	}
 
-	reg, err := docker.NewRegistry()
+	reg, err := newRegistry(c)
 	if err != nil {
 		return err
 	}
 
-	app := aura.New(db, reg)
+	sched, err := docker.NewScheduler()
+	if err != nil {
+		return err
+	}
+
+	keys, err := newKeyring(c)
+	if err != nil {
+		return err
+	}
+	auths, err := newRegistryAuths(c)
+	if err != nil {
+		return err
+	}
+	policy := aura.ImagePolicy{
+		RequireDigest: c.Bool(flagPolicyRequireDigest),
+		ForbidLatest:  c.Bool(flagPolicyForbidLatest),
+		Allow:         c.StringSlice(flagPolicyAllow),
+		Deny:          c.StringSlice(flagPolicyDeny),
+	}
+	if err = policy.Validate(); err != nil {
+		return fmt.Errorf("invalid image policy: %w", err)
+	}
+
+	var opts []aura.Option
+	if keys != nil {
+		opts = append(opts, aura.WithKeyring(keys))
+	} else {
+		log.Warn("No secrets keys configured, secrets are disabled")
+	}
+
+	opts = append(opts,
+		aura.WithDeployTimeout(c.Duration(flagDeployTimeout)),
+		aura.WithIdempotencyWindow(c.Duration(flagIdempotencyWindow)),
+		aura.WithAppRetention(c.Duration(flagAppsRetention)),
+		aura.WithRegistryAuths(auths),
+		aura.WithImagePolicy(policy),
+		aura.WithLogger(log),
+	)
+	app := aura.New(db, reg, sched, opts...)
+
+	workers := c.Int(flagDeployWorkers)
+	if workers < 1 {
+		return errors.New("at least one deploy worker is required")
+	}
+	workersDone := make(chan struct{})
+	go func() {
+		defer close(workersDone)
+
+		app.RunDeployWorkers(ctx, workers)
+	}()
+	purgerDone := make(chan struct{})
+	go func() {
+		defer close(purgerDone)
+
+		app.RunAppPurger(ctx)
+	}()
 
 	apiSrv := api.New(app, log, stats)
 
@@ -73,6 +130,8 @@ func runServer(c *cli.Context) error {
 	if err = srv.Shutdown(10 * time.Second); err != nil {
 		log.Error("Failed to shutdown server", lctx.Error("error", err))
 	}
+	<-workersDone
+	<-purgerDone
 
 	return nil
 }
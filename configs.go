@@ -0,0 +1,251 @@
+package aura
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"gorm.io/gorm"
+)
+
+// Vars contains environment variables.
+type Vars map[string]string
+
+// Scan decodes vars from a database field.
+func (v *Vars) Scan(src any) error {
+	var b []byte
+	switch val := src.(type) {
+	case string:
+		b = []byte(val)
+	case []byte:
+		b = val
+	default:
+		return nil
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// Value encodes vars into a database field.
+func (v Vars) Value() (driver.Value, error) {
+	if v == nil {
+		v = Vars{}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return driver.Value(string(b)), nil
+}
+
+// Config contains a version of the config vars of an application.
+type Config struct {
+	ID        string
+	AppID     string
+	App       *App
+	Version   int
+	Vars      Vars
+	CreatedAt *time.Time
+}
+
+// BeforeCreate is a pre-creation hook.
+func (c *Config) BeforeCreate(_ *gorm.DB) error {
+	c.ID = ksuid.New().String()
+
+	now := time.Now().UTC()
+	c.CreatedAt = &now
+
+	return nil
+}
+
+type configService struct {
+	db *DB
+}
+
+func (s *configService) First(ctx context.Context, scope scope) (*Config, error) {
+	var cfg *Config
+	scope = composedScope{order("version DESC"), scope}
+	// Take is used as First orders by the primary key before the scopes are applied.
+	return cfg, s.db.WithContext(ctx).Scopes(scope.scope).Take(&cfg).Error
+}
+
+func (s *configService) Create(ctx context.Context, cfg *Config) (*Config, error) {
+	tx := s.db.WithContext(ctx).Begin()
+	defer func() { _ = tx.Rollback() }()
+
+	// Lock the app for updates, so the version number is unique in a race.
+	if err := lockApp(tx, cfg.AppID); err != nil {
+		return nil, fmt.Errorf("locking app: %w", err)
+	}
+
+	ver, err := s.currentVersion(tx, cfg.AppID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// It is ok if it doesn't exist, we will use version 1 then.
+		default:
+			return nil, fmt.Errorf("getting latest config version: %w", err)
+		}
+	}
+	cfg.Version = ver + 1
+
+	if err = tx.Create(cfg).Error; err != nil {
+		return nil, fmt.Errorf("creating config: %w", err)
+	}
+
+	return cfg, tx.Commit().Error
+}
+
+func (s *configService) currentVersion(tx *gorm.DB, appID string) (int, error) {
+	var cfg *Config
+	return cfg.Version, tx.Where("app_id = ?", appID).Order("version DESC").First(&cfg).Error
+}
+
+// ConfigsQuery contains a config query.
+type ConfigsQuery struct {
+	App *App
+
+	Version int
+}
+
+func (q ConfigsQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.App != nil {
+		scope = append(scope, fieldEquals("app_id", q.App.ID))
+	}
+
+	if q.Version > 0 {
+		scope = append(scope, fieldEquals("version", q.Version))
+	}
+
+	return scope.scope(db)
+}
+
+// Config returns the latest config matching the query.
+func (a *Aura) Config(ctx context.Context, q ConfigsQuery) (*Config, error) {
+	cfg, err := a.configs.First(ctx, q)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("could not find config: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+func (a *Aura) latestConfig(ctx context.Context, app *App) (*Config, error) {
+	cfg, err := a.Config(ctx, ConfigsQuery{App: app})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+func configID(cfg *Config) *string {
+	if cfg == nil {
+		return nil
+	}
+	return &cfg.ID
+}
+
+var varNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SetVarsConfig contains config vars update configuration.
+type SetVarsConfig struct {
+	App *App
+
+	// Vars contains the vars to set. A nil value unsets the var.
+	Vars map[string]*string
+}
+
+// Validate validates a set vars configuration.
+func (c SetVarsConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if len(c.Vars) == 0 {
+		return errors.New("at least one var is required")
+	}
+	for k := range c.Vars {
+		if !varNameRegexp.MatchString(k) {
+			return fmt.Errorf("invalid var name %q", k)
+		}
+	}
+
+	return nil
+}
+
+// SetVars sets and unsets config vars, creating a new config version.
+//
+// If the current release cannot be redeployed with the new config,
+// the saved config is returned with a RedeployError.
+func (a *Aura) SetVars(ctx context.Context, cfg SetVarsConfig) (*Config, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+
+	unlock, err := a.lockActiveApp(ctx, cfg.App, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	current, err := a.latestConfig(ctx, cfg.App)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := Vars{}
+	if current != nil {
+		for k, v := range current.Vars {
+			vars[k] = v
+		}
+	}
+	for k, v := range cfg.Vars {
+		if v == nil {
+			delete(vars, k)
+			continue
+		}
+		vars[k] = *v
+	}
+
+	appCfg, err := a.configs.Create(ctx, &Config{
+		AppID: cfg.App.ID,
+		Vars:  vars,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create config: %w", err)
+	}
+
+	// Apply the new config to the running release, if there is one.
+	release, err := a.currentRelease(ctx, cfg.App)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return appCfg, nil
+		default:
+			return nil, err
+		}
+	}
+	if _, err = a.redeploy(ctx, cfg.App, release, ReasonConfigChange); err != nil {
+		return appCfg, RedeployError{Err: err}
+	}
+
+	return appCfg, nil
+}
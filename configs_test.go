@@ -0,0 +1,190 @@
+package aura_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/memory"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAura_SetVars(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	foo, bar := "foo", "bar"
+	_, err = a.SetVars(context.Background(), aura.SetVarsConfig{
+		App:  app,
+		Vars: map[string]*string{"FOO": &foo, "BAR": &bar},
+	})
+	require.NoError(t, err)
+
+	got, err := a.SetVars(context.Background(), aura.SetVarsConfig{
+		App:  app,
+		Vars: map[string]*string{"FOO": &bar, "BAR": nil},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Version)
+	assert.Equal(t, aura.Vars{"FOO": "bar"}, got.Vars)
+	cfg, err := a.Config(context.Background(), aura.ConfigsQuery{App: app})
+	require.NoError(t, err)
+	assert.Equal(t, got, cfg)
+	cfg, err = a.Config(context.Background(), aura.ConfigsQuery{App: app, Version: 1})
+	require.NoError(t, err)
+	assert.Equal(t, aura.Vars{"FOO": "foo", "BAR": "bar"}, cfg.Vars)
+}
+
+func TestAura_SetVarsHandlesValidationError(t *testing.T) {
+	foo := "foo"
+
+	tests := []struct {
+		name string
+		app  *aura.App
+		vars map[string]*string
+	}{
+		{
+			name: "handles no app",
+			vars: map[string]*string{"FOO": &foo},
+		},
+		{
+			name: "handles invalid app",
+			app:  &aura.App{},
+			vars: map[string]*string{"FOO": &foo},
+		},
+		{
+			name: "handles no vars",
+			app:  &aura.App{ID: "123"},
+		},
+		{
+			name: "handles invalid var name",
+			app:  &aura.App{ID: "123"},
+			vars: map[string]*string{"1FOO": &foo},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			reg := &mockRegistry{}
+			sched := memory.NewScheduler()
+
+			a := aura.New(db, reg, sched)
+
+			_, err := a.SetVars(context.Background(), aura.SetVarsConfig{App: test.app, Vars: test.vars})
+
+			require.Error(t, err)
+			assert.ErrorAs(t, err, &aura.ValidationError{})
+		})
+	}
+}
+
+func TestAura_ConfigHandlesNoConfig(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Config(context.Background(), aura.ConfigsQuery{App: app})
+
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_DeployWithConfig(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", "docker.io/foo/bar:latest").Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	foo := "foo"
+	cfg, err := a.SetVars(context.Background(), aura.SetVarsConfig{App: app, Vars: map[string]*string{"FOO": &foo}})
+	require.NoError(t, err)
+
+	got, err := a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	require.NoError(t, err)
+	assert.Equal(t, &cfg.ID, got.ConfigID)
+	assert.Equal(t, cfg, got.Config)
+	release, err := a.Release(context.Background(), aura.ReleasesQuery{App: app, Version: got.Version})
+	require.NoError(t, err)
+	assert.Equal(t, cfg.ID, release.Config.ID)
+	assert.Equal(t, aura.Vars{"FOO": "foo"}, release.Config.Vars)
+}
+
+func TestAura_SetVarsRedeploysCurrentRelease(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	foo := "foo"
+	cfg, err := a.SetVars(context.Background(), aura.SetVarsConfig{App: app, Vars: map[string]*string{"FOO": &foo}})
+
+	require.NoError(t, err)
+	got, err := a.Release(context.Background(), aura.ReleasesQuery{App: app, Version: 2})
+	require.NoError(t, err)
+	assert.Equal(t, aura.ReasonConfigChange, got.Reason)
+	assert.Equal(t, &cfg.ID, got.ConfigID)
+}
+
+func TestAura_SetVarsHandlesRedeployError(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("test"))
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	foo := "foo"
+	cfg, err := a.SetVars(context.Background(), aura.SetVarsConfig{App: app, Vars: map[string]*string{"FOO": &foo}})
+
+	require.ErrorAs(t, err, &aura.RedeployError{})
+	require.NotNil(t, cfg)
+	assert.Equal(t, aura.Vars{"FOO": "foo"}, cfg.Vars)
+	got, err := a.Config(context.Background(), aura.ConfigsQuery{App: app})
+	require.NoError(t, err)
+	assert.Equal(t, cfg.ID, got.ID)
+}
@@ -39,6 +39,8 @@ func NewDB(dialect gorm.Dialector, log *logger.Logger) (*DB, error) {
 			IgnoreRecordNotFoundError: false,
 			Colorful:                  false,
 		}),
+		// Match the UTC timestamps set in the model hooks.
+		NowFunc: func() time.Time { return time.Now().UTC() },
 	})
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to db: %w", err)
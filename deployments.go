@@ -0,0 +1,660 @@
+package aura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	lctx "github.com/hamba/logger/v2/ctx"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/segmentio/ksuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Deployment statuses.
+const (
+	DeploymentPending    = "pending"
+	DeploymentResolving  = "resolving"
+	DeploymentExtracting = "extracting"
+	DeploymentReleasing  = "releasing"
+	DeploymentSucceeded  = "succeeded"
+	DeploymentFailed     = "failed"
+	DeploymentCancelled  = "cancelled"
+)
+
+// runningDeploymentStatuses are the statuses of a deployment being deployed.
+var runningDeploymentStatuses = []string{DeploymentResolving, DeploymentExtracting, DeploymentReleasing}
+
+// Running deployments record a heartbeat while they are deployed. A running
+// deployment without a heartbeat within the lease was abandoned by its server.
+const (
+	deployHeartbeatInterval = 10 * time.Second
+	deployLease             = time.Minute
+)
+
+// Deployment contains the info of an asynchronous deployment.
+type Deployment struct {
+	ID        string
+	AppID     string
+	App       *App
+	Image     string
+	Platform  string
+	Status    string
+	Error     string
+	ReleaseID *string
+	Release   *Release
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+	// HeartbeatAt is the last time the server deploying
+	// the deployment reported it is still deploying it.
+	HeartbeatAt *time.Time
+}
+
+// BeforeCreate is a pre-creation hook.
+func (d *Deployment) BeforeCreate(_ *gorm.DB) error {
+	d.ID = ksuid.New().String()
+
+	now := time.Now().UTC()
+	d.CreatedAt = &now
+	d.UpdatedAt = &now
+
+	return nil
+}
+
+// BeforeUpdate is a pre-update hook.
+func (d *Deployment) BeforeUpdate(_ *gorm.DB) error {
+	now := time.Now().UTC()
+	d.UpdatedAt = &now
+
+	return nil
+}
+
+// Done determines if the deployment is finished.
+func (d *Deployment) Done() bool {
+	switch d.Status {
+	case DeploymentSucceeded, DeploymentFailed, DeploymentCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// PullProgress contains the progress of an image pull.
+type PullProgress struct {
+	ID       string
+	Status   string
+	Progress string
+	Current  int64
+	Total    int64
+}
+
+// DeployEvent contains a deploy progress event.
+//
+// A step event has only a status, while a pull event contains
+// the progress of the image pull in the resolving step.
+type DeployEvent struct {
+	Status string
+	Pull   *PullProgress
+}
+
+var deploymentsPreload = preload("App", "Release")
+
+type deploymentService struct {
+	db *DB
+}
+
+func (s *deploymentService) First(ctx context.Context, scope scope) (*Deployment, error) {
+	var deployment *Deployment
+	scope = composedScope{deploymentsPreload, scope}
+	return deployment, s.db.WithContext(ctx).Scopes(scope.scope).First(&deployment).Error
+}
+
+func (s *deploymentService) Create(ctx context.Context, deployment *Deployment) (*Deployment, error) {
+	return deployment, s.db.WithContext(ctx).Omit(clause.Associations).Create(deployment).Error
+}
+
+func (s *deploymentService) Update(ctx context.Context, deployment *Deployment) error {
+	// The heartbeat is only recorded by Heartbeat, so it is never overwritten.
+	return s.db.WithContext(ctx).Omit(clause.Associations, "heartbeat_at").Save(deployment).Error
+}
+
+// Heartbeat records that the running deployment is still being deployed.
+func (s *deploymentService) Heartbeat(ctx context.Context, id string) error {
+	return s.db.WithContext(ctx).Model(&Deployment{}).
+		Where("id = ? AND status IN ?", id, runningDeploymentStatuses).
+		UpdateColumn("heartbeat_at", time.Now().UTC()).Error
+}
+
+// failAbandoned fails the running deployments without a heartbeat
+// within the lease, as the server deploying them stopped.
+func failAbandoned(db *gorm.DB) error {
+	now := time.Now().UTC()
+	return db.Model(&Deployment{}).
+		Where("status IN ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", runningDeploymentStatuses, now.Add(-deployLease)).
+		Updates(map[string]any{"status": DeploymentFailed, "error": "the deployment was abandoned", "updated_at": now}).Error
+}
+
+// CreateExclusive creates the deployment if the app has no unfinished
+// deployments, otherwise ErrConflict is returned.
+func (s *deploymentService) CreateExclusive(ctx context.Context, deployment *Deployment) error {
+	tx := s.db.WithContext(ctx).Begin()
+	defer func() { _ = tx.Rollback() }()
+
+	// Lock the app for updates, so only one deployment is created in a race.
+	if err := lockApp(tx, deployment.AppID); err != nil {
+		return fmt.Errorf("locking app: %w", err)
+	}
+
+	if err := failAbandoned(tx); err != nil {
+		return fmt.Errorf("failing abandoned deployments: %w", err)
+	}
+
+	var count int64
+	err := tx.Model(&Deployment{}).
+		Where("app_id = ? AND status IN ?", deployment.AppID, append([]string{DeploymentPending}, runningDeploymentStatuses...)).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("counting unfinished deployments: %w", err)
+	}
+	if count > 0 {
+		return ErrConflict
+	}
+
+	if err = tx.Omit(clause.Associations).Create(deployment).Error; err != nil {
+		return fmt.Errorf("creating deployment: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// Claim claims the oldest pending deployment, moving it to the resolving status.
+// If there are no pending deployments, gorm.ErrRecordNotFound is returned.
+func (s *deploymentService) Claim(ctx context.Context) (*Deployment, error) {
+	db := s.db.WithContext(ctx)
+
+	// Abandoned deployments would keep their apps out of the queue.
+	if err := failAbandoned(db); err != nil {
+		return nil, fmt.Errorf("failing abandoned deployments: %w", err)
+	}
+
+	for {
+		var deployment *Deployment
+		// Deployments of apps with a running deployment are left in the queue.
+		running := db.Model(&Deployment{}).Select("app_id").Where("status IN ?", runningDeploymentStatuses)
+		err := db.Where("status = ? AND app_id NOT IN (?)", DeploymentPending, running).
+			Order("created_at").
+			Take(&deployment).Error
+		if err != nil {
+			return nil, err
+		}
+
+		// Only one worker can move the deployment out of pending.
+		now := time.Now().UTC()
+		res := db.Model(&Deployment{}).
+			Where("id = ? AND status = ?", deployment.ID, DeploymentPending).
+			Updates(map[string]any{"status": DeploymentResolving, "updated_at": now, "heartbeat_at": now})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			// Another worker claimed it first.
+			continue
+		}
+
+		deployment.Status = DeploymentResolving
+		deployment.HeartbeatAt = &now
+		return deployment, nil
+	}
+}
+
+// CancelPending cancels the pending deployments of an application.
+func (s *deploymentService) CancelPending(ctx context.Context, appID string) error {
+	return s.db.WithContext(ctx).Model(&Deployment{}).
+		Where("app_id = ? AND status = ?", appID, DeploymentPending).
+		Updates(map[string]any{"status": DeploymentCancelled, "updated_at": time.Now().UTC()}).Error
+}
+
+// Cancel cancels the deployment if it is still pending, returning
+// true if it was cancelled.
+func (s *deploymentService) Cancel(ctx context.Context, id string) (bool, error) {
+	res := s.db.WithContext(ctx).Model(&Deployment{}).
+		Where("id = ? AND status = ?", id, DeploymentPending).
+		Updates(map[string]any{"status": DeploymentCancelled, "updated_at": time.Now().UTC()})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// deployContext returns the context of a deploy, limited by the deploy timeout.
+func (a *Aura) deployContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.deployTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, a.deployTimeout)
+}
+
+// detachedContext is a context with the values of its parent,
+// that is never cancelled and has no deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (c detachedContext) Done() <-chan struct{} { return nil }
+
+func (c detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key any) any { return c.parent.Value(key) }
+
+// DeploymentsQuery contains a deployment query.
+type DeploymentsQuery struct {
+	App *App
+
+	ID string
+}
+
+func (q DeploymentsQuery) scope(db *gorm.DB) *gorm.DB {
+	var scope composedScope
+
+	if q.App != nil {
+		scope = append(scope, fieldEquals("app_id", q.App.ID))
+	}
+
+	if q.ID != "" {
+		scope = append(scope, idEquals(q.ID))
+	}
+
+	return scope.scope(db)
+}
+
+// Deployment returns the first deployment matching the query.
+func (a *Aura) Deployment(ctx context.Context, q DeploymentsQuery) (*Deployment, error) {
+	deployment, err := a.deployments.First(ctx, q)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("could not find deployment: %w", err)
+		}
+	}
+	return deployment, nil
+}
+
+// CreateDeployment creates a pending deployment, to be deployed
+// in the background by the deploy workers.
+//
+// Deployments of an application are deployed one at a time. With NoWait,
+// ErrConflict is returned if the application has an unfinished deployment.
+func (a *Aura) CreateDeployment(ctx context.Context, cfg DeployConfig) (*Deployment, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+	if err := a.checkImagePolicy(cfg.App, cfg.Image); err != nil {
+		return nil, err
+	}
+
+	deployment := &Deployment{
+		AppID:    cfg.App.ID,
+		Image:    cfg.Image.String(),
+		Platform: cfg.Image.Platform.String(),
+		Status:   DeploymentPending,
+	}
+	var err error
+	if cfg.NoWait {
+		err = a.deployments.CreateExclusive(ctx, deployment)
+	} else {
+		_, err = a.deployments.Create(ctx, deployment)
+	}
+	if err != nil {
+		if errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("could not create deployment: %w", err)
+	}
+	deployment.App = cfg.App
+
+	// Wake up a waiting worker, if there is one.
+	select {
+	case a.deployNotify <- struct{}{}:
+	default:
+	}
+
+	return deployment, nil
+}
+
+// StreamDeployment creates a deployment and deploys it, reporting
+// the progress of the deployment to progress.
+//
+// The deployment is not handed to the deploy workers, the returned
+// deployment is finished. Once the application is locked, the deployment
+// is not cancelled with ctx and continues until it finishes or the deploy
+// timeout is reached.
+func (a *Aura) StreamDeployment(ctx context.Context, cfg DeployConfig, progress func(DeployEvent)) (*Deployment, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+
+	unlock, err := a.lockActiveApp(ctx, cfg.App, !cfg.NoWait)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// The caller going away should not leave a deployment half done.
+	ctx = detachedContext{parent: ctx}
+
+	// The deployment is created as resolving, so it is never claimed by a worker.
+	now := time.Now().UTC()
+	deployment, err := a.deployments.Create(ctx, &Deployment{
+		AppID:       cfg.App.ID,
+		Image:       cfg.Image.String(),
+		Platform:    cfg.Image.Platform.String(),
+		Status:      DeploymentResolving,
+		HeartbeatAt: &now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create deployment: %w", err)
+	}
+
+	stop := a.heartbeatDeploy(deployment.ID)
+	defer stop()
+
+	if err = a.runDeployment(ctx, deployment, progress); err != nil {
+		return nil, fmt.Errorf("could not update deployment: %w", err)
+	}
+
+	return a.Deployment(context.Background(), DeploymentsQuery{ID: deployment.ID})
+}
+
+// deployPollInterval is the interval at which idle deploy workers
+// check for pending deployments.
+const deployPollInterval = 5 * time.Second
+
+// RunDeployWorkers processes pending deployments with n workers
+// until the context is cancelled.
+func (a *Aura) RunDeployWorkers(ctx context.Context, n int) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+
+			a.runDeployWorker(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (a *Aura) runDeployWorker(ctx context.Context) {
+	ticker := time.NewTicker(deployPollInterval)
+	defer ticker.Stop()
+
+	for {
+		deployment, err := a.deployments.Claim(ctx)
+		switch {
+		case err == nil:
+			log := a.log.With(lctx.Str("app_id", deployment.AppID), lctx.Str("deployment_id", deployment.ID))
+
+			if err = a.processDeployment(ctx, deployment); err != nil {
+				log.Error("Could not update deployment", lctx.Error("error", err))
+				continue
+			}
+			log.Info("Deployment finished", lctx.Str("status", deployment.Status))
+			continue
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		case ctx.Err() != nil:
+			return
+		default:
+			a.log.Error("Could not claim deployment", lctx.Error("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.deployNotify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// processDeployment deploys a claimed deployment, once any other
+// deploy of the application has finished.
+func (a *Aura) processDeployment(ctx context.Context, deployment *Deployment) error {
+	stop := a.heartbeatDeploy(deployment.ID)
+	defer stop()
+
+	unlock, err := a.locks.Lock(ctx, deployment.AppID, true)
+	if err != nil {
+		deployment.Status = DeploymentFailed
+		deployment.Error = fmt.Sprintf("could not lock app: %v", err)
+		return a.deployments.Update(context.Background(), deployment)
+	}
+	defer unlock()
+
+	return a.runDeployment(ctx, deployment, func(DeployEvent) {})
+}
+
+// heartbeatDeploy records heartbeats of the running deployment until
+// the returned function is called.
+func (a *Aura) heartbeatDeploy(id string) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(deployHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := a.deployments.Heartbeat(ctx, id); err != nil && ctx.Err() == nil {
+				a.log.Error("Could not record deployment heartbeat", lctx.Str("deployment_id", id), lctx.Error("error", err))
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// runDeployment deploys the deployment, recording its status as
+// it progresses. Deploy failures are recorded on the deployment, only
+// a failure to record the final status is returned.
+func (a *Aura) runDeployment(ctx context.Context, deployment *Deployment, progress func(DeployEvent)) error {
+	ctx, cancel := a.deployContext(ctx)
+	defer cancel()
+
+	running := a.trackDeploy(deployment, cancel)
+	defer a.untrackDeploy(deployment.ID)
+
+	release, err := a.deployDeployment(ctx, deployment, progress)
+	switch {
+	case err == nil:
+		deployment.Status = DeploymentSucceeded
+	case running.isCancelled():
+		deployment.Status = DeploymentCancelled
+		deployment.Error = "the deployment was cancelled"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		deployment.Status = DeploymentFailed
+		deployment.Error = fmt.Sprintf("the deployment timed out: %v", err)
+	default:
+		deployment.Status = DeploymentFailed
+		deployment.Error = err.Error()
+	}
+	if err != nil {
+		var phaseErr ReleasePhaseError
+		if errors.As(err, &phaseErr) {
+			release = phaseErr.Release
+		}
+	}
+	if release != nil {
+		deployment.ReleaseID = &release.ID
+	}
+
+	// The deployment is finished, even if the context was cancelled.
+	return a.deployments.Update(context.Background(), deployment)
+}
+
+type runningDeploy struct {
+	appID string
+
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+func (d *runningDeploy) cancelDeploy() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.cancelled = true
+	d.cancel()
+}
+
+func (d *runningDeploy) isCancelled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.cancelled
+}
+
+func (a *Aura) trackDeploy(deployment *Deployment, cancel context.CancelFunc) *runningDeploy {
+	running := &runningDeploy{appID: deployment.AppID, cancel: cancel}
+
+	a.runningMu.Lock()
+	a.running[deployment.ID] = running
+	a.runningMu.Unlock()
+
+	return running
+}
+
+func (a *Aura) untrackDeploy(id string) {
+	a.runningMu.Lock()
+	delete(a.running, id)
+	a.runningMu.Unlock()
+}
+
+func (a *Aura) deployDeployment(ctx context.Context, deployment *Deployment, progress func(DeployEvent)) (*Release, error) {
+	app, err := a.App(ctx, AppsQuery{ID: deployment.AppID})
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := image.Decode(deployment.Image)
+	if err != nil {
+		return nil, err
+	}
+	if deployment.Platform != "" {
+		if img.Platform, err = image.ParsePlatform(deployment.Platform); err != nil {
+			return nil, err
+		}
+	}
+
+	return a.deploy(ctx, DeployConfig{App: app, Image: img}, func(event DeployEvent) {
+		progress(event)
+
+		if deployment.Status == event.Status {
+			return
+		}
+
+		deployment.Status = event.Status
+		if err := a.deployments.Update(ctx, deployment); err != nil {
+			// The status is only informational, the deployment continues.
+			a.log.Error("Could not update deployment status",
+				lctx.Str("app_id", deployment.AppID),
+				lctx.Str("deployment_id", deployment.ID),
+				lctx.Error("error", err),
+			)
+		}
+	})
+}
+
+// CancelDeploymentConfig contains deployment cancel configuration.
+type CancelDeploymentConfig struct {
+	App *App
+	ID  string
+}
+
+// Validate validates a deployment cancel configuration.
+func (c CancelDeploymentConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if c.ID == "" {
+		return errors.New("a deployment is required")
+	}
+
+	return nil
+}
+
+// CancelDeployment cancels a pending or running deployment.
+//
+// A pending deployment is cancelled immediately. A running deployment
+// has its context cancelled, and is marked cancelled once the deploy
+// has stopped. Only deployments running on this server can be cancelled.
+func (a *Aura) CancelDeployment(ctx context.Context, cfg CancelDeploymentConfig) (*Deployment, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+
+	deployment, err := a.Deployment(ctx, DeploymentsQuery{App: cfg.App, ID: cfg.ID})
+	if err != nil {
+		return nil, err
+	}
+	if deployment.Done() {
+		return nil, ValidationError{err: errors.New("the deployment is already finished")}
+	}
+
+	cancelled, err := a.deployments.Cancel(ctx, deployment.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not cancel deployment: %w", err)
+	}
+	if cancelled {
+		return a.Deployment(ctx, DeploymentsQuery{App: cfg.App, ID: cfg.ID})
+	}
+
+	a.runningMu.Lock()
+	running, ok := a.running[deployment.ID]
+	a.runningMu.Unlock()
+	if !ok {
+		return nil, ValidationError{err: errors.New("the deployment is not running on this server")}
+	}
+	running.cancelDeploy()
+
+	return deployment, nil
+}
+
+// cancelDeployments cancels the pending deployments of an application,
+// and its deployments running on this server.
+func (a *Aura) cancelDeployments(ctx context.Context, app *App) error {
+	if err := a.deployments.CancelPending(ctx, app.ID); err != nil {
+		return fmt.Errorf("could not cancel deployments: %w", err)
+	}
+
+	a.runningMu.Lock()
+	defer a.runningMu.Unlock()
+
+	for _, running := range a.running {
+		if running.appID == app.ID {
+			running.cancelDeploy()
+		}
+	}
+	return nil
+}
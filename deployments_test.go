@@ -0,0 +1,553 @@
+package aura_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAura_CreateDeployment(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	got, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	require.NoError(t, err)
+	assert.NotEmpty(t, got.ID)
+	assert.Equal(t, "docker.io/foo/bar:latest", got.Image)
+	assert.Equal(t, aura.DeploymentPending, got.Status)
+
+	deployment, err := a.Deployment(context.Background(), aura.DeploymentsQuery{App: app, ID: got.ID})
+	require.NoError(t, err)
+	assert.Equal(t, got.ID, deployment.ID)
+	assert.Equal(t, aura.DeploymentPending, deployment.Status)
+}
+
+func TestAura_CreateDeploymentHandlesValidationError(t *testing.T) {
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	_, err := a.CreateDeployment(context.Background(), aura.DeployConfig{})
+
+	assert.ErrorAs(t, err, &aura.ValidationError{})
+}
+
+func TestAura_DeploymentHandlesNoDeployment(t *testing.T) {
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Deployment(context.Background(), aura.DeploymentsQuery{App: app, ID: "test"})
+
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_RunDeployWorkers(t *testing.T) {
+	tests := []struct {
+		name        string
+		extractErr  error
+		wantStatus  string
+		wantError   string
+		wantRelease bool
+	}{
+		{
+			name:        "handles successful deployment",
+			wantStatus:  aura.DeploymentSucceeded,
+			wantRelease: true,
+		},
+		{
+			name:       "handles failed deployment",
+			extractErr: errors.New("test"),
+			wantStatus: aura.DeploymentFailed,
+			wantError:  "could not extract procfile: test",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			img, err := image.Decode("foo/bar:latest")
+			require.NoError(t, err)
+
+			db := testDB(t)
+			reg := &mockRegistry{}
+			reg.On("Resolve", img).Return(img, nil)
+			reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), test.extractErr)
+
+			sched := &mockScheduler{}
+			sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			a := aura.New(db, reg, sched)
+
+			app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+			require.NoError(t, err)
+
+			deployment, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+			require.NoError(t, err)
+
+			runDeployWorkers(t, a)
+
+			var got *aura.Deployment
+			require.Eventually(t, func() bool {
+				got, err = a.Deployment(context.Background(), aura.DeploymentsQuery{App: app, ID: deployment.ID})
+				return err == nil && got.Done()
+			}, 5*time.Second, 10*time.Millisecond)
+
+			assert.Equal(t, test.wantStatus, got.Status)
+			assert.Equal(t, test.wantError, got.Error)
+			if test.wantRelease {
+				require.NotNil(t, got.Release)
+				assert.Equal(t, 1, got.Release.Version)
+			} else {
+				assert.Nil(t, got.Release)
+			}
+		})
+	}
+}
+
+func TestAura_RunDeployWorkersPassesPlatform(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+	img.Platform = image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	deployment, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+	assert.Equal(t, "linux/arm/v7", deployment.Platform)
+
+	runDeployWorkers(t, a)
+
+	var got *aura.Deployment
+	require.Eventually(t, func() bool {
+		got, err = a.Deployment(context.Background(), aura.DeploymentsQuery{App: app, ID: deployment.ID})
+		return err == nil && got.Done()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, aura.DeploymentSucceeded, got.Status)
+	require.NotNil(t, got.Release)
+	require.NotNil(t, got.Release.Platform)
+	assert.Equal(t, img.Platform, *got.Release.Platform)
+	reg.AssertCalled(t, "Resolve", img)
+}
+
+func TestAura_RunDeployWorkersHandlesTimeout(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{block: true}
+
+	a := aura.New(db, reg, &mockScheduler{}, aura.WithDeployTimeout(10*time.Millisecond))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	deployment, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	runDeployWorkers(t, a)
+
+	var got *aura.Deployment
+	require.Eventually(t, func() bool {
+		got, err = a.Deployment(context.Background(), aura.DeploymentsQuery{App: app, ID: deployment.ID})
+		return err == nil && got.Done()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, aura.DeploymentFailed, got.Status)
+	assert.Equal(t, "the deployment timed out: could not resolve image: context deadline exceeded", got.Error)
+}
+
+func TestAura_CancelDeploymentCancelsPendingDeployment(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	deployment, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	got, err := a.CancelDeployment(context.Background(), aura.CancelDeploymentConfig{App: app, ID: deployment.ID})
+
+	require.NoError(t, err)
+	assert.Equal(t, aura.DeploymentCancelled, got.Status)
+	assert.True(t, got.Done())
+}
+
+func TestAura_CancelDeploymentCancelsRunningDeployment(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{block: true}
+
+	a := aura.New(db, reg, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	deployment, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	runDeployWorkers(t, a)
+
+	// The deployment can only be cancelled once a worker is running it.
+	require.Eventually(t, func() bool {
+		_, err = a.CancelDeployment(context.Background(), aura.CancelDeploymentConfig{App: app, ID: deployment.ID})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var got *aura.Deployment
+	require.Eventually(t, func() bool {
+		got, err = a.Deployment(context.Background(), aura.DeploymentsQuery{App: app, ID: deployment.ID})
+		return err == nil && got.Done()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, aura.DeploymentCancelled, got.Status)
+	assert.Equal(t, "the deployment was cancelled", got.Error)
+}
+
+func TestAura_CancelDeploymentHandlesFinishedDeployment(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, errors.New("test"))
+
+	a := aura.New(db, reg, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	deployment, err := a.StreamDeployment(context.Background(), aura.DeployConfig{App: app, Image: img}, func(aura.DeployEvent) {})
+	require.NoError(t, err)
+
+	_, err = a.CancelDeployment(context.Background(), aura.CancelDeploymentConfig{App: app, ID: deployment.ID})
+
+	assert.ErrorAs(t, err, &aura.ValidationError{})
+}
+
+func TestAura_CancelDeploymentHandlesNoDeployment(t *testing.T) {
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.CancelDeployment(context.Background(), aura.CancelDeploymentConfig{App: app, ID: "test"})
+
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_RunDeployWorkersQueuesDeploymentsOfAnApp(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		deployment, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+		require.NoError(t, err)
+		ids = append(ids, deployment.ID)
+	}
+
+	runDeployWorkers(t, a)
+
+	var versions []int
+	for _, id := range ids {
+		var got *aura.Deployment
+		require.Eventually(t, func() bool {
+			got, err = a.Deployment(context.Background(), aura.DeploymentsQuery{App: app, ID: id})
+			return err == nil && got.Done()
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.Equal(t, aura.DeploymentSucceeded, got.Status)
+		versions = append(versions, got.Release.Version)
+	}
+	assert.Equal(t, []int{1, 2, 3}, versions)
+}
+
+func TestAura_CreateDeploymentHandlesUnfinishedDeployment(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img, NoWait: true})
+	require.NoError(t, err)
+
+	_, err = a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img, NoWait: true})
+
+	assert.ErrorIs(t, err, aura.ErrConflict)
+}
+
+func TestAura_RunDeployWorkersFailsAbandonedDeployment(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	abandoned, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+	abandonDeployment(t, db, abandoned.ID)
+
+	deployment, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	runDeployWorkers(t, a)
+
+	var got *aura.Deployment
+	require.Eventually(t, func() bool {
+		got, err = a.Deployment(context.Background(), aura.DeploymentsQuery{App: app, ID: deployment.ID})
+		return err == nil && got.Done()
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, aura.DeploymentSucceeded, got.Status)
+
+	got, err = a.Deployment(context.Background(), aura.DeploymentsQuery{App: app, ID: abandoned.ID})
+	require.NoError(t, err)
+	assert.Equal(t, aura.DeploymentFailed, got.Status)
+	assert.Equal(t, "the deployment was abandoned", got.Error)
+}
+
+func TestAura_CreateDeploymentHandlesAbandonedDeployment(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	abandoned, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img, NoWait: true})
+	require.NoError(t, err)
+	abandonDeployment(t, db, abandoned.ID)
+
+	_, err = a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img, NoWait: true})
+	require.NoError(t, err)
+
+	got, err := a.Deployment(context.Background(), aura.DeploymentsQuery{App: app, ID: abandoned.ID})
+	require.NoError(t, err)
+	assert.Equal(t, aura.DeploymentFailed, got.Status)
+}
+
+func TestAura_CreateDeploymentHandlesRunningDeployment(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	running, err := a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img, NoWait: true})
+	require.NoError(t, err)
+	err = db.Model(&aura.Deployment{}).Where("id = ?", running.ID).Updates(map[string]any{
+		"status":       aura.DeploymentResolving,
+		"heartbeat_at": time.Now().UTC(),
+	}).Error
+	require.NoError(t, err)
+
+	_, err = a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img, NoWait: true})
+
+	assert.ErrorIs(t, err, aura.ErrConflict)
+}
+
+func TestAura_DeployHandlesConcurrentDeploy(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{block: true, resolving: make(chan struct{}, 1)}
+
+	a := aura.New(db, reg, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		_, _ = a.Deploy(ctx, aura.DeployConfig{App: app, Image: img})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	<-reg.resolving
+
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img, NoWait: true})
+	assert.ErrorIs(t, err, aura.ErrConflict)
+
+	_, err = a.StreamDeployment(context.Background(), aura.DeployConfig{App: app, Image: img, NoWait: true}, func(aura.DeployEvent) {})
+	assert.ErrorIs(t, err, aura.ErrConflict)
+}
+
+func TestAura_StreamDeployment(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{pulls: []aura.PullProgress{{ID: "abc", Status: "Downloading", Current: 1, Total: 2}}}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	var events []aura.DeployEvent
+	got, err := a.StreamDeployment(context.Background(), aura.DeployConfig{App: app, Image: img}, func(event aura.DeployEvent) {
+		events = append(events, event)
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, aura.DeploymentSucceeded, got.Status)
+	require.NotNil(t, got.Release)
+	assert.Equal(t, 1, got.Release.Version)
+	want := []aura.DeployEvent{
+		{Status: aura.DeploymentResolving},
+		{Status: aura.DeploymentResolving, Pull: &aura.PullProgress{ID: "abc", Status: "Downloading", Current: 1, Total: 2}},
+		{Status: aura.DeploymentExtracting},
+		{Status: aura.DeploymentReleasing},
+	}
+	assert.Equal(t, want, events)
+}
+
+func TestAura_StreamDeploymentContinuesWhenCancelled(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got, err := a.StreamDeployment(ctx, aura.DeployConfig{App: app, Image: img}, func(aura.DeployEvent) {
+		// The client goes away once the deployment started.
+		cancel()
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, aura.DeploymentSucceeded, got.Status)
+	require.NotNil(t, got.Release)
+	sched.AssertCalled(t, "Submit", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAura_StreamDeploymentHandlesFailedDeploy(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, errors.New("test"))
+
+	a := aura.New(db, reg, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	got, err := a.StreamDeployment(context.Background(), aura.DeployConfig{App: app, Image: img}, func(aura.DeployEvent) {})
+
+	require.NoError(t, err)
+	assert.Equal(t, aura.DeploymentFailed, got.Status)
+	assert.Equal(t, "could not resolve image: test", got.Error)
+	assert.Nil(t, got.Release)
+}
+
+func TestAura_StreamDeploymentHandlesValidationError(t *testing.T) {
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	_, err := a.StreamDeployment(context.Background(), aura.DeployConfig{}, func(aura.DeployEvent) {})
+
+	assert.ErrorAs(t, err, &aura.ValidationError{})
+}
+
+// abandonDeployment leaves the deployment running without a recent
+// heartbeat, as if the server deploying it stopped.
+func abandonDeployment(t *testing.T, db *aura.DB, id string) {
+	t.Helper()
+
+	err := db.Model(&aura.Deployment{}).Where("id = ?", id).Updates(map[string]any{
+		"status":       aura.DeploymentResolving,
+		"heartbeat_at": time.Now().UTC().Add(-2 * time.Minute),
+	}).Error
+	require.NoError(t, err)
+}
@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// cleanupTimeout is the time allowed to clean up a container.
+const cleanupTimeout = 30 * time.Second
+
+func createContainer(ctx context.Context, client *docker.Client, name string, cfg *docker.Config, hostCfg *docker.HostConfig) (string, error) {
+	ctr, err := client.CreateContainer(docker.CreateContainerOptions{
+		Name:       name,
+		Config:     cfg,
+		HostConfig: hostCfg,
+		Context:    ctx,
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating container: %w", err)
+	}
+
+	return ctr.ID, nil
+}
+
+func removeContainer(ctx context.Context, client *docker.Client, id string, force bool) error {
+	if err := client.RemoveContainer(docker.RemoveContainerOptions{
+		ID:      id,
+		Force:   force,
+		Context: ctx,
+	}); err != nil {
+		return fmt.Errorf("removing container: %w", err)
+	}
+	return nil
+}
+
+// cleanupContainer removes a container left behind by a failed or
+// finished operation. It does not use the operation context, so the
+// container is removed even when the operation was cancelled.
+func cleanupContainer(client *docker.Client, id string, force bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	return removeContainer(ctx, client, id, force)
+}
@@ -4,70 +4,378 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"path/filepath"
+	"strings"
 
 	docker "github.com/fsouza/go-dockerclient"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/oci"
 	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/segmentio/ksuid"
 )
 
+// Option configures a registry.
+type Option func(*Registry)
+
+// WithPlatform sets the platform pulled for images without a platform.
+// By default, the platform of the docker daemon is pulled.
+func WithPlatform(p image.Platform) Option {
+	return func(r *Registry) {
+		r.platform = p
+	}
+}
+
+// WithRegistryHTTPClient sets the http client used to read
+// image indexes from their registry.
+func WithRegistryHTTPClient(client *http.Client) Option {
+	return func(r *Registry) {
+		r.indexOpts = append(r.indexOpts, oci.WithHTTPClient(client))
+	}
+}
+
+// WithTokenRealm allows the credentials of the registry to be sent to
+// the token service at the realm host when reading image indexes.
+func WithTokenRealm(registry, realmHost string) Option {
+	return func(r *Registry) {
+		r.indexOpts = append(r.indexOpts, oci.WithTokenRealm(registry, realmHost))
+	}
+}
+
 // Registry is a docker registry.
 type Registry struct {
-	client *docker.Client
+	client    *docker.Client
+	index     *oci.Registry
+	indexOpts []oci.Option
+	platform  image.Platform
 }
 
 // NewRegistry returns a registry.
-func NewRegistry() (*Registry, error) {
+func NewRegistry(opts ...Option) (*Registry, error) {
 	client, err := docker.NewClientFromEnv()
 	if err != nil {
 		return nil, fmt.Errorf("could not create docker client: %w", err)
 	}
 
-	return &Registry{
+	reg := &Registry{
 		client: client,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(reg)
+	}
+
+	reg.index = oci.NewRegistry(reg.indexOpts...)
+
+	return reg, nil
 }
 
-// Resolve resolves a docker image.
-func (r *Registry) Resolve(ctx context.Context, img image.Image) (image.Image, error) {
+// Resolve resolves a docker image, reporting the pull progress to progress.
+//
+// The daemon does not report the platform manifest it pulls from an
+// image index, so multi-platform images are resolved to the manifest of
+// their platform, or the registry platform, from the index in their
+// registry. The manifest is then pulled by its digest.
+func (r *Registry) Resolve(ctx context.Context, img image.Image, auth *aura.RegistryAuth, progress func(aura.PullProgress)) (image.Image, error) {
+	p := img.Platform
+	if p.IsZero() {
+		p = r.platform
+	}
+
+	authCfg := authConfig(img, auth)
+	dist, err := r.inspectDistribution(ctx, pullReference(img), authCfg)
+	if err != nil {
+		return img, fmt.Errorf("inspecting distribution: %w", err)
+	}
+	if isIndex(dist.Descriptor.MediaType) {
+		return r.resolveIndex(ctx, img, p, dist, auth, progress)
+	}
+
 	opts := docker.PullImageOptions{
-		Registry:   img.Registry,
-		Repository: img.Repository,
+		Repository: pullRepository(img),
 		Tag:        img.Tag,
+		Platform:   p.String(),
 		Context:    ctx,
 	}
 	if img.Digest != "" {
 		opts.Tag = img.Digest
 	}
-	err := r.client.PullImage(opts, docker.AuthConfiguration{})
+	if err = r.pullImage(opts, authCfg, progress); err != nil {
+		return img, fmt.Errorf("pulling image: %w", err)
+	}
+
+	i, err := r.client.InspectImage(img.String())
+	if err != nil {
+		return img, fmt.Errorf("inspecting image: %w", err)
+	}
+
+	if img.Digest == "" && len(i.RepoDigests) > 0 {
+		if img, err = image.Decode(i.RepoDigests[0]); err != nil {
+			return img, err
+		}
+	}
+	img.Platform = image.Platform{OS: i.OS, Architecture: i.Architecture}
+	if img.Platform.Architecture == p.Architecture {
+		// The daemon does not report the variant of the image.
+		img.Platform.Variant = p.Variant
+	}
+	return img, nil
+}
+
+// resolveIndex resolves the image index to the manifest of the platform,
+// and pulls the manifest.
+func (r *Registry) resolveIndex(ctx context.Context, img image.Image, p image.Platform, dist distributionInspect, auth *aura.RegistryAuth, progress func(aura.PullProgress)) (image.Image, error) {
+	if p.IsZero() {
+		var err error
+		if p, err = r.daemonPlatform(ctx); err != nil {
+			return img, err
+		}
+	}
+	if !dist.hasPlatform(p) {
+		return img, fmt.Errorf("no manifest for platform %s", p)
+	}
+
+	// The index is resolved by the digest the daemon saw, in case the tag moved.
+	idx := img
+	idx.Digest = dist.Descriptor.Digest
+	idx.Platform = p
+	resolved, err := r.index.Resolve(ctx, idx, auth, progress)
 	if err != nil {
+		return img, fmt.Errorf("resolving image index: %w", err)
+	}
+
+	opts := docker.PullImageOptions{
+		Repository: pullRepository(img),
+		Tag:        resolved.Digest,
+		Context:    ctx,
+	}
+	if err = r.pullImage(opts, authConfig(img, auth), progress); err != nil {
 		return img, fmt.Errorf("pulling image: %w", err)
 	}
+	return resolved, nil
+}
 
-	if img.Digest != "" {
-		return img, nil
+// daemonPlatform returns the platform of the docker daemon.
+func (r *Registry) daemonPlatform(ctx context.Context) (image.Platform, error) {
+	env, err := r.client.VersionWithContext(ctx)
+	if err != nil {
+		return image.Platform{}, fmt.Errorf("getting daemon version: %w", err)
 	}
+	return image.Platform{OS: env.Get("Os"), Architecture: env.Get("Arch")}, nil
+}
 
-	i, err := r.client.InspectImage(img.String())
+// pullRepository returns the repository to pull the image from.
+//
+// The daemon ignores the registry option, it pulls from the
+// registry in the repository.
+func pullRepository(img image.Image) string {
+	if img.Registry == "" {
+		return img.Repository
+	}
+	return img.Registry + "/" + img.Repository
+}
+
+// pullReference returns the reference the image is pulled by.
+func pullReference(img image.Image) string {
+	switch {
+	case img.Digest != "":
+		return pullRepository(img) + "@" + img.Digest
+	case img.Tag != "":
+		return pullRepository(img) + ":" + img.Tag
+	default:
+		return pullRepository(img) + ":latest"
+	}
+}
+
+// Image index media types.
+const (
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+func isIndex(mediaType string) bool {
+	return mediaType == mediaTypeDockerManifestList || mediaType == mediaTypeOCIIndex
+}
+
+type distributionInspect struct {
+	Descriptor struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"Descriptor"`
+	Platforms []struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	} `json:"Platforms"`
+}
+
+// hasPlatform determines if the image index has a manifest for the platform.
+func (d distributionInspect) hasPlatform(p image.Platform) bool {
+	for _, plat := range d.Platforms {
+		if (image.Platform{OS: plat.OS, Architecture: plat.Architecture, Variant: plat.Variant}).Matches(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// inspectDistribution returns the descriptor of the image manifest in its registry.
+//
+// The docker client does not send registry auth to the distribution endpoint,
+// so the request is made with the http client of the docker client.
+func (r *Registry) inspectDistribution(ctx context.Context, ref string, authCfg docker.AuthConfiguration) (distributionInspect, error) {
+	base, err := r.endpointURL()
 	if err != nil {
-		return img, fmt.Errorf("inspecting image: %w", err)
+		return distributionInspect{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/distribution/"+ref+"/json", nil)
+	if err != nil {
+		return distributionInspect{}, err
 	}
+	if authCfg != (docker.AuthConfiguration{}) {
+		b, err := json.Marshal(authCfg)
+		if err != nil {
+			return distributionInspect{}, err
+		}
+		req.Header.Set("X-Registry-Auth", base64.URLEncoding.EncodeToString(b))
+	}
+
+	resp, err := r.client.HTTPClient.Do(req)
+	if err != nil {
+		return distributionInspect{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
 
-	if len(i.RepoDigests) == 0 {
-		return img, nil
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Message string `json:"message"`
+		}
+		if err = json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Message == "" {
+			return distributionInspect{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+		return distributionInspect{}, errors.New(errResp.Message)
 	}
 
-	return image.Decode(i.RepoDigests[0])
+	var dist distributionInspect
+	if err = json.NewDecoder(resp.Body).Decode(&dist); err != nil {
+		return distributionInspect{}, fmt.Errorf("decoding response: %w", err)
+	}
+	return dist, nil
+}
+
+// endpointURL returns the base url of the docker daemon.
+func (r *Registry) endpointURL() (string, error) {
+	u, err := url.Parse(r.client.Endpoint())
+	if err != nil {
+		return "", fmt.Errorf("parsing docker endpoint: %w", err)
+	}
+
+	switch u.Scheme {
+	case "unix", "npipe":
+		// The http client dials the socket, the host is not used.
+		return "http://unix.sock", nil
+	case "tcp":
+		u.Scheme = "http"
+		if r.client.TLSConfig != nil {
+			u.Scheme = "https"
+		}
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Progress       string `json:"progress"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error string `json:"error"`
+}
+
+// authConfig returns the docker auth configuration of the registry auth.
+func authConfig(img image.Image, auth *aura.RegistryAuth) docker.AuthConfiguration {
+	if auth == nil {
+		return docker.AuthConfiguration{}
+	}
+	return docker.AuthConfiguration{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		ServerAddress: img.Registry,
+	}
+}
+
+func (r *Registry) pullImage(opts docker.PullImageOptions, authCfg docker.AuthConfiguration, progress func(aura.PullProgress)) error {
+	if progress == nil {
+		return r.client.PullImage(opts, authCfg)
+	}
+
+	// With a raw stream the pull errors are not returned by the client,
+	// they are part of the stream.
+	pr, pw := io.Pipe()
+	opts.OutputStream = pw
+	opts.RawJSONStream = true
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		defer func() { _, _ = io.Copy(io.Discard, pr) }()
+
+		dec := json.NewDecoder(pr)
+		for {
+			var msg pullMessage
+			if err := dec.Decode(&msg); err != nil {
+				if !errors.Is(err, io.EOF) {
+					errCh <- fmt.Errorf("decoding pull progress: %w", err)
+				}
+				return
+			}
+			if msg.Error != "" {
+				errCh <- errors.New(msg.Error)
+				return
+			}
+
+			progress(aura.PullProgress{
+				ID:       msg.ID,
+				Status:   msg.Status,
+				Progress: msg.Progress,
+				Current:  msg.ProgressDetail.Current,
+				Total:    msg.ProgressDetail.Total,
+			})
+		}
+	}()
+
+	err := r.client.PullImage(opts, authCfg)
+	_ = pw.CloseWithError(err)
+	if streamErr := <-errCh; streamErr != nil && err == nil {
+		err = streamErr
+	}
+	return err
 }
 
 // ExtractProcfile extracts a procfile from an image.
-func (r *Registry) ExtractProcfile(ctx context.Context, img string) ([]byte, error) {
-	ctrID, err := r.createContainer(ctx, img)
+//
+// The image must have been pulled by Resolve, so no registry auth is needed.
+func (r *Registry) ExtractProcfile(ctx context.Context, img string, _ *aura.RegistryAuth) ([]byte, error) {
+	// The container is named, so it can be cleaned up if the context
+	// is cancelled after the container is created, but before its ID is known.
+	name := "aura-extract-" + ksuid.New().String()
+	ctrID, err := createContainer(ctx, r.client, name, &docker.Config{Image: img}, nil)
 	if err != nil {
+		if ctx.Err() != nil {
+			_ = cleanupContainer(r.client, name, false)
+		}
 		return nil, err
 	}
-	defer func() { _ = r.removeContainer(ctx, ctrID) }()
+	defer func() { _ = cleanupContainer(r.client, ctrID, false) }()
 
 	ctr, err := r.client.InspectContainerWithOptions(docker.InspectContainerOptions{
 		ID:      ctrID,
@@ -90,30 +398,6 @@ func (r *Registry) ExtractProcfile(ctx context.Context, img string) ([]byte, err
 	return b, nil
 }
 
-func (r *Registry) createContainer(ctx context.Context, img string) (string, error) {
-	ctr, err := r.client.CreateContainer(docker.CreateContainerOptions{
-		Config: &docker.Config{
-			Image: img,
-		},
-		Context: ctx,
-	})
-	if err != nil {
-		return "", fmt.Errorf("creating container: %w", err)
-	}
-
-	return ctr.ID, nil
-}
-
-func (r *Registry) removeContainer(ctx context.Context, id string) error {
-	if err := r.client.RemoveContainer(docker.RemoveContainerOptions{
-		ID:      id,
-		Context: ctx,
-	}); err != nil {
-		return fmt.Errorf("removing container: %w", err)
-	}
-	return nil
-}
-
 func (r *Registry) downloadFile(ctx context.Context, id, path string) ([]byte, error) {
 	var buf bytes.Buffer
 	if err := r.client.DownloadFromContainer(id, docker.DownloadFromContainerOptions{
@@ -4,12 +4,21 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
 	"net/http"
+	stdhttptest "net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	httptest "github.com/hamba/testutils/http"
+	"github.com/nrwiersma/aura"
 	"github.com/nrwiersma/aura/docker"
+	"github.com/nrwiersma/aura/oci"
 	"github.com/nrwiersma/aura/pkg/image"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,6 +26,7 @@ import (
 
 func TestRegistry_Resolve(t *testing.T) {
 	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/distribution/some/repo:latest/json").ReturnsString(http.StatusOK, testDistribution)
 	srv.On(http.MethodPost, "/images/create").ReturnsString(http.StatusOK, `{}`)
 	srv.On(http.MethodGet, "/images/some/repo:latest/json").ReturnsString(http.StatusOK, `{"RepoDigests":["some/repo@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"]}}`)
 
@@ -29,9 +39,10 @@ func TestRegistry_Resolve(t *testing.T) {
 	got, err := reg.Resolve(context.Background(), image.Image{
 		Repository: "some/repo",
 		Tag:        "latest",
-	})
+	}, nil, nil)
 
 	want := image.Image{
+		Registry:   "docker.io",
 		Repository: "some/repo",
 		Digest:     "sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2",
 	}
@@ -42,7 +53,9 @@ func TestRegistry_Resolve(t *testing.T) {
 
 func TestRegistry_ResolveHandlesDigest(t *testing.T) {
 	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/distribution/some/repo@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2/json").ReturnsString(http.StatusOK, testDistribution)
 	srv.On(http.MethodPost, "/images/create").ReturnsString(http.StatusOK, `{}`)
+	srv.On(http.MethodGet, "/images/some/repo@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2/json").ReturnsString(http.StatusOK, `{"Os":"linux","Architecture":"amd64"}`)
 
 	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
 	t.Cleanup(cancel)
@@ -53,19 +66,318 @@ func TestRegistry_ResolveHandlesDigest(t *testing.T) {
 	got, err := reg.Resolve(context.Background(), image.Image{
 		Repository: "some/repo",
 		Digest:     "sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2",
-	})
+	}, nil, nil)
 
 	want := image.Image{
 		Repository: "some/repo",
 		Digest:     "sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2",
+		Platform:   image.Platform{OS: "linux", Architecture: "amd64"},
 	}
 	require.NoError(t, err)
 	assert.Equal(t, want, got)
 	srv.AssertExpectations()
 }
 
+func TestRegistry_ResolveHandlesPlatform(t *testing.T) {
+	tests := []struct {
+		name         string
+		platform     image.Platform
+		imgPlatform  image.Platform
+		wantPlatform string
+		want         image.Platform
+	}{
+		{
+			name:         "handles daemon platform",
+			wantPlatform: "",
+			want:         image.Platform{OS: "linux", Architecture: "arm"},
+		},
+		{
+			name:         "handles registry platform",
+			platform:     image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			wantPlatform: "linux/arm/v7",
+			want:         image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		},
+		{
+			name:         "handles image platform",
+			platform:     image.Platform{OS: "linux", Architecture: "amd64"},
+			imgPlatform:  image.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			wantPlatform: "linux/arm/v6",
+			want:         image.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Pulling a platform requires api version 1.32.
+			createPath := "/images/create"
+			if test.wantPlatform != "" {
+				createPath = "/v1.32/images/create"
+			}
+
+			var gotPlatform string
+			srv := httptest.NewServer(t)
+			srv.On(http.MethodGet, "/distribution/some/repo:latest/json").ReturnsString(http.StatusOK, testDistribution)
+			srv.On(http.MethodPost, createPath).Handle(func(rw http.ResponseWriter, req *http.Request) {
+				gotPlatform = req.URL.Query().Get("platform")
+				_, _ = rw.Write([]byte(`{}`))
+			})
+			srv.On(http.MethodGet, "/images/some/repo:latest/json").ReturnsString(http.StatusOK, `{"RepoDigests":["some/repo@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"],"Os":"linux","Architecture":"arm"}`)
+
+			cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+			t.Cleanup(cancel)
+
+			reg, err := docker.NewRegistry(docker.WithPlatform(test.platform))
+			require.NoError(t, err)
+
+			got, err := reg.Resolve(context.Background(), image.Image{
+				Repository: "some/repo",
+				Tag:        "latest",
+				Platform:   test.imgPlatform,
+			}, nil, nil)
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantPlatform, gotPlatform)
+			assert.Equal(t, "sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2", got.Digest)
+			assert.Equal(t, test.want, got.Platform)
+			srv.AssertExpectations()
+		})
+	}
+}
+
+func TestRegistry_ResolvePullsFromImageRegistry(t *testing.T) {
+	tests := []struct {
+		name          string
+		image         string
+		wantFromImage string
+	}{
+		{
+			name:          "docker hub",
+			image:         "some/repo:1.0",
+			wantFromImage: "docker.io/some/repo",
+		},
+		{
+			name:          "registry",
+			image:         "gcr.io/some/repo:1.0",
+			wantFromImage: "gcr.io/some/repo",
+		},
+		{
+			name:          "registry with port",
+			image:         "localhost:5000/repo:1.0",
+			wantFromImage: "localhost:5000/repo",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			img, err := image.Decode(test.image)
+			require.NoError(t, err)
+
+			var gotFromImage, gotTag string
+			srv := httptest.NewServer(t)
+			srv.On(http.MethodGet, "/distribution/"+test.wantFromImage+":1.0/json").ReturnsString(http.StatusOK, testDistribution)
+			srv.On(http.MethodPost, "/images/create").Handle(func(rw http.ResponseWriter, req *http.Request) {
+				gotFromImage = req.URL.Query().Get("fromImage")
+				gotTag = req.URL.Query().Get("tag")
+				_, _ = rw.Write([]byte(`{}`))
+			})
+			srv.On(http.MethodGet, "/images/"+img.String()+"/json").ReturnsString(http.StatusOK, `{"RepoDigests":["`+test.wantFromImage+`@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"]}`)
+
+			cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+			t.Cleanup(cancel)
+
+			reg, err := docker.NewRegistry()
+			require.NoError(t, err)
+
+			got, err := reg.Resolve(context.Background(), img, nil, nil)
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantFromImage, gotFromImage)
+			assert.Equal(t, "1.0", gotTag)
+			assert.Equal(t, test.wantFromImage+"@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2", got.String())
+			srv.AssertExpectations()
+		})
+	}
+}
+
+func TestRegistry_ResolvePassesAuth(t *testing.T) {
+	var distAuthHeader, authHeader, fromImage string
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/distribution/registry.example.com/some/repo@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2/json").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		distAuthHeader = req.Header.Get("X-Registry-Auth")
+		_, _ = rw.Write([]byte(testDistribution))
+	})
+	srv.On(http.MethodPost, "/images/create").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		authHeader = req.Header.Get("X-Registry-Auth")
+		fromImage = req.URL.Query().Get("fromImage")
+		_, _ = rw.Write([]byte(`{}`))
+	})
+	srv.On(http.MethodGet, "/images/registry.example.com/some/repo@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2/json").ReturnsString(http.StatusOK, `{}`)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	reg, err := docker.NewRegistry()
+	require.NoError(t, err)
+
+	_, err = reg.Resolve(context.Background(), image.Image{
+		Registry:   "registry.example.com",
+		Repository: "some/repo",
+		Digest:     "sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2",
+	}, &aura.RegistryAuth{Username: "user", Password: "pass"}, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "registry.example.com/some/repo", fromImage)
+	assert.Equal(t, authHeader, distAuthHeader)
+	b, err := base64.URLEncoding.DecodeString(authHeader)
+	require.NoError(t, err)
+	var got struct {
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		ServerAddress string `json:"serveraddress"`
+	}
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, "user", got.Username)
+	assert.Equal(t, "pass", got.Password)
+	assert.Equal(t, "registry.example.com", got.ServerAddress)
+	srv.AssertExpectations()
+}
+
+func TestRegistry_ResolveHandlesIndex(t *testing.T) {
+	const (
+		amdDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		armDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+		v7Digest  = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+	)
+
+	tests := []struct {
+		name         string
+		platform     image.Platform
+		imgPlatform  image.Platform
+		wantDigest   string
+		wantPlatform image.Platform
+		wantErr      string
+	}{
+		{
+			name:         "handles daemon platform",
+			wantDigest:   amdDigest,
+			wantPlatform: image.Platform{OS: "linux", Architecture: "amd64"},
+		},
+		{
+			name:         "handles registry platform",
+			platform:     image.Platform{OS: "linux", Architecture: "arm64"},
+			wantDigest:   armDigest,
+			wantPlatform: image.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+		},
+		{
+			name:         "handles image platform",
+			platform:     image.Platform{OS: "linux", Architecture: "arm64"},
+			imgPlatform:  image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			wantDigest:   v7Digest,
+			wantPlatform: image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		},
+		{
+			name:        "handles unknown platform",
+			imgPlatform: image.Platform{OS: "windows", Architecture: "amd64"},
+			wantErr:     "no manifest for platform windows/amd64",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			index, err := json.Marshal(map[string]any{
+				"schemaVersion": 2,
+				"mediaType":     oci.MediaTypeOCIIndex,
+				"manifests": []map[string]any{
+					{"mediaType": oci.MediaTypeOCIManifest, "digest": amdDigest, "size": 1, "platform": map[string]string{"os": "linux", "architecture": "amd64"}},
+					{"mediaType": oci.MediaTypeOCIManifest, "digest": armDigest, "size": 1, "platform": map[string]string{"os": "linux", "architecture": "arm64", "variant": "v8"}},
+					{"mediaType": oci.MediaTypeOCIManifest, "digest": v7Digest, "size": 1, "platform": map[string]string{"os": "linux", "architecture": "arm", "variant": "v7"}},
+				},
+			})
+			require.NoError(t, err)
+			sum := sha256.Sum256(index)
+			indexDigest := "sha256:" + hex.EncodeToString(sum[:])
+
+			regSrv := stdhttptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/v2/some/repo/manifests/"+indexDigest {
+					http.NotFound(rw, req)
+					return
+				}
+				rw.Header().Set("Content-Type", oci.MediaTypeOCIIndex)
+				rw.Header().Set("Docker-Content-Digest", indexDigest)
+				_, _ = rw.Write(index)
+			}))
+			t.Cleanup(regSrv.Close)
+			host := strings.TrimPrefix(regSrv.URL, "https://")
+
+			var gotFromImage, gotTag string
+			srv := httptest.NewServer(t)
+			srv.On(http.MethodGet, "/distribution/"+host+"/some/repo:1.0/json").ReturnsString(http.StatusOK, `{"Descriptor":{"mediaType":"`+oci.MediaTypeOCIIndex+`","digest":"`+indexDigest+`"},`+
+				`"Platforms":[{"os":"linux","architecture":"amd64"},{"os":"linux","architecture":"arm64","variant":"v8"},{"os":"linux","architecture":"arm","variant":"v7"}]}`)
+			if test.platform.IsZero() && test.imgPlatform.IsZero() {
+				srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"Os":"linux","Arch":"amd64"}`)
+			}
+			if test.wantErr == "" {
+				srv.On(http.MethodPost, "/images/create").Handle(func(rw http.ResponseWriter, req *http.Request) {
+					gotFromImage = req.URL.Query().Get("fromImage")
+					gotTag = req.URL.Query().Get("tag")
+					_, _ = rw.Write([]byte(`{}`))
+				})
+			}
+
+			cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+			t.Cleanup(cancel)
+
+			reg, err := docker.NewRegistry(docker.WithPlatform(test.platform), docker.WithRegistryHTTPClient(regSrv.Client()))
+			require.NoError(t, err)
+
+			got, err := reg.Resolve(context.Background(), image.Image{
+				Registry:   host,
+				Repository: "some/repo",
+				Tag:        "1.0",
+				Platform:   test.imgPlatform,
+			}, nil, nil)
+
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				srv.AssertExpectations()
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantDigest, got.Digest)
+			assert.Equal(t, indexDigest, got.IndexDigest)
+			assert.Equal(t, test.wantPlatform, got.Platform)
+			assert.Equal(t, host+"/some/repo", gotFromImage)
+			assert.Equal(t, test.wantDigest, gotTag)
+			srv.AssertExpectations()
+		})
+	}
+}
+
+func TestRegistry_ResolveHandlesDistributionError(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/distribution/some/repo:latest/json").ReturnsString(http.StatusUnauthorized, `{"message":"access denied"}`)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	reg, err := docker.NewRegistry()
+	require.NoError(t, err)
+
+	_, err = reg.Resolve(context.Background(), image.Image{
+		Repository: "some/repo",
+		Tag:        "latest",
+	}, nil, nil)
+
+	require.EqualError(t, err, "inspecting distribution: access denied")
+	srv.AssertExpectations()
+}
+
 func TestRegistry_ResolveHandlesNoDigest(t *testing.T) {
 	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/distribution/some/repo:latest/json").ReturnsString(http.StatusOK, testDistribution)
 	srv.On(http.MethodPost, "/images/create").ReturnsString(http.StatusOK, `{}`)
 	srv.On(http.MethodGet, "/images/some/repo:latest/json").ReturnsString(http.StatusOK, `{"RepoDigests":[]}}`)
 
@@ -78,7 +390,7 @@ func TestRegistry_ResolveHandlesNoDigest(t *testing.T) {
 	got, err := reg.Resolve(context.Background(), image.Image{
 		Repository: "some/repo",
 		Tag:        "latest",
-	})
+	}, nil, nil)
 
 	want := image.Image{
 		Repository: "some/repo",
@@ -91,6 +403,7 @@ func TestRegistry_ResolveHandlesNoDigest(t *testing.T) {
 
 func TestRegistry_ResolveHandlesPullError(t *testing.T) {
 	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/distribution/some/repo:latest/json").ReturnsString(http.StatusOK, testDistribution)
 	srv.On(http.MethodPost, "/images/create").Returns(http.StatusInternalServerError, nil)
 
 	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
@@ -102,7 +415,7 @@ func TestRegistry_ResolveHandlesPullError(t *testing.T) {
 	_, err = reg.Resolve(context.Background(), image.Image{
 		Repository: "some/repo",
 		Tag:        "latest",
-	})
+	}, nil, nil)
 
 	require.Error(t, err)
 	srv.AssertExpectations()
@@ -110,6 +423,7 @@ func TestRegistry_ResolveHandlesPullError(t *testing.T) {
 
 func TestRegistry_ResolveHandlesInspectError(t *testing.T) {
 	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/distribution/some/repo:latest/json").ReturnsString(http.StatusOK, testDistribution)
 	srv.On(http.MethodPost, "/images/create").ReturnsString(http.StatusOK, `{}`)
 	srv.On(http.MethodGet, "/images/some/repo:latest/json").Returns(http.StatusInternalServerError, nil)
 
@@ -122,12 +436,69 @@ func TestRegistry_ResolveHandlesInspectError(t *testing.T) {
 	_, err = reg.Resolve(context.Background(), image.Image{
 		Repository: "some/repo",
 		Tag:        "latest",
-	})
+	}, nil, nil)
 
 	require.Error(t, err)
 	srv.AssertExpectations()
 }
 
+func TestRegistry_ResolveReportsProgress(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/distribution/some/repo:latest/json").ReturnsString(http.StatusOK, testDistribution)
+	srv.On(http.MethodPost, "/images/create").ReturnsString(http.StatusOK, `{"status":"Pulling from some/repo","id":"latest"}
+{"status":"Downloading","progressDetail":{"current":512,"total":1024},"progress":"[=====>     ]","id":"abc"}
+{"status":"Download complete","progressDetail":{},"id":"abc"}`)
+	srv.On(http.MethodGet, "/images/some/repo:latest/json").ReturnsString(http.StatusOK, `{"RepoDigests":[]}}`)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	reg, err := docker.NewRegistry()
+	require.NoError(t, err)
+
+	var got []aura.PullProgress
+	_, err = reg.Resolve(context.Background(), image.Image{
+		Repository: "some/repo",
+		Tag:        "latest",
+	}, nil, func(p aura.PullProgress) {
+		got = append(got, p)
+	})
+
+	want := []aura.PullProgress{
+		{ID: "latest", Status: "Pulling from some/repo"},
+		{ID: "abc", Status: "Downloading", Progress: "[=====>     ]", Current: 512, Total: 1024},
+		{ID: "abc", Status: "Download complete"},
+	}
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	srv.AssertExpectations()
+}
+
+func TestRegistry_ResolveHandlesProgressError(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/distribution/some/repo:latest/json").ReturnsString(http.StatusOK, testDistribution)
+	srv.On(http.MethodPost, "/images/create").ReturnsString(http.StatusOK, `{"status":"Pulling from some/repo","id":"latest"}
+{"error":"manifest unknown"}`)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	reg, err := docker.NewRegistry()
+	require.NoError(t, err)
+
+	var got []aura.PullProgress
+	_, err = reg.Resolve(context.Background(), image.Image{
+		Repository: "some/repo",
+		Tag:        "latest",
+	}, nil, func(p aura.PullProgress) {
+		got = append(got, p)
+	})
+
+	require.EqualError(t, err, "pulling image: manifest unknown")
+	assert.Len(t, got, 1)
+	srv.AssertExpectations()
+}
+
 func TestRegistry_ExtractProcfile(t *testing.T) {
 	procFile := "web: test"
 
@@ -148,7 +519,7 @@ func TestRegistry_ExtractProcfile(t *testing.T) {
 	reg, err := docker.NewRegistry()
 	require.NoError(t, err)
 
-	got, err := reg.ExtractProcfile(context.Background(), "foo/bar:latest")
+	got, err := reg.ExtractProcfile(context.Background(), "foo/bar:latest", nil)
 
 	require.NoError(t, err)
 	assert.Equal(t, []byte(procFile), got)
@@ -175,13 +546,69 @@ func TestRegistry_ExtractProcfileHandlesWorkingDirectory(t *testing.T) {
 	reg, err := docker.NewRegistry()
 	require.NoError(t, err)
 
-	got, err := reg.ExtractProcfile(context.Background(), "foo/bar:latest")
+	got, err := reg.ExtractProcfile(context.Background(), "foo/bar:latest", nil)
 
 	require.NoError(t, err)
 	assert.Equal(t, []byte(procFile), got)
 	srv.AssertExpectations()
 }
 
+func TestRegistry_ExtractProcfileCleansUpCancelledExtraction(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodPost, "/containers/create").ReturnsString(http.StatusOK, `{"ID": "foo"}`)
+	srv.On(http.MethodGet, "/containers/foo/json").ReturnsString(http.StatusOK, `{}`)
+	srv.On(http.MethodGet, "/containers/foo/archive").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		cancel()
+		<-req.Context().Done()
+	})
+	srv.On(http.MethodDelete, "/containers/foo").ReturnsString(http.StatusOK, `{}`)
+
+	swap := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(swap)
+
+	reg, err := docker.NewRegistry()
+	require.NoError(t, err)
+
+	_, err = reg.ExtractProcfile(ctx, "foo/bar:latest", nil)
+
+	require.Error(t, err)
+	// Wait for the cancelled request to finish.
+	srv.Close()
+	srv.AssertExpectations()
+}
+
+func TestRegistry_ExtractProcfileCleansUpCancelledCreate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodPost, "/containers/create").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		assert.True(t, strings.HasPrefix(req.URL.Query().Get("name"), "aura-extract-"))
+
+		// The closed connection is only noticed once the body is read.
+		_, _ = io.Copy(io.Discard, req.Body)
+		cancel()
+		<-req.Context().Done()
+	})
+	srv.On(http.MethodDelete, "/containers/aura-extract-*").ReturnsString(http.StatusOK, `{}`)
+
+	swap := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(swap)
+
+	reg, err := docker.NewRegistry()
+	require.NoError(t, err)
+
+	_, err = reg.ExtractProcfile(ctx, "foo/bar:latest", nil)
+
+	require.Error(t, err)
+	// Wait for the cancelled request to finish.
+	srv.Close()
+	srv.AssertExpectations()
+}
+
 func TestRegistry_ExtractProcfileHandlesCreateContainerError(t *testing.T) {
 	srv := httptest.NewServer(t)
 	srv.On(http.MethodPost, "/containers/create").ReturnsString(http.StatusInternalServerError, ``)
@@ -192,7 +619,7 @@ func TestRegistry_ExtractProcfileHandlesCreateContainerError(t *testing.T) {
 	reg, err := docker.NewRegistry()
 	require.NoError(t, err)
 
-	_, err = reg.ExtractProcfile(context.Background(), "foo/bar:latest")
+	_, err = reg.ExtractProcfile(context.Background(), "foo/bar:latest", nil)
 
 	require.Error(t, err)
 	srv.AssertExpectations()
@@ -210,7 +637,7 @@ func TestRegistry_ExtractProcfileHandlesInspectError(t *testing.T) {
 	reg, err := docker.NewRegistry()
 	require.NoError(t, err)
 
-	_, err = reg.ExtractProcfile(context.Background(), "nrwiersma/test:latest")
+	_, err = reg.ExtractProcfile(context.Background(), "nrwiersma/test:latest", nil)
 
 	require.Error(t, err)
 	srv.AssertExpectations()
@@ -229,12 +656,14 @@ func TestRegistry_ExtractProcfileHandlesDownloadError(t *testing.T) {
 	reg, err := docker.NewRegistry()
 	require.NoError(t, err)
 
-	_, err = reg.ExtractProcfile(context.Background(), "foo/bar:latest")
+	_, err = reg.ExtractProcfile(context.Background(), "foo/bar:latest", nil)
 
 	require.Error(t, err)
 	srv.AssertExpectations()
 }
 
+const testDistribution = `{"Descriptor":{"mediaType":"application/vnd.docker.distribution.manifest.v2+json","digest":"sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"}}`
+
 func swapEnv(t *testing.T, key, value string) (cancel func()) {
 	t.Helper()
 
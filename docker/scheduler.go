@@ -0,0 +1,307 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/procfile"
+)
+
+// Container labels used to track the containers of an application.
+const (
+	labelApp     = "aura.app"
+	labelRelease = "aura.release"
+	labelVersion = "aura.version"
+	labelProcess = "aura.process"
+	// labelRun marks one-off process containers, which are
+	// not replaced by new releases.
+	labelRun = "aura.run"
+)
+
+// Scheduler schedules applications as docker containers.
+type Scheduler struct {
+	client *docker.Client
+}
+
+// NewScheduler returns a scheduler.
+func NewScheduler() (*Scheduler, error) {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("could not create docker client: %w", err)
+	}
+
+	return &Scheduler{
+		client: client,
+	}, nil
+}
+
+// Submit runs containers for each process in the release as given by
+// the formation, replacing the containers of any previous release.
+//
+// The port of a process is published on an ephemeral host port, as a
+// process can have several containers on the host.
+func (s *Scheduler) Submit(ctx context.Context, app *aura.App, release *aura.Release, formation []*aura.Formation) error {
+	procs, err := procfile.Parse(release.Procfile)
+	if err != nil {
+		return fmt.Errorf("parsing procfile: %w", err)
+	}
+
+	old, err := s.listContainers(ctx, app.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.ensureImage(ctx, release); err != nil {
+		return err
+	}
+
+	ctrIDs := make([]string, 0, len(procs))
+	for _, proc := range procs {
+		if proc.Schedule != "" || proc.Name == aura.ReleaseProcess {
+			// Scheduled and release processes are not long-running.
+			continue
+		}
+
+		scale := aura.ProcessScale(proc, formation)
+		for i := 0; i < scale.Quantity; i++ {
+			id, err := s.runContainer(ctx, app, release, proc, scale)
+			if err != nil {
+				// Clean up the new containers, leaving the old release running.
+				for _, ctrID := range ctrIDs {
+					_ = cleanupContainer(s.client, ctrID, true)
+				}
+				return fmt.Errorf("running process %q: %w", proc.Name, err)
+			}
+			ctrIDs = append(ctrIDs, id)
+		}
+	}
+
+	for _, ctr := range old {
+		if _, ok := ctr.Labels[labelRun]; ok {
+			continue
+		}
+		if err = removeContainer(ctx, s.client, ctr.ID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes all containers of an application.
+func (s *Scheduler) Remove(ctx context.Context, app *aura.App) error {
+	ctrs, err := s.listContainers(ctx, app.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, ctr := range ctrs {
+		if err = removeContainer(ctx, s.client, ctr.ID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Processes returns the processes of an application.
+func (s *Scheduler) Processes(ctx context.Context, app *aura.App) ([]*aura.Process, error) {
+	ctrs, err := s.listContainers(ctx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	procs := make([]*aura.Process, 0, len(ctrs))
+	for _, ctr := range ctrs {
+		ver, _ := strconv.Atoi(ctr.Labels[labelVersion])
+		created := time.Unix(ctr.Created, 0).UTC()
+
+		procs = append(procs, &aura.Process{
+			ID:        ctr.ID,
+			Type:      ctr.Labels[labelProcess],
+			Command:   ctr.Command,
+			Version:   ver,
+			State:     ctr.State,
+			CreatedAt: &created,
+		})
+	}
+	return procs, nil
+}
+
+// Run runs a one-off process in a container.
+//
+// Attached runs wait for the process to exit, capturing its exit code
+// and output, before the container is removed. Detached runs remove
+// their container when the process exits.
+func (s *Scheduler) Run(ctx context.Context, app *aura.App, release *aura.Release, run *aura.Run) error {
+	if err := s.ensureImage(ctx, release); err != nil {
+		return err
+	}
+
+	cfg := &docker.Config{
+		Image: release.Image.String(),
+		Cmd:   []string{"/bin/sh", "-c", run.Command},
+		Labels: map[string]string{
+			labelApp:     app.ID,
+			labelRelease: release.ID,
+			labelVersion: strconv.Itoa(release.Version),
+			labelProcess: "run",
+			labelRun:     "true",
+		},
+	}
+	cfg.Env = env(release)
+
+	id, err := createContainer(ctx, s.client, "", cfg, &docker.HostConfig{
+		AutoRemove: !run.Attach,
+	})
+	if err != nil {
+		return err
+	}
+	run.ID = id
+
+	if err = s.client.StartContainerWithContext(id, nil, ctx); err != nil {
+		_ = cleanupContainer(s.client, id, true)
+		return fmt.Errorf("starting container: %w", err)
+	}
+
+	if !run.Attach {
+		return nil
+	}
+	defer func() { _ = cleanupContainer(s.client, id, true) }()
+
+	exitCode, err := s.client.WaitContainerWithContext(id, ctx)
+	if err != nil {
+		return fmt.Errorf("waiting for container: %w", err)
+	}
+	run.ExitCode = &exitCode
+
+	var buf bytes.Buffer
+	if err = s.client.Logs(docker.LogsOptions{
+		Container:    id,
+		OutputStream: &buf,
+		ErrorStream:  &buf,
+		Stdout:       true,
+		Stderr:       true,
+		Context:      ctx,
+	}); err != nil {
+		return fmt.Errorf("reading container logs: %w", err)
+	}
+	run.Output = buf.Bytes()
+
+	return nil
+}
+
+func (s *Scheduler) runContainer(ctx context.Context, app *aura.App, release *aura.Release, proc procfile.Process, scale aura.Scale) (string, error) {
+	cfg := &docker.Config{
+		Image: release.Image.String(),
+		Cmd:   []string{"/bin/sh", "-c", proc.Command},
+		Labels: map[string]string{
+			labelApp:     app.ID,
+			labelRelease: release.ID,
+			labelVersion: strconv.Itoa(release.Version),
+			labelProcess: proc.Name,
+		},
+	}
+	cfg.Env = env(release)
+	hostCfg := &docker.HostConfig{
+		RestartPolicy: docker.RestartUnlessStopped(),
+		Memory:        scale.Memory,
+		NanoCPUs:      int64(scale.CPU * 1e9),
+	}
+	if proc.Port > 0 {
+		port := strconv.Itoa(proc.Port)
+		cfg.Env = append(cfg.Env, "PORT="+port)
+		cfg.ExposedPorts = map[docker.Port]struct{}{docker.Port(port + "/tcp"): {}}
+		// An empty host port lets the daemon pick a free port.
+		hostCfg.PortBindings = map[docker.Port][]docker.PortBinding{docker.Port(port + "/tcp"): {{}}}
+	}
+
+	id, err := createContainer(ctx, s.client, "", cfg, hostCfg)
+	if err != nil {
+		return "", err
+	}
+
+	if err = s.client.StartContainerWithContext(id, nil, ctx); err != nil {
+		_ = cleanupContainer(s.client, id, true)
+		return "", fmt.Errorf("starting container: %w", err)
+	}
+	return id, nil
+}
+
+// ensureImage pulls the image of the release if the daemon does not have it.
+// The image may have been resolved without the daemon, or pruned since.
+func (s *Scheduler) ensureImage(ctx context.Context, release *aura.Release) error {
+	img := *release.Image
+	_, err := s.client.InspectImage(img.String())
+	switch {
+	case err == nil:
+		return nil
+	case !errors.Is(err, docker.ErrNoSuchImage):
+		return fmt.Errorf("inspecting image: %w", err)
+	}
+
+	opts := docker.PullImageOptions{
+		Repository: pullRepository(img),
+		Tag:        img.Digest,
+		Context:    ctx,
+	}
+	if opts.Tag == "" {
+		opts.Tag = img.Tag
+		if opts.Tag == "" {
+			opts.Tag = "latest"
+		}
+		if release.Platform != nil {
+			opts.Platform = release.Platform.String()
+		}
+	}
+	if err = s.client.PullImage(opts, authConfig(img, release.Auth)); err != nil {
+		return fmt.Errorf("pulling image: %w", err)
+	}
+	return nil
+}
+
+func (s *Scheduler) listContainers(ctx context.Context, appID string) ([]docker.APIContainers, error) {
+	ctrs, err := s.client.ListContainers(docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {labelApp + "=" + appID},
+		},
+		Context: ctx,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+	return ctrs, nil
+}
+
+// env returns the environment of the processes of a release.
+//
+// Secrets take precedence over config vars of the same name.
+func env(release *aura.Release) []string {
+	vars := aura.Vars{}
+	if release.Config != nil {
+		for k, v := range release.Config.Vars {
+			vars[k] = v
+		}
+	}
+	for k, v := range release.Secrets {
+		vars[k] = v
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+vars[k])
+	}
+	return env
+}
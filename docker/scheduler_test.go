@@ -0,0 +1,582 @@
+package docker_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	httptest "github.com/hamba/testutils/http"
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/docker"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_Submit(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, `{"label":["aura.app=123"]}`, req.URL.Query().Get("filters"))
+
+		_, _ = rw.Write([]byte(`[{"Id":"old"}]`))
+	})
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusOK, `{"Id":"abc"}`)
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		var ctr struct {
+			Image  string
+			Cmd    []string
+			Labels map[string]string
+		}
+		err := json.NewDecoder(req.Body).Decode(&ctr)
+		require.NoError(t, err)
+
+		assert.Equal(t, "foo/bar:latest", ctr.Image)
+		assert.Equal(t, []string{"/bin/sh", "-c", "./app"}, ctr.Cmd)
+		assert.Equal(t, map[string]string{
+			"aura.app":     "123",
+			"aura.release": "456",
+			"aura.version": "2",
+			"aura.process": "web",
+		}, ctr.Labels)
+
+		_, _ = rw.Write([]byte(`{"Id":"new"}`))
+	})
+	srv.On(http.MethodPost, "/containers/new/start").ReturnsStatus(http.StatusNoContent)
+	srv.On(http.MethodDelete, "/containers/old").ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Submit(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:       "456",
+		Image:    &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version:  2,
+		Procfile: []byte("release: ./migrate\nweb: ./app"),
+	}, nil)
+
+	require.NoError(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_SubmitHandlesExtendedProcfile(t *testing.T) {
+	procfile := `web:
+  command: ./app
+  port: 8080
+  instances: 2
+  memory: 512m
+  cpu: 0.5
+cron:
+  command: ./job
+  schedule: "@hourly"
+`
+
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsString(http.StatusOK, `[]`)
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusOK, `{"Id":"abc"}`)
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").Times(2).Handle(func(rw http.ResponseWriter, req *http.Request) {
+		var ctr struct {
+			Cmd          []string
+			Env          []string
+			ExposedPorts map[string]struct{}
+			HostConfig   struct {
+				Memory       int64
+				NanoCPUs     int64
+				PortBindings map[string][]struct {
+					HostIP   string
+					HostPort string
+				}
+			}
+		}
+		err := json.NewDecoder(req.Body).Decode(&ctr)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"/bin/sh", "-c", "./app"}, ctr.Cmd)
+		assert.Equal(t, []string{"BAR=baz", "FOO=bar", "PORT=8080"}, ctr.Env)
+		assert.Equal(t, map[string]struct{}{"8080/tcp": {}}, ctr.ExposedPorts)
+		require.Contains(t, ctr.HostConfig.PortBindings, "8080/tcp")
+		assert.Len(t, ctr.HostConfig.PortBindings["8080/tcp"], 1)
+		assert.Empty(t, ctr.HostConfig.PortBindings["8080/tcp"][0].HostPort)
+		assert.Equal(t, int64(512<<20), ctr.HostConfig.Memory)
+		assert.Equal(t, int64(5e8), ctr.HostConfig.NanoCPUs)
+
+		_, _ = rw.Write([]byte(`{"Id":"new"}`))
+	})
+	srv.On(http.MethodPost, "/containers/new/start").Times(2).ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Submit(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:       "456",
+		Image:    &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version:  2,
+		Procfile: []byte(procfile),
+		Config:   &aura.Config{Vars: aura.Vars{"FOO": "bar", "BAR": "baz"}},
+	}, nil)
+
+	require.NoError(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_SubmitPassesSecrets(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsString(http.StatusOK, `[]`)
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusOK, `{"Id":"abc"}`)
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		var ctr struct {
+			Env []string
+		}
+		err := json.NewDecoder(req.Body).Decode(&ctr)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"DB_PASS=hunter2", "FOO=bar", "TOKEN=secret"}, ctr.Env)
+
+		_, _ = rw.Write([]byte(`{"Id":"new"}`))
+	})
+	srv.On(http.MethodPost, "/containers/new/start").ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Submit(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:       "456",
+		Image:    &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version:  2,
+		Procfile: []byte("web: ./app"),
+		Config:   &aura.Config{Vars: aura.Vars{"FOO": "bar", "TOKEN": "plain"}},
+		Secrets:  aura.Vars{"DB_PASS": "hunter2", "TOKEN": "secret"},
+	}, nil)
+
+	require.NoError(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_SubmitPullsMissingImage(t *testing.T) {
+	const digest = "sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"
+
+	var gotFromImage, gotTag string
+	var gotAuth struct {
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		ServerAddress string `json:"serveraddress"`
+	}
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsString(http.StatusOK, `[]`)
+	srv.On(http.MethodGet, "/images/ghcr.io/foo/bar@"+digest+"/json").ReturnsString(http.StatusNotFound, `{"message":"no such image"}`)
+	srv.On(http.MethodPost, "/images/create").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		gotFromImage = req.URL.Query().Get("fromImage")
+		gotTag = req.URL.Query().Get("tag")
+		b, err := base64.URLEncoding.DecodeString(req.Header.Get("X-Registry-Auth"))
+		require.NoError(t, err)
+		err = json.Unmarshal(b, &gotAuth)
+		require.NoError(t, err)
+		_, _ = rw.Write([]byte(`{}`))
+	})
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").ReturnsString(http.StatusOK, `{"Id":"new"}`)
+	srv.On(http.MethodPost, "/containers/new/start").ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Submit(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:       "456",
+		Image:    &image.Image{Registry: "ghcr.io", Repository: "foo/bar", Digest: digest},
+		Version:  2,
+		Procfile: []byte("web: ./app"),
+		Auth:     &aura.RegistryAuth{Username: "user", Password: "pass"},
+	}, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr.io/foo/bar", gotFromImage)
+	assert.Equal(t, digest, gotTag)
+	assert.Equal(t, "user", gotAuth.Username)
+	assert.Equal(t, "pass", gotAuth.Password)
+	assert.Equal(t, "ghcr.io", gotAuth.ServerAddress)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_SubmitHandlesPullError(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsString(http.StatusOK, `[{"Id":"old"}]`)
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusNotFound, `{"message":"no such image"}`)
+	srv.On(http.MethodPost, "/images/create").ReturnsString(http.StatusNotFound, `{"message":"manifest unknown"}`)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Submit(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:       "456",
+		Image:    &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version:  2,
+		Procfile: []byte("web: ./app"),
+	}, nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pulling image")
+	srv.AssertExpectations()
+}
+
+func TestScheduler_RunPullsMissingImage(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusNotFound, `{"message":"no such image"}`)
+	srv.On(http.MethodPost, "/images/create").ReturnsString(http.StatusOK, `{}`)
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").ReturnsString(http.StatusOK, `{"Id":"run"}`)
+	srv.On(http.MethodPost, "/containers/run/start").ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Run(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:      "456",
+		Image:   &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version: 2,
+	}, &aura.Run{Command: "./job"})
+
+	require.NoError(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_SubmitHandlesFormation(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsString(http.StatusOK, `[]`)
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusOK, `{"Id":"abc"}`)
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").Times(3).Handle(func(rw http.ResponseWriter, req *http.Request) {
+		var ctr struct {
+			Cmd        []string
+			HostConfig struct {
+				Memory   int64
+				NanoCPUs int64
+			}
+		}
+		err := json.NewDecoder(req.Body).Decode(&ctr)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"/bin/sh", "-c", "./app"}, ctr.Cmd)
+		assert.Equal(t, int64(1<<30), ctr.HostConfig.Memory)
+		assert.Equal(t, int64(1e9), ctr.HostConfig.NanoCPUs)
+
+		_, _ = rw.Write([]byte(`{"Id":"new"}`))
+	})
+	srv.On(http.MethodPost, "/containers/new/start").Times(3).ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Submit(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:       "456",
+		Image:    &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version:  2,
+		Procfile: []byte("web: ./app\nworker: ./worker"),
+	}, []*aura.Formation{
+		{Process: "web", Quantity: 3, Size: "large"},
+		{Process: "worker", Quantity: 0},
+	})
+
+	require.NoError(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_SubmitHandlesStartError(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsString(http.StatusOK, `[{"Id":"old"}]`)
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusOK, `{"Id":"abc"}`)
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").ReturnsString(http.StatusOK, `{"Id":"new"}`)
+	srv.On(http.MethodPost, "/containers/new/start").ReturnsStatus(http.StatusInternalServerError)
+	srv.On(http.MethodDelete, "/containers/new").ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Submit(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:       "456",
+		Image:    &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version:  2,
+		Procfile: []byte("web: ./app"),
+	}, nil)
+
+	require.Error(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_SubmitHandlesListError(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsStatus(http.StatusInternalServerError)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Submit(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:       "456",
+		Image:    &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version:  2,
+		Procfile: []byte("web: ./app"),
+	}, nil)
+
+	require.Error(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_SubmitKeepsRunContainers(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsString(http.StatusOK, `[{"Id":"old"},{"Id":"run","Labels":{"aura.run":"true"}}]`)
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusOK, `{"Id":"abc"}`)
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").ReturnsString(http.StatusOK, `{"Id":"new"}`)
+	srv.On(http.MethodPost, "/containers/new/start").ReturnsStatus(http.StatusNoContent)
+	srv.On(http.MethodDelete, "/containers/old").ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Submit(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:       "456",
+		Image:    &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version:  2,
+		Procfile: []byte("web: ./app"),
+	}, nil)
+
+	require.NoError(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_Run(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusOK, `{"Id":"abc"}`)
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		var ctr struct {
+			Image      string
+			Cmd        []string
+			Env        []string
+			Labels     map[string]string
+			HostConfig struct {
+				AutoRemove bool
+			}
+		}
+		err := json.NewDecoder(req.Body).Decode(&ctr)
+		require.NoError(t, err)
+
+		assert.Equal(t, "foo/bar:latest", ctr.Image)
+		assert.Equal(t, []string{"/bin/sh", "-c", "rake db:migrate"}, ctr.Cmd)
+		assert.Equal(t, []string{"FOO=bar", "TOKEN=secret"}, ctr.Env)
+		assert.Equal(t, map[string]string{
+			"aura.app":     "123",
+			"aura.release": "456",
+			"aura.version": "2",
+			"aura.process": "run",
+			"aura.run":     "true",
+		}, ctr.Labels)
+		assert.False(t, ctr.HostConfig.AutoRemove)
+
+		_, _ = rw.Write([]byte(`{"Id":"run"}`))
+	})
+	srv.On(http.MethodPost, "/containers/run/start").ReturnsStatus(http.StatusNoContent)
+	srv.On(http.MethodPost, "/containers/run/wait").ReturnsString(http.StatusOK, `{"StatusCode":1}`)
+	srv.On(http.MethodGet, "/containers/run/logs").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write(stdFrame(1, "migrating\n"))
+		_, _ = rw.Write(stdFrame(2, "failed\n"))
+	})
+	srv.On(http.MethodDelete, "/containers/run").ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	run := &aura.Run{Command: "rake db:migrate", Attach: true}
+	err = sched.Run(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:      "456",
+		Image:   &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version: 2,
+		Config:  &aura.Config{Vars: aura.Vars{"FOO": "bar"}},
+		Secrets: aura.Vars{"TOKEN": "secret"},
+	}, run)
+
+	require.NoError(t, err)
+	assert.Equal(t, "run", run.ID)
+	require.NotNil(t, run.ExitCode)
+	assert.Equal(t, 1, *run.ExitCode)
+	assert.Equal(t, "migrating\nfailed\n", string(run.Output))
+	srv.AssertExpectations()
+}
+
+func TestScheduler_RunHandlesDetached(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusOK, `{"Id":"abc"}`)
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").Handle(func(rw http.ResponseWriter, req *http.Request) {
+		var ctr struct {
+			HostConfig struct {
+				AutoRemove bool
+			}
+		}
+		err := json.NewDecoder(req.Body).Decode(&ctr)
+		require.NoError(t, err)
+
+		assert.True(t, ctr.HostConfig.AutoRemove)
+
+		_, _ = rw.Write([]byte(`{"Id":"run"}`))
+	})
+	srv.On(http.MethodPost, "/containers/run/start").ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	run := &aura.Run{Command: "./job"}
+	err = sched.Run(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:      "456",
+		Image:   &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version: 2,
+	}, run)
+
+	require.NoError(t, err)
+	assert.Equal(t, "run", run.ID)
+	assert.Nil(t, run.ExitCode)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_RunHandlesStartError(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/images/foo/bar:latest/json").ReturnsString(http.StatusOK, `{"Id":"abc"}`)
+	srv.On(http.MethodGet, "/version").ReturnsString(http.StatusOK, `{"ApiVersion":"1.41"}`)
+	srv.On(http.MethodPost, "/containers/create").ReturnsString(http.StatusOK, `{"Id":"run"}`)
+	srv.On(http.MethodPost, "/containers/run/start").ReturnsStatus(http.StatusInternalServerError)
+	srv.On(http.MethodDelete, "/containers/run").ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Run(context.Background(), &aura.App{ID: "123"}, &aura.Release{
+		ID:      "456",
+		Image:   &image.Image{Repository: "foo/bar", Tag: "latest"},
+		Version: 2,
+	}, &aura.Run{Command: "./job", Attach: true})
+
+	require.Error(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsString(http.StatusOK, `[{"Id":"foo"},{"Id":"bar"}]`)
+	srv.On(http.MethodDelete, "/containers/foo").ReturnsStatus(http.StatusNoContent)
+	srv.On(http.MethodDelete, "/containers/bar").ReturnsStatus(http.StatusNoContent)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Remove(context.Background(), &aura.App{ID: "123"})
+
+	require.NoError(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_RemoveHandlesRemoveError(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsString(http.StatusOK, `[{"Id":"foo"}]`)
+	srv.On(http.MethodDelete, "/containers/foo").ReturnsStatus(http.StatusInternalServerError)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	err = sched.Remove(context.Background(), &aura.App{ID: "123"})
+
+	require.Error(t, err)
+	srv.AssertExpectations()
+}
+
+func TestScheduler_Processes(t *testing.T) {
+	srv := httptest.NewServer(t)
+	srv.On(http.MethodGet, "/containers/json").ReturnsString(http.StatusOK, `[{
+	"Id":"foo",
+	"Command":"/bin/sh -c ./app",
+	"Created":1643688000,
+	"State":"running",
+	"Labels":{"aura.app":"123","aura.release":"456","aura.version":"2","aura.process":"web"}
+}]`)
+
+	cancel := swapEnv(t, "DOCKER_HOST", srv.URL())
+	t.Cleanup(cancel)
+
+	sched, err := docker.NewScheduler()
+	require.NoError(t, err)
+
+	got, err := sched.Processes(context.Background(), &aura.App{ID: "123"})
+
+	require.NoError(t, err)
+	created := time.Date(2022, 02, 01, 04, 00, 0, 0, time.UTC)
+	want := []*aura.Process{
+		{
+			ID:        "foo",
+			Type:      "web",
+			Command:   "/bin/sh -c ./app",
+			Version:   2,
+			State:     "running",
+			CreatedAt: &created,
+		},
+	}
+	assert.Equal(t, want, got)
+	srv.AssertExpectations()
+}
+
+// stdFrame returns a multiplexed docker log frame.
+func stdFrame(stream byte, s string) []byte {
+	b := make([]byte, 8, 8+len(s))
+	b[0] = stream
+	binary.BigEndian.PutUint32(b[4:], uint32(len(s)))
+	return append(b, s...)
+}
@@ -1,5 +1,7 @@
 package aura
 
+import "fmt"
+
 // ValidationError is returned when there is a validation error.
 type ValidationError struct {
 	err error
@@ -15,3 +17,28 @@ func (e ValidationError) Error() string {
 
 // Unwrap returns the underlying error.
 func (e ValidationError) Unwrap() error { return e.err }
+
+// ReleasePhaseError is returned when the release phase of a release fails.
+type ReleasePhaseError struct {
+	Release  *Release
+	ExitCode int
+}
+
+// Error stringifies the error.
+func (e ReleasePhaseError) Error() string {
+	return fmt.Sprintf("release phase exited with code %d", e.ExitCode)
+}
+
+// RedeployError is returned when a change was saved, but the
+// current release could not be redeployed with it.
+type RedeployError struct {
+	Err error
+}
+
+// Error stringifies the error.
+func (e RedeployError) Error() string {
+	return fmt.Sprintf("could not redeploy: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e RedeployError) Unwrap() error { return e.Err }
@@ -0,0 +1,18 @@
+package aura
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// NewAdvisoryLocker returns a postgres advisory locker
+// with the given backoff, for testing.
+func NewAdvisoryLocker(db *sql.DB, backoff time.Duration) interface {
+	Lock(ctx context.Context, appID string, wait bool) (func(), error)
+} {
+	l := newAdvisoryLocker(db)
+	l.minBackoff = backoff
+	l.maxBackoff = backoff
+	return l
+}
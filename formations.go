@@ -0,0 +1,282 @@
+package aura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nrwiersma/aura/pkg/procfile"
+	"github.com/segmentio/ksuid"
+	"gorm.io/gorm"
+)
+
+// Size contains the resources of a process size.
+type Size struct {
+	// Memory is the memory limit in bytes.
+	Memory int64
+	// CPU is the CPU limit in cores.
+	CPU float64
+}
+
+// Sizes contains the available process sizes.
+var Sizes = map[string]Size{
+	"small":  {Memory: 256 << 20, CPU: 0.25},
+	"medium": {Memory: 512 << 20, CPU: 0.5},
+	"large":  {Memory: 1 << 30, CPU: 1},
+	"xlarge": {Memory: 2 << 30, CPU: 2},
+}
+
+// Formation contains the desired quantity and size of a process type.
+type Formation struct {
+	ID      string
+	AppID   string
+	Process string
+	// Quantity is the number of instances of the process.
+	Quantity int
+	// Size is the name of the process size. When empty, the
+	// resources from the procfile are used.
+	Size      string
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+// BeforeCreate is a pre-creation hook.
+func (f *Formation) BeforeCreate(_ *gorm.DB) error {
+	f.ID = ksuid.New().String()
+
+	now := time.Now().UTC()
+	f.CreatedAt = &now
+	f.UpdatedAt = &now
+
+	return nil
+}
+
+// BeforeUpdate is a pre-update hook.
+func (f *Formation) BeforeUpdate(_ *gorm.DB) error {
+	now := time.Now().UTC()
+	f.UpdatedAt = &now
+
+	return nil
+}
+
+// Scale contains the quantity and resources a process runs with.
+type Scale struct {
+	Quantity int
+	Memory   int64
+	CPU      float64
+}
+
+// ProcessScale returns the scale of a process, applying the
+// formation of the process to the procfile defaults.
+func ProcessScale(proc procfile.Process, formation []*Formation) Scale {
+	scale := Scale{
+		Quantity: proc.Instances,
+		Memory:   proc.Memory,
+		CPU:      proc.CPU,
+	}
+	if scale.Quantity == 0 {
+		scale.Quantity = 1
+	}
+
+	for _, f := range formation {
+		if f.Process != proc.Name {
+			continue
+		}
+
+		scale.Quantity = f.Quantity
+		if size, ok := Sizes[f.Size]; ok {
+			scale.Memory = size.Memory
+			scale.CPU = size.CPU
+		}
+		break
+	}
+	return scale
+}
+
+type formationService struct {
+	db *DB
+}
+
+func (s *formationService) Find(ctx context.Context, scope scope) ([]*Formation, error) {
+	var formation []*Formation
+	scope = composedScope{order("process"), scope}
+	return formation, s.db.WithContext(ctx).Scopes(scope.scope).Find(&formation).Error
+}
+
+func (s *formationService) Save(ctx context.Context, formation []*Formation) error {
+	tx := s.db.WithContext(ctx).Begin()
+	defer func() { _ = tx.Rollback() }()
+
+	for _, f := range formation {
+		if f.ID == "" {
+			if err := tx.Create(f).Error; err != nil {
+				return fmt.Errorf("creating formation: %w", err)
+			}
+			continue
+		}
+
+		if err := tx.Save(f).Error; err != nil {
+			return fmt.Errorf("updating formation: %w", err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Formation returns the process formation of the current release of an application.
+//
+// Processes without a stored formation have the procfile defaults.
+func (a *Aura) Formation(ctx context.Context, app *App) ([]*Formation, error) {
+	release, err := a.currentRelease(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.formation(ctx, app, release)
+}
+
+// FormationUpdate contains the update of a process formation.
+type FormationUpdate struct {
+	Process string
+
+	// Quantity is the number of instances. Nil leaves it unchanged.
+	Quantity *int
+	// Size is the process size. Nil leaves it unchanged, while
+	// an empty size uses the procfile resources.
+	Size *string
+}
+
+// UpdateFormationConfig contains formation update configuration.
+type UpdateFormationConfig struct {
+	App *App
+
+	Updates []FormationUpdate
+}
+
+// Validate validates an update formation configuration.
+func (c UpdateFormationConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if len(c.Updates) == 0 {
+		return errors.New("at least one update is required")
+	}
+	seen := map[string]bool{}
+	for _, u := range c.Updates {
+		if u.Process == "" {
+			return errors.New("a process is required")
+		}
+		if seen[u.Process] {
+			return fmt.Errorf("duplicate process %q", u.Process)
+		}
+		seen[u.Process] = true
+		if u.Quantity != nil && *u.Quantity < 0 {
+			return fmt.Errorf("quantity of process %q must not be negative", u.Process)
+		}
+		if u.Size != nil && *u.Size != "" {
+			if _, ok := Sizes[*u.Size]; !ok {
+				return fmt.Errorf("invalid size %q for process %q", *u.Size, u.Process)
+			}
+		}
+	}
+
+	return nil
+}
+
+// UpdateFormation updates the process formation of an application
+// and applies it to the current release.
+//
+// The processes must exist in the procfile of the current release.
+func (a *Aura) UpdateFormation(ctx context.Context, cfg UpdateFormationConfig) ([]*Formation, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+
+	unlock, err := a.lockActiveApp(ctx, cfg.App, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	release, err := a.currentRelease(ctx, cfg.App)
+	if err != nil {
+		return nil, err
+	}
+
+	formation, err := a.formation(ctx, cfg.App, release)
+	if err != nil {
+		return nil, err
+	}
+	byProc := make(map[string]*Formation, len(formation))
+	for _, f := range formation {
+		byProc[f.Process] = f
+	}
+
+	changed := make([]*Formation, 0, len(cfg.Updates))
+	for _, u := range cfg.Updates {
+		f, ok := byProc[u.Process]
+		if !ok {
+			return nil, ValidationError{err: fmt.Errorf("process %q does not exist in the current release", u.Process)}
+		}
+
+		if u.Quantity != nil {
+			f.Quantity = *u.Quantity
+		}
+		if u.Size != nil {
+			f.Size = *u.Size
+		}
+		changed = append(changed, f)
+	}
+
+	if err = a.prepareRelease(ctx, cfg.App, release); err != nil {
+		return nil, err
+	}
+	if err = a.formations.Save(ctx, changed); err != nil {
+		return nil, fmt.Errorf("could not save formation: %w", err)
+	}
+
+	if err = a.sched.Submit(ctx, cfg.App, release, formation); err != nil {
+		return nil, fmt.Errorf("could not submit release: %w", err)
+	}
+
+	return formation, nil
+}
+
+// formation returns the formation of the processes of a release.
+func (a *Aura) formation(ctx context.Context, app *App, release *Release) ([]*Formation, error) {
+	procs, err := procfile.Parse(release.Procfile)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse procfile: %w", err)
+	}
+
+	stored, err := a.formations.Find(ctx, fieldEquals("app_id", app.ID))
+	if err != nil {
+		return nil, fmt.Errorf("could not find formation: %w", err)
+	}
+	byProc := make(map[string]*Formation, len(stored))
+	for _, f := range stored {
+		byProc[f.Process] = f
+	}
+
+	formation := make([]*Formation, 0, len(procs))
+	for _, proc := range procs {
+		if proc.Name == ReleaseProcess {
+			continue
+		}
+		if f, ok := byProc[proc.Name]; ok {
+			formation = append(formation, f)
+			continue
+		}
+
+		formation = append(formation, &Formation{
+			AppID:    app.ID,
+			Process:  proc.Name,
+			Quantity: ProcessScale(proc, nil).Quantity,
+		})
+	}
+	return formation, nil
+}
@@ -0,0 +1,223 @@
+package aura_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/memory"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/nrwiersma/aura/pkg/procfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAura_Formation(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web:\n  command: ./app\n  instances: 2\nworker: ./worker"), nil)
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	got, err := a.Formation(context.Background(), app)
+
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "web", got[0].Process)
+	assert.Equal(t, 2, got[0].Quantity)
+	assert.Equal(t, "worker", got[1].Process)
+	assert.Equal(t, 1, got[1].Quantity)
+}
+
+func TestAura_FormationHandlesNoRelease(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Formation(context.Background(), app)
+
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_UpdateFormation(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app\nworker: ./worker"), nil)
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	three, zero, size := 3, 0, "medium"
+	got, err := a.UpdateFormation(context.Background(), aura.UpdateFormationConfig{
+		App: app,
+		Updates: []aura.FormationUpdate{
+			{Process: "web", Quantity: &three, Size: &size},
+			{Process: "worker", Quantity: &zero},
+		},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, 3, got[0].Quantity)
+	assert.Equal(t, "medium", got[0].Size)
+	assert.Equal(t, 0, got[1].Quantity)
+	procs, err := sched.Processes(context.Background(), app)
+	require.NoError(t, err)
+	assert.Len(t, procs, 3)
+
+	// The formation is kept for new releases.
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+	procs, err = sched.Processes(context.Background(), app)
+	require.NoError(t, err)
+	require.Len(t, procs, 3)
+	assert.Equal(t, 2, procs[0].Version)
+	formation, err := a.Formation(context.Background(), app)
+	require.NoError(t, err)
+	assert.Equal(t, 3, formation[0].Quantity)
+	assert.Equal(t, "medium", formation[0].Size)
+}
+
+func TestAura_UpdateFormationHandlesUnknownProcess(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	two := 2
+	_, err = a.UpdateFormation(context.Background(), aura.UpdateFormationConfig{
+		App:     app,
+		Updates: []aura.FormationUpdate{{Process: "worker", Quantity: &two}},
+	})
+
+	assert.ErrorAs(t, err, &aura.ValidationError{})
+}
+
+func TestAura_UpdateFormationHandlesValidationError(t *testing.T) {
+	one, negative, size := 1, -1, "huge"
+
+	tests := []struct {
+		name string
+		cfg  aura.UpdateFormationConfig
+	}{
+		{
+			name: "no app",
+			cfg:  aura.UpdateFormationConfig{Updates: []aura.FormationUpdate{{Process: "web", Quantity: &one}}},
+		},
+		{
+			name: "invalid app",
+			cfg:  aura.UpdateFormationConfig{App: &aura.App{}, Updates: []aura.FormationUpdate{{Process: "web", Quantity: &one}}},
+		},
+		{
+			name: "no updates",
+			cfg:  aura.UpdateFormationConfig{App: &aura.App{ID: "123"}},
+		},
+		{
+			name: "no process",
+			cfg:  aura.UpdateFormationConfig{App: &aura.App{ID: "123"}, Updates: []aura.FormationUpdate{{Quantity: &one}}},
+		},
+		{
+			name: "duplicate process",
+			cfg: aura.UpdateFormationConfig{App: &aura.App{ID: "123"}, Updates: []aura.FormationUpdate{
+				{Process: "web", Quantity: &one},
+				{Process: "web", Quantity: &one},
+			}},
+		},
+		{
+			name: "negative quantity",
+			cfg:  aura.UpdateFormationConfig{App: &aura.App{ID: "123"}, Updates: []aura.FormationUpdate{{Process: "web", Quantity: &negative}}},
+		},
+		{
+			name: "invalid size",
+			cfg:  aura.UpdateFormationConfig{App: &aura.App{ID: "123"}, Updates: []aura.FormationUpdate{{Process: "web", Size: &size}}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			reg := &mockRegistry{}
+			sched := memory.NewScheduler()
+
+			a := aura.New(db, reg, sched)
+
+			_, err := a.UpdateFormation(context.Background(), test.cfg)
+
+			assert.ErrorAs(t, err, &aura.ValidationError{})
+		})
+	}
+}
+
+func TestProcessScale(t *testing.T) {
+	tests := []struct {
+		name      string
+		proc      procfile.Process
+		formation []*aura.Formation
+		want      aura.Scale
+	}{
+		{
+			name: "handles procfile defaults",
+			proc: procfile.Process{Name: "web", Memory: 128 << 20, CPU: 0.5},
+			want: aura.Scale{Quantity: 1, Memory: 128 << 20, CPU: 0.5},
+		},
+		{
+			name: "handles procfile instances",
+			proc: procfile.Process{Name: "web", Instances: 3},
+			want: aura.Scale{Quantity: 3},
+		},
+		{
+			name:      "handles formation",
+			proc:      procfile.Process{Name: "web", Instances: 3, Memory: 128 << 20, CPU: 0.5},
+			formation: []*aura.Formation{{Process: "worker", Quantity: 5}, {Process: "web", Quantity: 0, Size: "large"}},
+			want:      aura.Scale{Quantity: 0, Memory: 1 << 30, CPU: 1},
+		},
+		{
+			name:      "handles formation without size",
+			proc:      procfile.Process{Name: "web", Memory: 128 << 20, CPU: 0.5},
+			formation: []*aura.Formation{{Process: "web", Quantity: 2}},
+			want:      aura.Scale{Quantity: 2, Memory: 128 << 20, CPU: 0.5},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got := aura.ProcessScale(test.proc, test.formation)
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
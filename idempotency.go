@@ -0,0 +1,124 @@
+package aura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotentRequest contains the info of a request made with an
+// idempotency key, and its response once the request is done.
+type IdempotentRequest struct {
+	ID          string
+	Key         string
+	Method      string
+	Path        string
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Location    string
+	Body        []byte
+	CreatedAt   *time.Time
+}
+
+// BeforeCreate is a pre-creation hook.
+func (r *IdempotentRequest) BeforeCreate(_ *gorm.DB) error {
+	r.ID = ksuid.New().String()
+
+	now := time.Now().UTC()
+	r.CreatedAt = &now
+
+	return nil
+}
+
+// Done determines if the response of the request has been recorded.
+func (r *IdempotentRequest) Done() bool {
+	return r.StatusCode != 0
+}
+
+type idempotencyService struct {
+	db *DB
+}
+
+// Create creates the request, returning false if a request
+// with the same key, method and path already exists.
+func (s *idempotencyService) Create(ctx context.Context, req *IdempotentRequest) (bool, error) {
+	res := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(req)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (s *idempotencyService) First(ctx context.Context, key, method, path string) (*IdempotentRequest, error) {
+	var req *IdempotentRequest
+	return req, s.db.WithContext(ctx).
+		Where("key = ? AND method = ? AND path = ?", key, method, path).
+		Take(&req).Error
+}
+
+func (s *idempotencyService) Update(ctx context.Context, req *IdempotentRequest) error {
+	return s.db.WithContext(ctx).Save(req).Error
+}
+
+func (s *idempotencyService) Delete(ctx context.Context, req *IdempotentRequest) error {
+	return s.db.WithContext(ctx).Delete(req).Error
+}
+
+// DeleteBefore deletes all requests created before t.
+func (s *idempotencyService) DeleteBefore(ctx context.Context, t time.Time) error {
+	return s.db.WithContext(ctx).Where("created_at < ?", t).Delete(&IdempotentRequest{}).Error
+}
+
+// StartIdempotentRequest records the start of a request made with an
+// idempotency key, expiring keys older than the idempotency window.
+//
+// If the key was already used for the method and path, the earlier
+// request is returned and started is false.
+func (a *Aura) StartIdempotentRequest(ctx context.Context, req *IdempotentRequest) (*IdempotentRequest, bool, error) {
+	if err := a.idempotency.DeleteBefore(ctx, time.Now().UTC().Add(-a.idempotencyWindow)); err != nil {
+		return nil, false, fmt.Errorf("could not expire idempotent requests: %w", err)
+	}
+
+	for {
+		created, err := a.idempotency.Create(ctx, req)
+		if err != nil {
+			return nil, false, fmt.Errorf("could not create idempotent request: %w", err)
+		}
+		if created {
+			return req, true, nil
+		}
+
+		existing, err := a.idempotency.First(ctx, req.Key, req.Method, req.Path)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// The earlier request was aborted in the meantime.
+				continue
+			}
+			return nil, false, fmt.Errorf("could not find idempotent request: %w", err)
+		}
+		return existing, false, nil
+	}
+}
+
+// FinishIdempotentRequest records the response of an idempotent request.
+func (a *Aura) FinishIdempotentRequest(ctx context.Context, req *IdempotentRequest) error {
+	if err := a.idempotency.Update(ctx, req); err != nil {
+		return fmt.Errorf("could not update idempotent request: %w", err)
+	}
+	return nil
+}
+
+// AbortIdempotentRequest removes an idempotent request, allowing
+// its key to be used again.
+func (a *Aura) AbortIdempotentRequest(ctx context.Context, req *IdempotentRequest) error {
+	if err := a.idempotency.Delete(ctx, req); err != nil {
+		return fmt.Errorf("could not delete idempotent request: %w", err)
+	}
+	return nil
+}
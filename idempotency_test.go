@@ -0,0 +1,73 @@
+package aura_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAura_StartIdempotentRequest(t *testing.T) {
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	req := &aura.IdempotentRequest{Key: "test", Method: "POST", Path: "/apps", Fingerprint: "abc"}
+	got, started, err := a.StartIdempotentRequest(context.Background(), req)
+	require.NoError(t, err)
+	assert.True(t, started)
+	assert.False(t, got.Done())
+
+	got, started, err = a.StartIdempotentRequest(context.Background(), &aura.IdempotentRequest{Key: "test", Method: "POST", Path: "/apps", Fingerprint: "abc"})
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.Equal(t, req.ID, got.ID)
+	assert.False(t, got.Done())
+
+	req.StatusCode = 200
+	req.Body = []byte("test")
+	err = a.FinishIdempotentRequest(context.Background(), req)
+	require.NoError(t, err)
+
+	got, started, err = a.StartIdempotentRequest(context.Background(), &aura.IdempotentRequest{Key: "test", Method: "POST", Path: "/apps", Fingerprint: "abc"})
+	require.NoError(t, err)
+	assert.False(t, started)
+	assert.True(t, got.Done())
+	assert.Equal(t, []byte("test"), got.Body)
+
+	_, started, err = a.StartIdempotentRequest(context.Background(), &aura.IdempotentRequest{Key: "test", Method: "POST", Path: "/apps/123/deploys", Fingerprint: "abc"})
+	require.NoError(t, err)
+	assert.True(t, started)
+}
+
+func TestAura_StartIdempotentRequestAfterAbort(t *testing.T) {
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	req := &aura.IdempotentRequest{Key: "test", Method: "POST", Path: "/apps", Fingerprint: "abc"}
+	_, _, err := a.StartIdempotentRequest(context.Background(), req)
+	require.NoError(t, err)
+
+	err = a.AbortIdempotentRequest(context.Background(), req)
+	require.NoError(t, err)
+
+	_, started, err := a.StartIdempotentRequest(context.Background(), &aura.IdempotentRequest{Key: "test", Method: "POST", Path: "/apps", Fingerprint: "abc"})
+	require.NoError(t, err)
+	assert.True(t, started)
+}
+
+func TestAura_StartIdempotentRequestExpiresKeys(t *testing.T) {
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{}, aura.WithIdempotencyWindow(time.Millisecond))
+
+	_, _, err := a.StartIdempotentRequest(context.Background(), &aura.IdempotentRequest{Key: "test", Method: "POST", Path: "/apps", Fingerprint: "abc"})
+	require.NoError(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, started, err := a.StartIdempotentRequest(context.Background(), &aura.IdempotentRequest{Key: "test", Method: "POST", Path: "/apps", Fingerprint: "abc"})
+	require.NoError(t, err)
+	assert.True(t, started)
+}
@@ -0,0 +1,191 @@
+package aura
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deployLocker serializes the deploys of an application.
+type deployLocker interface {
+	// Lock locks the application, returning a function to unlock it.
+	// If wait is false and the application is locked, ErrConflict
+	// is returned.
+	Lock(ctx context.Context, appID string, wait bool) (func(), error)
+}
+
+func newDeployLocker(db *DB) deployLocker {
+	if db.Dialector.Name() == "postgres" {
+		if sqlDB, err := db.DB.DB(); err == nil {
+			return newAdvisoryLocker(sqlDB)
+		}
+	}
+	return &memoryLocker{locks: map[string]chan struct{}{}}
+}
+
+// Bounds of the backoff between attempts to take a held advisory lock.
+const (
+	advisoryLockMinBackoff = 50 * time.Millisecond
+	advisoryLockMaxBackoff = 2 * time.Second
+)
+
+// advisoryLocker locks applications using postgres session advisory locks,
+// serializing deploys across servers.
+//
+// A held lock is retried with backoff, rather than waited for, so waiting
+// deploys do not hold connections from the pool.
+type advisoryLocker struct {
+	db *sql.DB
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+func newAdvisoryLocker(db *sql.DB) *advisoryLocker {
+	return &advisoryLocker{
+		db:         db,
+		minBackoff: advisoryLockMinBackoff,
+		maxBackoff: advisoryLockMaxBackoff,
+	}
+}
+
+func (l *advisoryLocker) Lock(ctx context.Context, appID string, wait bool) (func(), error) {
+	key := lockKey(appID)
+
+	backoff := l.minBackoff
+	for {
+		conn, err := l.tryLock(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if conn != nil {
+			return func() {
+				// Closing the connection would also release the lock, but
+				// it is returned to the pool, so the lock must be released first.
+				_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+				_ = conn.Close()
+			}, nil
+		}
+		if !wait {
+			return nil, ErrConflict
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > l.maxBackoff {
+			backoff = l.maxBackoff
+		}
+	}
+}
+
+// tryLock tries to take the lock, returning the connection holding it.
+// If the lock is held elsewhere, no connection is returned.
+func (l *advisoryLocker) tryLock(ctx context.Context, key int64) (*sql.Conn, error) {
+	// The lock is held by the session, so a connection is held until unlocked.
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked bool
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !locked {
+		_ = conn.Close()
+		return nil, nil
+	}
+	return conn, nil
+}
+
+func lockKey(appID string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("aura.deploy/" + appID))
+	return int64(h.Sum64())
+}
+
+// memoryLocker locks applications in process, for databases
+// without advisory locks.
+type memoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+func (l *memoryLocker) Lock(ctx context.Context, appID string, wait bool) (func(), error) {
+	for {
+		l.mu.Lock()
+		released, ok := l.locks[appID]
+		if !ok {
+			released = make(chan struct{})
+			l.locks[appID] = released
+			l.mu.Unlock()
+
+			return func() {
+				l.mu.Lock()
+				delete(l.locks, appID)
+				l.mu.Unlock()
+
+				close(released)
+			}, nil
+		}
+		l.mu.Unlock()
+
+		if !wait {
+			return nil, ErrConflict
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// lockApp locks the application row until the end of the transaction.
+func lockApp(tx *gorm.DB, appID string) error {
+	var app *App
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", appID).Take(&app).Error
+}
+
+// lock locks the application for changes to its releases or processes.
+func (a *Aura) lock(ctx context.Context, appID string, wait bool) (func(), error) {
+	unlock, err := a.locks.Lock(ctx, appID, wait)
+	if err != nil {
+		if errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("could not lock app: %w", err)
+	}
+	return unlock, nil
+}
+
+// lockActiveApp locks the application, ensuring it was not
+// destroyed while waiting for the lock.
+func (a *Aura) lockActiveApp(ctx context.Context, app *App, wait bool) (func(), error) {
+	unlock, err := a.lock(ctx, app.ID, wait)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = a.App(ctx, AppsQuery{ID: app.ID}); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
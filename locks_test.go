@@ -0,0 +1,217 @@
+package aura_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nrwiersma/aura"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdvisoryLocker_Lock(t *testing.T) {
+	pg := newFakeAdvisoryDB()
+	db := sql.OpenDB(pg)
+	t.Cleanup(func() { _ = db.Close() })
+
+	l := aura.NewAdvisoryLocker(db, time.Millisecond)
+
+	unlock, err := l.Lock(context.Background(), "app1", false)
+	require.NoError(t, err)
+
+	_, err = l.Lock(context.Background(), "app1", false)
+	assert.ErrorIs(t, err, aura.ErrConflict)
+
+	unlock()
+	assert.Equal(t, 0, pg.held())
+	unlock, err = l.Lock(context.Background(), "app1", false)
+	require.NoError(t, err)
+	unlock()
+}
+
+func TestAdvisoryLocker_LockWaitsWithoutHoldingConnections(t *testing.T) {
+	pg := newFakeAdvisoryDB()
+	db := sql.OpenDB(pg)
+	t.Cleanup(func() { _ = db.Close() })
+	db.SetMaxOpenConns(2)
+
+	l := aura.NewAdvisoryLocker(db, time.Millisecond)
+
+	unlock, err := l.Lock(context.Background(), "app1", true)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unlock, err := l.Lock(context.Background(), "app1", true)
+			if !assert.NoError(t, err) {
+				return
+			}
+			unlock()
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return pg.failedAttempts() >= 6
+	}, time.Second, time.Millisecond)
+
+	// Another app can be locked while the waiters share the pool.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlockOther, err := l.Lock(ctx, "app2", true)
+	require.NoError(t, err)
+	unlockOther()
+
+	unlock()
+	wg.Wait()
+	assert.Equal(t, 0, pg.held())
+}
+
+func TestAdvisoryLocker_LockHandlesCancelledContext(t *testing.T) {
+	pg := newFakeAdvisoryDB()
+	db := sql.OpenDB(pg)
+	t.Cleanup(func() { _ = db.Close() })
+
+	l := aura.NewAdvisoryLocker(db, time.Millisecond)
+
+	unlock, err := l.Lock(context.Background(), "app1", true)
+	require.NoError(t, err)
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = l.Lock(ctx, "app1", true)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// fakeAdvisoryDB is a database with postgres session advisory lock
+// semantics, where locks are held by the connection that took them.
+type fakeAdvisoryDB struct {
+	mu     sync.Mutex
+	locks  map[int64]*fakeAdvisoryConn
+	failed int
+}
+
+func newFakeAdvisoryDB() *fakeAdvisoryDB {
+	return &fakeAdvisoryDB{locks: map[int64]*fakeAdvisoryConn{}}
+}
+
+func (db *fakeAdvisoryDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeAdvisoryConn{db: db}, nil
+}
+
+func (db *fakeAdvisoryDB) Driver() driver.Driver {
+	return nil
+}
+
+func (db *fakeAdvisoryDB) held() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return len(db.locks)
+}
+
+func (db *fakeAdvisoryDB) failedAttempts() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.failed
+}
+
+type fakeAdvisoryConn struct {
+	db *fakeAdvisoryDB
+}
+
+func (c *fakeAdvisoryConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeAdvisoryStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeAdvisoryConn) Close() error {
+	// Session locks are released when the session ends.
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	for key, holder := range c.db.locks {
+		if holder == c {
+			delete(c.db.locks, key)
+		}
+	}
+	return nil
+}
+
+func (c *fakeAdvisoryConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeAdvisoryStmt struct {
+	conn  *fakeAdvisoryConn
+	query string
+}
+
+func (s *fakeAdvisoryStmt) Close() error { return nil }
+
+func (s *fakeAdvisoryStmt) NumInput() int { return 1 }
+
+func (s *fakeAdvisoryStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.query != "SELECT pg_advisory_unlock($1)" {
+		return nil, errors.New("unexpected query " + s.query)
+	}
+
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	key := args[0].(int64)
+	if db.locks[key] == s.conn {
+		delete(db.locks, key)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s *fakeAdvisoryStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.query != "SELECT pg_try_advisory_lock($1)" {
+		return nil, errors.New("unexpected query " + s.query)
+	}
+
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	key := args[0].(int64)
+	holder, ok := db.locks[key]
+	locked := !ok || holder == s.conn
+	if locked {
+		db.locks[key] = s.conn
+	} else {
+		db.failed++
+	}
+	return &fakeAdvisoryRows{val: locked}, nil
+}
+
+type fakeAdvisoryRows struct {
+	val  bool
+	done bool
+}
+
+func (r *fakeAdvisoryRows) Columns() []string { return []string{"locked"} }
+
+func (r *fakeAdvisoryRows) Close() error { return nil }
+
+func (r *fakeAdvisoryRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.val
+	return nil
+}
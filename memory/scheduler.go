@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/procfile"
+)
+
+// Scheduler is an in-memory scheduler.
+//
+// It keeps track of the submitted releases, but does not run anything.
+type Scheduler struct {
+	mu         sync.Mutex
+	releases   map[string]*aura.Release
+	formations map[string][]*aura.Formation
+}
+
+// NewScheduler returns an in-memory scheduler.
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		releases:   map[string]*aura.Release{},
+		formations: map[string][]*aura.Formation{},
+	}
+}
+
+// Submit submits a release for an application with the given formation.
+func (s *Scheduler) Submit(_ context.Context, app *aura.App, release *aura.Release, formation []*aura.Formation) error {
+	if _, err := procfile.Parse(release.Procfile); err != nil {
+		return fmt.Errorf("parsing procfile: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.releases[app.ID] = release
+	s.formations[app.ID] = formation
+	return nil
+}
+
+// Remove removes an application.
+func (s *Scheduler) Remove(_ context.Context, app *aura.App) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.releases, app.ID)
+	delete(s.formations, app.ID)
+	return nil
+}
+
+// Processes returns the processes of an application.
+func (s *Scheduler) Processes(_ context.Context, app *aura.App) ([]*aura.Process, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	release, ok := s.releases[app.ID]
+	if !ok {
+		return []*aura.Process{}, nil
+	}
+
+	// The procfile was validated on submit.
+	defs, _ := procfile.Parse(release.Procfile)
+
+	procs := make([]*aura.Process, 0, len(defs))
+	for _, def := range defs {
+		if def.Schedule != "" || def.Name == aura.ReleaseProcess {
+			// Scheduled and release processes are not long-running.
+			continue
+		}
+		scale := aura.ProcessScale(def, s.formations[app.ID])
+		for i := 1; i <= scale.Quantity; i++ {
+			procs = append(procs, &aura.Process{
+				ID:        release.ID + "." + def.Name + "." + strconv.Itoa(i),
+				Type:      def.Name,
+				Command:   def.Command,
+				Version:   release.Version,
+				State:     "running",
+				CreatedAt: release.CreatedAt,
+			})
+		}
+	}
+	return procs, nil
+}
+
+// Run runs a one-off process. Nothing is run, an attached
+// run always succeeds without output.
+func (s *Scheduler) Run(_ context.Context, _ *aura.App, release *aura.Release, run *aura.Run) error {
+	run.ID = release.ID + ".run"
+	if run.Attach {
+		exitCode := 0
+		run.ExitCode = &exitCode
+	}
+	return nil
+}
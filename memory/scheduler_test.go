@@ -0,0 +1,107 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_Submit(t *testing.T) {
+	app := &aura.App{ID: "123"}
+
+	s := memory.NewScheduler()
+
+	err := s.Submit(context.Background(), app, &aura.Release{ID: "456", Version: 1, Procfile: []byte("web: ./app")}, nil)
+	require.NoError(t, err)
+	err = s.Submit(context.Background(), app, &aura.Release{ID: "789", Version: 2, Procfile: []byte("release: ./migrate\nweb: ./app\nworker: ./worker")}, nil)
+	require.NoError(t, err)
+
+	got, err := s.Processes(context.Background(), app)
+	require.NoError(t, err)
+	want := []*aura.Process{
+		{ID: "789.web.1", Type: "web", Command: "./app", Version: 2, State: "running"},
+		{ID: "789.worker.1", Type: "worker", Command: "./worker", Version: 2, State: "running"},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestScheduler_SubmitHandlesFormation(t *testing.T) {
+	app := &aura.App{ID: "123"}
+
+	s := memory.NewScheduler()
+
+	err := s.Submit(context.Background(), app, &aura.Release{ID: "456", Version: 1, Procfile: []byte("web: ./app\nworker: ./worker")}, []*aura.Formation{
+		{Process: "web", Quantity: 2},
+		{Process: "worker", Quantity: 0},
+	})
+	require.NoError(t, err)
+
+	got, err := s.Processes(context.Background(), app)
+	require.NoError(t, err)
+	want := []*aura.Process{
+		{ID: "456.web.1", Type: "web", Command: "./app", Version: 1, State: "running"},
+		{ID: "456.web.2", Type: "web", Command: "./app", Version: 1, State: "running"},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestScheduler_SubmitSkipsScheduledProcesses(t *testing.T) {
+	app := &aura.App{ID: "123"}
+	procfile := `web:
+  command: ./app
+cron:
+  command: ./job
+  schedule: "@hourly"
+`
+
+	s := memory.NewScheduler()
+
+	err := s.Submit(context.Background(), app, &aura.Release{ID: "456", Version: 1, Procfile: []byte(procfile)}, nil)
+	require.NoError(t, err)
+
+	got, err := s.Processes(context.Background(), app)
+	require.NoError(t, err)
+	want := []*aura.Process{
+		{ID: "456.web.1", Type: "web", Command: "./app", Version: 1, State: "running"},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestScheduler_SubmitHandlesInvalidProcfile(t *testing.T) {
+	s := memory.NewScheduler()
+
+	err := s.Submit(context.Background(), &aura.App{ID: "123"}, &aura.Release{ID: "456", Procfile: []byte("web ./app")}, nil)
+
+	require.Error(t, err)
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	app := &aura.App{ID: "123"}
+
+	s := memory.NewScheduler()
+	err := s.Submit(context.Background(), app, &aura.Release{ID: "456", Version: 1, Procfile: []byte("web: ./app")}, nil)
+	require.NoError(t, err)
+
+	err = s.Remove(context.Background(), app)
+
+	require.NoError(t, err)
+	got, err := s.Processes(context.Background(), app)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	s := memory.NewScheduler()
+
+	run := &aura.Run{Command: "./job", Attach: true}
+	err := s.Run(context.Background(), &aura.App{ID: "123"}, &aura.Release{ID: "456"}, run)
+
+	require.NoError(t, err)
+	assert.Equal(t, "456.run", run.ID)
+	require.NotNil(t, run.ExitCode)
+	assert.Equal(t, 0, *run.ExitCode)
+}
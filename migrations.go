@@ -32,5 +32,207 @@ func (s Schema) migrations() []migrate.Migration {
 				`DROP TABLE apps;`,
 			),
 		},
+		{
+			ID: 2,
+			Up: migrate.Queries(
+				`CREATE TABLE IF NOT EXISTS configs (
+    id varchar(27) NOT NULL primary key,
+    app_id varchar(27) NOT NULL references apps(id) ON DELETE CASCADE,
+    version int NOT NULL,
+    vars text NOT NULL,
+    created_at datetime NOT NULL
+);`,
+				`ALTER TABLE releases ADD COLUMN config_id varchar(27) references configs(id);`,
+			),
+			Down: migrate.Queries(
+				`ALTER TABLE releases DROP COLUMN config_id;`,
+				`DROP TABLE configs;`,
+			),
+		},
+		{
+			ID: 3,
+			Up: migrate.Queries(
+				`ALTER TABLE releases ADD COLUMN reason varchar(50) NOT NULL DEFAULT '';`,
+			),
+			Down: migrate.Queries(
+				`ALTER TABLE releases DROP COLUMN reason;`,
+			),
+		},
+		{
+			ID: 4,
+			Up: migrate.Queries(
+				`CREATE TABLE IF NOT EXISTS secrets (
+    id varchar(27) NOT NULL primary key,
+    app_id varchar(27) NOT NULL references apps(id) ON DELETE CASCADE,
+    name varchar(100) NOT NULL,
+    key_id varchar(50) NOT NULL,
+    data_key bytea NOT NULL,
+    value bytea NOT NULL,
+    created_at datetime NOT NULL,
+    updated_at datetime NOT NULL
+);`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS secrets_app_id_name ON secrets (app_id, name);`,
+				`CREATE INDEX IF NOT EXISTS secrets_key_id ON secrets (key_id);`,
+			),
+			Down: migrate.Queries(
+				`DROP TABLE secrets;`,
+			),
+		},
+		{
+			ID: 5,
+			Up: migrate.Queries(
+				`ALTER TABLE releases ADD COLUMN rollback_version int;`,
+			),
+			Down: migrate.Queries(
+				`ALTER TABLE releases DROP COLUMN rollback_version;`,
+			),
+		},
+		{
+			ID: 6,
+			Up: migrate.Queries(
+				`CREATE TABLE IF NOT EXISTS formations (
+    id varchar(27) NOT NULL primary key,
+    app_id varchar(27) NOT NULL references apps(id) ON DELETE CASCADE,
+    process varchar(100) NOT NULL,
+    quantity int NOT NULL,
+    size varchar(20) NOT NULL DEFAULT '',
+    created_at datetime NOT NULL,
+    updated_at datetime NOT NULL
+);`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS formations_app_id_process ON formations (app_id, process);`,
+			),
+			Down: migrate.Queries(
+				`DROP TABLE formations;`,
+			),
+		},
+		{
+			ID: 7,
+			Up: migrate.Queries(
+				`ALTER TABLE releases ADD COLUMN status varchar(20) NOT NULL DEFAULT 'active';`,
+				`ALTER TABLE releases ADD COLUMN output bytea;`,
+			),
+			Down: migrate.Queries(
+				`ALTER TABLE releases DROP COLUMN output;`,
+				`ALTER TABLE releases DROP COLUMN status;`,
+			),
+		},
+		{
+			ID: 8,
+			Up: migrate.Queries(
+				`CREATE TABLE IF NOT EXISTS deployments (
+    id varchar(27) NOT NULL primary key,
+    app_id varchar(27) NOT NULL references apps(id) ON DELETE CASCADE,
+    image text NOT NULL,
+    status varchar(20) NOT NULL,
+    error text NOT NULL DEFAULT '',
+    release_id varchar(27) references releases(id),
+    created_at datetime NOT NULL,
+    updated_at datetime NOT NULL
+);`,
+				`CREATE INDEX IF NOT EXISTS deployments_status_created_at ON deployments (status, created_at);`,
+			),
+			Down: migrate.Queries(
+				`DROP TABLE deployments;`,
+			),
+		},
+		{
+			ID: 9,
+			Up: migrate.Queries(
+				`CREATE TABLE IF NOT EXISTS idempotent_requests (
+    id varchar(27) NOT NULL primary key,
+    key varchar(255) NOT NULL,
+    method varchar(10) NOT NULL,
+    path text NOT NULL,
+    fingerprint varchar(64) NOT NULL,
+    status_code int NOT NULL DEFAULT 0,
+    content_type text NOT NULL DEFAULT '',
+    location text NOT NULL DEFAULT '',
+    body bytea,
+    created_at datetime NOT NULL
+);`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idempotent_requests_key_method_path ON idempotent_requests (key, method, path);`,
+				`CREATE INDEX IF NOT EXISTS idempotent_requests_created_at ON idempotent_requests (created_at);`,
+			),
+			Down: migrate.Queries(
+				`DROP TABLE idempotent_requests;`,
+			),
+		},
+		{
+			ID: 10,
+			Up: migrate.Queries(
+				`CREATE UNIQUE INDEX IF NOT EXISTS apps_name ON apps (name) WHERE deleted_at IS NULL;`,
+			),
+			Down: migrate.Queries(
+				`DROP INDEX apps_name;`,
+			),
+		},
+		{
+			ID: 11,
+			Up: migrate.Queries(
+				`ALTER TABLE apps ADD COLUMN description text NOT NULL DEFAULT '';`,
+				`ALTER TABLE apps ADD COLUMN owner varchar(255) NOT NULL DEFAULT '';`,
+				`ALTER TABLE apps ADD COLUMN labels text NOT NULL DEFAULT '{}';`,
+				`ALTER TABLE apps ADD COLUMN updated_at datetime;`,
+				`UPDATE apps SET updated_at = created_at;`,
+			),
+			Down: migrate.Queries(
+				`ALTER TABLE apps DROP COLUMN updated_at;`,
+				`ALTER TABLE apps DROP COLUMN labels;`,
+				`ALTER TABLE apps DROP COLUMN owner;`,
+				`ALTER TABLE apps DROP COLUMN description;`,
+			),
+		},
+		{
+			ID: 12,
+			Up: migrate.Queries(
+				`CREATE TABLE IF NOT EXISTS registry_credentials (
+    id varchar(27) NOT NULL primary key,
+    app_id varchar(27) NOT NULL references apps(id) ON DELETE CASCADE,
+    registry varchar(255) NOT NULL,
+    username text NOT NULL,
+    key_id varchar(50) NOT NULL,
+    data_key bytea NOT NULL,
+    password bytea NOT NULL,
+    created_at datetime NOT NULL,
+    updated_at datetime NOT NULL
+);`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS registry_credentials_app_id_registry ON registry_credentials (app_id, registry);`,
+				`CREATE INDEX IF NOT EXISTS registry_credentials_key_id ON registry_credentials (key_id);`,
+			),
+			Down: migrate.Queries(
+				`DROP TABLE registry_credentials;`,
+			),
+		},
+		{
+			ID: 13,
+			Up: migrate.Queries(
+				`ALTER TABLE releases ADD COLUMN index_digest varchar(100) NOT NULL DEFAULT '';`,
+				`ALTER TABLE releases ADD COLUMN platform varchar(100);`,
+				`ALTER TABLE deployments ADD COLUMN platform varchar(100) NOT NULL DEFAULT '';`,
+			),
+			Down: migrate.Queries(
+				`ALTER TABLE deployments DROP COLUMN platform;`,
+				`ALTER TABLE releases DROP COLUMN platform;`,
+				`ALTER TABLE releases DROP COLUMN index_digest;`,
+			),
+		},
+		{
+			ID: 14,
+			Up: migrate.Queries(
+				`ALTER TABLE apps ADD COLUMN image_policy text NOT NULL DEFAULT '{}';`,
+			),
+			Down: migrate.Queries(
+				`ALTER TABLE apps DROP COLUMN image_policy;`,
+			),
+		},
+		{
+			ID: 15,
+			Up: migrate.Queries(
+				`ALTER TABLE deployments ADD COLUMN heartbeat_at datetime;`,
+			),
+			Down: migrate.Queries(
+				`ALTER TABLE deployments DROP COLUMN heartbeat_at;`,
+			),
+		},
 	}
 }
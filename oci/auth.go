@@ -0,0 +1,169 @@
+package oci
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// authorize sets the session authorization for the registry challenge.
+//
+// Basic challenges use the session credentials directly, while bearer
+// challenges exchange them for a token with the token service. Without
+// credentials an anonymous token is requested.
+func (r *Registry) authorize(ctx context.Context, sess *session, header string) error {
+	scheme, params := parseChallenge(header)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if sess.auth == nil {
+			return errors.New("registry requires credentials")
+		}
+		sess.authz = "Basic " + basicAuth(sess.auth.Username, sess.auth.Password)
+		return nil
+	case "bearer":
+		token, err := r.fetchToken(ctx, sess, params)
+		if err != nil {
+			return err
+		}
+		sess.authz = "Bearer " + token
+		return nil
+	default:
+		return fmt.Errorf("unsupported auth challenge %q", header)
+	}
+}
+
+func (r *Registry) fetchToken(ctx context.Context, sess *session, params map[string]string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.New("bearer challenge has no realm")
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("parsing token realm: %w", err)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("token realm %q does not use https", realm)
+	}
+
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + sess.repo + ":pull"
+	}
+	q := u.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	q.Set("scope", scope)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("creating token request: %w", err)
+	}
+	// A registry could send its credentials anywhere, so without
+	// a trusted realm an anonymous token is requested.
+	if sess.auth != nil && r.trustsRealm(sess.host, u) {
+		req.SetBasicAuth(sess.auth.Username, sess.auth.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected token response %q", resp.Status)
+	}
+
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("decoding token: %w", err)
+	}
+
+	token := tokenResp.Token
+	if token == "" {
+		token = tokenResp.AccessToken
+	}
+	if token == "" {
+		return "", errors.New("token response has no token")
+	}
+	return token, nil
+}
+
+// trustsRealm determines if the credentials of the registry
+// can be sent to the token service at the realm.
+func (r *Registry) trustsRealm(registry string, realm *url.URL) bool {
+	host := strings.ToLower(realm.Host)
+	if realm.Port() == "443" {
+		host = strings.ToLower(realm.Hostname())
+	}
+	if host == strings.ToLower(registry) {
+		return true
+	}
+	for _, h := range r.tokenRealms[registry] {
+		if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+// parseChallenge parses a WWW-Authenticate header into its
+// scheme and parameters.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+
+	params := map[string]string{}
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		if rest == "" {
+			return scheme, params
+		}
+
+		var key string
+		key, rest, _ = strings.Cut(rest, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		var val string
+		if strings.HasPrefix(rest, `"`) {
+			val, rest = parseQuoted(rest[1:])
+		} else {
+			val, rest, _ = strings.Cut(rest, ",")
+			val = strings.TrimSpace(val)
+		}
+		params[key] = val
+	}
+}
+
+// parseQuoted parses a quoted string, without its opening quote,
+// returning the unescaped value and the remaining string.
+func parseQuoted(s string) (string, string) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), ""
+}
@@ -0,0 +1,265 @@
+package oci_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/oci"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_ResolveHandlesAuth(t *testing.T) {
+	tests := []struct {
+		name      string
+		scheme    string
+		username  string
+		password  string
+		auth      *aura.RegistryAuth
+		wantScope string
+		wantErr   require.ErrorAssertionFunc
+	}{
+		{
+			name:      "handles bearer auth",
+			scheme:    "Bearer",
+			username:  "bob",
+			password:  "hunter2",
+			auth:      &aura.RegistryAuth{Username: "bob", Password: "hunter2"},
+			wantScope: "repository:foo/bar:pull",
+			wantErr:   require.NoError,
+		},
+		{
+			name:      "handles anonymous bearer auth",
+			scheme:    "Bearer",
+			wantScope: "repository:foo/bar:pull",
+			wantErr:   require.NoError,
+		},
+		{
+			name:     "handles bearer auth with invalid credentials",
+			scheme:   "Bearer",
+			username: "bob",
+			password: "hunter2",
+			auth:     &aura.RegistryAuth{Username: "bob", Password: "wrong"},
+			wantErr:  require.Error,
+		},
+		{
+			name:     "handles basic auth",
+			scheme:   "Basic",
+			username: "bob",
+			password: "hunter2",
+			auth:     &aura.RegistryAuth{Username: "bob", Password: "hunter2"},
+			wantErr:  require.NoError,
+		},
+		{
+			name:     "handles basic auth with invalid credentials",
+			scheme:   "Basic",
+			username: "bob",
+			password: "hunter2",
+			auth:     &aura.RegistryAuth{Username: "bob", Password: "wrong"},
+			wantErr:  require.Error,
+		},
+		{
+			name:     "handles basic auth without credentials",
+			scheme:   "Basic",
+			username: "bob",
+			password: "hunter2",
+			wantErr:  require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			reg := newTestRegistry(t)
+			reg.auth = &testAuth{scheme: test.scheme, username: test.username, password: test.password}
+			want := reg.pushImage(t, "foo/bar", "1.0", "")
+
+			img, err := image.Decode(reg.host + "/foo/bar:1.0")
+			require.NoError(t, err)
+
+			r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+			got, err := r.Resolve(context.Background(), img, test.auth, nil)
+
+			test.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, want, got.Digest)
+			if test.wantScope != "" {
+				assert.Equal(t, []string{test.wantScope}, reg.auth.tokenScopes())
+			}
+		})
+	}
+}
+
+func TestRegistry_ResolveHandlesBearerChallengeScope(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.auth = &testAuth{scheme: "Bearer", scope: "repository:foo/bar:pull,push"}
+	want := reg.pushImage(t, "foo/bar", "1.0", "")
+
+	img, err := image.Decode(reg.host + "/foo/bar:1.0")
+	require.NoError(t, err)
+
+	r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+	got, err := r.Resolve(context.Background(), img, nil, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, want, got.Digest)
+	assert.Equal(t, []string{"repository:foo/bar:pull,push"}, reg.auth.tokenScopes())
+}
+
+func TestRegistry_ResolveHandlesTokenRealm(t *testing.T) {
+	tests := []struct {
+		name    string
+		realm   string
+		trusted string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "handles realm on the registry host",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles trusted realm on another host",
+			realm:   "https://auth.example.com/token",
+			trusted: "auth.example.com",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "does not send credentials to untrusted realm",
+			realm:   "https://auth.example.com/token",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles realm without https",
+			realm:   "http://auth.example.com/token",
+			trusted: "auth.example.com",
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			reg := newTestRegistry(t)
+			// The token endpoint rejects requests without credentials.
+			reg.auth = &testAuth{scheme: "Bearer", username: "bob", password: "hunter2", realm: test.realm}
+			want := reg.pushImage(t, "foo/bar", "1.0", "")
+
+			img, err := image.Decode(reg.host + "/foo/bar:1.0")
+			require.NoError(t, err)
+
+			// The test server client routes example.com hosts to the test registry.
+			opts := []oci.Option{oci.WithHTTPClient(reg.srv.Client())}
+			if test.trusted != "" {
+				opts = append(opts, oci.WithTokenRealm(reg.host, test.trusted))
+			}
+			r := oci.NewRegistry(opts...)
+
+			got, err := r.Resolve(context.Background(), img, &aura.RegistryAuth{Username: "bob", Password: "hunter2"}, nil)
+
+			test.wantErr(t, err)
+			if err != nil {
+				assert.Empty(t, reg.auth.tokenScopes())
+				return
+			}
+			assert.Equal(t, want, got.Digest)
+		})
+	}
+}
+
+func TestRegistry_ExtractProcfileHandlesAuth(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.auth = &testAuth{scheme: "Bearer", username: "bob", password: "hunter2"}
+	dgst := reg.pushImage(t, "foo/bar", "1.0", "", testLayer(t, tarEntry{name: "Procfile", body: "web: ./app"}))
+
+	r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+	got, err := r.ExtractProcfile(context.Background(), reg.host+"/foo/bar@"+dgst, &aura.RegistryAuth{Username: "bob", Password: "hunter2"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte("web: ./app"), got)
+	// The token is fetched once and reused for the session.
+	assert.Len(t, reg.auth.tokenScopes(), 1)
+}
+
+const testToken = "test-token"
+
+// testAuth authorizes requests to a test registry, serving
+// its own token endpoint for bearer auth.
+type testAuth struct {
+	scheme   string
+	username string
+	password string
+	scope    string
+	// realm is the token realm, defaulting to the token endpoint of the registry.
+	realm string
+
+	mu     sync.Mutex
+	scopes []string
+}
+
+func (a *testAuth) authorized(rw http.ResponseWriter, req *http.Request, srvURL string) bool {
+	if req.URL.Path == "/token" {
+		a.serveToken(rw, req)
+		return false
+	}
+
+	switch a.scheme {
+	case "Basic":
+		user, pass, ok := req.BasicAuth()
+		if ok && user == a.username && pass == a.password {
+			return true
+		}
+		rw.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+	case "Bearer":
+		if req.Header.Get("Authorization") == "Bearer "+testToken {
+			return true
+		}
+		realm := a.realm
+		if realm == "" {
+			realm = srvURL + "/token"
+		}
+		challenge := `Bearer realm="` + realm + `",service="test"`
+		if a.scope != "" {
+			challenge += `,scope="` + a.scope + `"`
+		}
+		rw.Header().Set("WWW-Authenticate", challenge)
+	}
+	http.Error(rw, `{"errors":[{"code":"UNAUTHORIZED"}]}`, http.StatusUnauthorized)
+	return false
+}
+
+func (a *testAuth) serveToken(rw http.ResponseWriter, req *http.Request) {
+	if a.username != "" {
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != a.username || pass != a.password {
+			http.Error(rw, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	if req.URL.Query().Get("service") != "test" {
+		http.Error(rw, "unknown service", http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	a.scopes = append(a.scopes, req.URL.Query().Get("scope"))
+	a.mu.Unlock()
+
+	_ = json.NewEncoder(rw).Encode(map[string]string{"access_token": testToken})
+}
+
+func (a *testAuth) tokenScopes() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string(nil), a.scopes...)
+}
@@ -0,0 +1,98 @@
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Whiteout file prefixes, marking files removed from lower layers.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// maxFileSize is the maximum size of a file read from a layer.
+const maxFileSize = 1 << 20
+
+// layerResult is the result of searching a layer for a file.
+type layerResult struct {
+	// Content is set if the file was found in the layer.
+	Content []byte
+	Found   bool
+
+	// Hidden is set if the layer removes the file from lower layers.
+	Hidden bool
+}
+
+// layerReader returns an uncompressed tar reader for the layer.
+func layerReader(mediaType string, r io.Reader) (io.Reader, error) {
+	switch mediaType {
+	case MediaTypeDockerLayer, MediaTypeOCILayerGz:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip: %w", err)
+		}
+		return gr, nil
+	case MediaTypeOCILayer:
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unsupported layer media type %q", mediaType)
+	}
+}
+
+// findFile searches an uncompressed layer for the file, honouring whiteouts.
+func findFile(r io.Reader, file string) (layerResult, error) {
+	var res layerResult
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return res, nil
+			}
+			return layerResult{}, fmt.Errorf("reading tar: %w", err)
+		}
+
+		name := cleanPath(hdr.Name)
+		dir, base := path.Split(name)
+		dir = strings.TrimSuffix(dir, "/")
+
+		switch {
+		case base == whiteoutOpaque:
+			// The directory contents of lower layers are removed.
+			if dir == "" || strings.HasPrefix(file, dir+"/") {
+				res.Hidden = true
+			}
+		case strings.HasPrefix(base, whiteoutPrefix):
+			removed := path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+			if removed == file || strings.HasPrefix(file, removed+"/") {
+				res.Hidden = true
+			}
+		case name == file:
+			if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA { //nolint:staticcheck // Older images use TypeRegA.
+				return layerResult{}, fmt.Errorf("%s is not a regular file", file)
+			}
+			if hdr.Size > maxFileSize {
+				return layerResult{}, fmt.Errorf("%s is larger than %d bytes", file, maxFileSize)
+			}
+
+			b, err := io.ReadAll(io.LimitReader(tr, maxFileSize))
+			if err != nil {
+				return layerResult{}, fmt.Errorf("reading %s: %w", file, err)
+			}
+			// A file in this layer takes precedence over its whiteouts.
+			return layerResult{Content: b, Found: true}, nil
+		}
+	}
+}
+
+// cleanPath returns the path relative to the root, without a leading slash.
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
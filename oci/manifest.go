@@ -0,0 +1,113 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nrwiersma/aura/pkg/image"
+)
+
+// Manifest media types.
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// Layer media types.
+const (
+	MediaTypeDockerLayer = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	MediaTypeOCILayer    = "application/vnd.oci.image.layer.v1.tar"
+	MediaTypeOCILayerGz  = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+// manifestAccept is the accept header for manifest requests.
+var manifestAccept = strings.Join([]string{
+	MediaTypeOCIIndex,
+	MediaTypeOCIManifest,
+	MediaTypeDockerManifestList,
+	MediaTypeDockerManifest,
+}, ", ")
+
+type platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p platform) image() image.Platform {
+	return image.Platform{OS: p.OS, Architecture: p.Architecture, Variant: p.Variant}
+}
+
+type descriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *platform `json:"platform,omitempty"`
+}
+
+// manifest is an image manifest, or an image index.
+type manifest struct {
+	MediaType string `json:"mediaType"`
+
+	// Config and Layers are set on image manifests.
+	Config descriptor   `json:"config"`
+	Layers []descriptor `json:"layers"`
+
+	// Manifests is set on image indexes.
+	Manifests []descriptor `json:"manifests"`
+}
+
+// isIndex determines if the manifest is an image index.
+func (m manifest) isIndex(contentType string) bool {
+	mediaType := m.MediaType
+	if mediaType == "" {
+		mediaType = contentType
+	}
+	switch mediaType {
+	case MediaTypeOCIIndex, MediaTypeDockerManifestList:
+		return true
+	case "":
+		// Without a media type, only an index has manifests.
+		return len(m.Manifests) > 0
+	default:
+		return false
+	}
+}
+
+type imageConfig struct {
+	platform
+
+	Config struct {
+		WorkingDir string `json:"WorkingDir"`
+	} `json:"config"`
+}
+
+func decodeManifest(b []byte) (manifest, error) {
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return manifest{}, fmt.Errorf("decoding manifest: %w", err)
+	}
+	return m, nil
+}
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// verifyDigest verifies the content matches the digest. Only
+// sha256 digests can be verified, others are trusted.
+func verifyDigest(dgst string, b []byte) error {
+	if !strings.HasPrefix(dgst, "sha256:") {
+		return nil
+	}
+	if got := digestOf(b); got != dgst {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", dgst, got)
+	}
+	return nil
+}
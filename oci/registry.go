@@ -0,0 +1,418 @@
+// Package oci implements an image registry using the OCI distribution api.
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/pkg/image"
+)
+
+// Docker hub defaults.
+const (
+	defaultRegistry  = "registry-1.docker.io"
+	defaultNamespace = "library"
+)
+
+// defaultPlatform is the platform selected from image indexes,
+// unless configured otherwise.
+var defaultPlatform = image.Platform{OS: "linux", Architecture: "amd64"}
+
+// maxManifestSize is the maximum size of a manifest or image config.
+const maxManifestSize = 4 << 20
+
+// Option configures a registry.
+type Option func(*Registry)
+
+// WithHTTPClient sets the http client used to talk to registries.
+func WithHTTPClient(client *http.Client) Option {
+	return func(r *Registry) {
+		r.client = client
+	}
+}
+
+// WithPlatform sets the platform selected from image indexes
+// for images without a platform.
+func WithPlatform(p image.Platform) Option {
+	return func(r *Registry) {
+		r.platform = p
+	}
+}
+
+// WithTokenRealm allows the credentials of the registry to be sent to the
+// token service at the realm host. By default, credentials are only sent to
+// token services on the registry host, and Docker Hub's token service.
+func WithTokenRealm(registry, realmHost string) Option {
+	return func(r *Registry) {
+		host, _ := repository(image.Image{Registry: strings.ToLower(registry)})
+		r.tokenRealms[host] = append(r.tokenRealms[host], strings.ToLower(realmHost))
+	}
+}
+
+// Registry is an OCI distribution registry client.
+//
+// Images are resolved and inspected without pulling them, only
+// fetching the layers needed to find the procfile.
+type Registry struct {
+	client   *http.Client
+	platform image.Platform
+
+	// tokenRealms are the token service hosts trusted
+	// with credentials, by registry host.
+	tokenRealms map[string][]string
+}
+
+// NewRegistry returns a registry.
+func NewRegistry(opts ...Option) *Registry {
+	reg := &Registry{
+		client:   http.DefaultClient,
+		platform: defaultPlatform,
+		tokenRealms: map[string][]string{
+			defaultRegistry: {"auth.docker.io"},
+		},
+	}
+
+	for _, opt := range opts {
+		opt(reg)
+	}
+
+	return reg
+}
+
+// Resolve resolves the image to its manifest digest.
+//
+// If the image is an image index, it is resolved to the manifest of
+// the image platform, or the registry platform if the image has none,
+// and the index digest is recorded.
+func (r *Registry) Resolve(ctx context.Context, img image.Image, auth *aura.RegistryAuth, progress func(aura.PullProgress)) (image.Image, error) {
+	sess := newSession(img, auth)
+
+	ref := img.Digest
+	if ref == "" {
+		ref = img.Tag
+	}
+	if ref == "" {
+		ref = "latest"
+	}
+
+	raw, err := r.getManifest(ctx, sess, ref)
+	if err != nil {
+		return img, fmt.Errorf("resolving manifest: %w", err)
+	}
+	m, err := decodeManifest(raw.body)
+	if err != nil {
+		return img, err
+	}
+
+	if progress != nil {
+		progress(aura.PullProgress{ID: ref, Status: "Digest: " + raw.digest})
+	}
+
+	if m.isIndex(raw.contentType) {
+		p := r.platformOf(img)
+		desc, ok := selectManifest(m.Manifests, p)
+		if !ok {
+			return img, fmt.Errorf("no manifest for platform %s", p)
+		}
+
+		img.IndexDigest = raw.digest
+		img.Digest = desc.Digest
+		img.Platform = desc.Platform.image()
+
+		if progress != nil {
+			progress(aura.PullProgress{ID: ref, Status: "Platform: " + img.Platform.String() + ", digest: " + img.Digest})
+		}
+		return img, nil
+	}
+
+	cfg, err := r.imageConfig(ctx, sess, m)
+	if err != nil {
+		return img, err
+	}
+	// Single platform images are only checked against an explicit
+	// platform, as they run wherever they are deployed to.
+	if !img.Platform.IsZero() && !cfg.image().Matches(img.Platform) {
+		return img, fmt.Errorf("image platform %s does not match %s", cfg.image(), img.Platform)
+	}
+
+	img.IndexDigest = ""
+	img.Digest = raw.digest
+	img.Platform = cfg.image()
+	return img, nil
+}
+
+// ExtractProcfile extracts the procfile in the working directory of the image.
+func (r *Registry) ExtractProcfile(ctx context.Context, ref string, auth *aura.RegistryAuth) ([]byte, error) {
+	img, err := image.Decode(ref)
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+
+	sess := newSession(img, auth)
+
+	m, err := r.imageManifest(ctx, sess, img)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := r.imageConfig(ctx, sess, m)
+	if err != nil {
+		return nil, err
+	}
+
+	file := cleanPath(path.Join(cfg.Config.WorkingDir, "Procfile"))
+
+	// Search the layers from the top, stopping at the first
+	// layer that contains or removes the procfile.
+	for i := len(m.Layers) - 1; i >= 0; i-- {
+		res, err := r.searchLayer(ctx, sess, m.Layers[i], file)
+		if err != nil {
+			return nil, fmt.Errorf("searching layer %s: %w", m.Layers[i].Digest, err)
+		}
+		if res.Found {
+			return res.Content, nil
+		}
+		if res.Hidden {
+			break
+		}
+	}
+	return nil, fmt.Errorf("procfile %q not found in image", "/"+file)
+}
+
+// platformOf returns the platform to resolve the image to.
+func (r *Registry) platformOf(img image.Image) image.Platform {
+	if !img.Platform.IsZero() {
+		return img.Platform
+	}
+	return r.platform
+}
+
+// imageManifest returns the image manifest, selecting the
+// manifest for the registry platform from an image index.
+func (r *Registry) imageManifest(ctx context.Context, sess *session, img image.Image) (manifest, error) {
+	ref := img.Digest
+	if ref == "" {
+		ref = img.Tag
+	}
+	if ref == "" {
+		ref = "latest"
+	}
+
+	raw, err := r.getManifest(ctx, sess, ref)
+	if err != nil {
+		return manifest{}, fmt.Errorf("getting manifest: %w", err)
+	}
+	m, err := decodeManifest(raw.body)
+	if err != nil {
+		return manifest{}, err
+	}
+	if !m.isIndex(raw.contentType) {
+		return m, nil
+	}
+
+	desc, ok := selectManifest(m.Manifests, r.platform)
+	if !ok {
+		return manifest{}, fmt.Errorf("no manifest for platform %s", r.platform)
+	}
+
+	raw, err = r.getManifest(ctx, sess, desc.Digest)
+	if err != nil {
+		return manifest{}, fmt.Errorf("getting platform manifest: %w", err)
+	}
+	return decodeManifest(raw.body)
+}
+
+func selectManifest(descs []descriptor, p image.Platform) (descriptor, bool) {
+	for _, desc := range descs {
+		if desc.Platform == nil {
+			continue
+		}
+		if desc.Platform.image().Matches(p) {
+			return desc, true
+		}
+	}
+	return descriptor{}, false
+}
+
+func (r *Registry) imageConfig(ctx context.Context, sess *session, m manifest) (imageConfig, error) {
+	b, err := r.getBlob(ctx, sess, m.Config.Digest)
+	if err != nil {
+		return imageConfig{}, fmt.Errorf("getting image config: %w", err)
+	}
+	var cfg imageConfig
+	if err = json.Unmarshal(b, &cfg); err != nil {
+		return imageConfig{}, fmt.Errorf("decoding image config: %w", err)
+	}
+	return cfg, nil
+}
+
+func (r *Registry) searchLayer(ctx context.Context, sess *session, desc descriptor, file string) (layerResult, error) {
+	resp, err := r.do(ctx, sess, http.MethodGet, "blobs/"+desc.Digest, "")
+	if err != nil {
+		return layerResult{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	lr, err := layerReader(desc.MediaType, resp.Body)
+	if err != nil {
+		return layerResult{}, err
+	}
+	return findFile(lr, file)
+}
+
+// rawManifest is a manifest as returned by the registry.
+type rawManifest struct {
+	body        []byte
+	contentType string
+	digest      string
+}
+
+func (r *Registry) getManifest(ctx context.Context, sess *session, ref string) (rawManifest, error) {
+	resp, err := r.do(ctx, sess, http.MethodGet, "manifests/"+ref, manifestAccept)
+	if err != nil {
+		return rawManifest{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	b, err := readAll(resp.Body, maxManifestSize)
+	if err != nil {
+		return rawManifest{}, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	raw := rawManifest{
+		body:        b,
+		contentType: resp.Header.Get("Content-Type"),
+		digest:      ref,
+	}
+	switch {
+	case strings.Contains(ref, ":"):
+		if err = verifyDigest(ref, b); err != nil {
+			return rawManifest{}, err
+		}
+	case resp.Header.Get("Docker-Content-Digest") != "":
+		raw.digest = resp.Header.Get("Docker-Content-Digest")
+		if err = verifyDigest(raw.digest, b); err != nil {
+			return rawManifest{}, err
+		}
+	default:
+		// The registry did not return the digest, compute it from the manifest.
+		raw.digest = digestOf(b)
+	}
+	return raw, nil
+}
+
+func (r *Registry) getBlob(ctx context.Context, sess *session, dgst string) ([]byte, error) {
+	resp, err := r.do(ctx, sess, http.MethodGet, "blobs/"+dgst, "")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	b, err := readAll(resp.Body, maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("reading blob: %w", err)
+	}
+	return b, verifyDigest(dgst, b)
+}
+
+// session contains the state of the requests to an image repository.
+type session struct {
+	host string
+	repo string
+	auth *aura.RegistryAuth
+
+	// authz is the authorization header, set once the
+	// registry has challenged a request.
+	authz string
+}
+
+func newSession(img image.Image, auth *aura.RegistryAuth) *session {
+	host, repo := repository(img)
+	return &session{
+		host: host,
+		repo: repo,
+		auth: auth,
+	}
+}
+
+// do performs a registry api request, returning an error if
+// the response is not successful.
+//
+// If the registry challenges the request, it is authorized
+// and retried.
+func (r *Registry) do(ctx context.Context, sess *session, method, p, accept string) (*http.Response, error) {
+	resp, err := r.doRequest(ctx, sess, method, p, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && sess.authz == "" {
+		_ = resp.Body.Close()
+
+		if err = r.authorize(ctx, sess, resp.Header.Get("WWW-Authenticate")); err != nil {
+			return nil, fmt.Errorf("authorizing with %s: %w", sess.host, err)
+		}
+		if resp, err = r.doRequest(ctx, sess, method, p, accept); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return resp, nil
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%s not found in %s/%s", p, sess.host, sess.repo)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("access to %s/%s denied", sess.host, sess.repo)
+	default:
+		return nil, fmt.Errorf("unexpected registry response %q", resp.Status)
+	}
+}
+
+func (r *Registry) doRequest(ctx context.Context, sess *session, method, p, accept string) (*http.Response, error) {
+	u := "https://" + sess.host + "/v2/" + sess.repo + "/" + p
+
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if sess.authz != "" {
+		req.Header.Set("Authorization", sess.authz)
+	}
+
+	return r.client.Do(req)
+}
+
+// repository returns the registry host and repository of the image.
+func repository(img image.Image) (string, string) {
+	host, repo := img.Registry, img.Repository
+	if host == "" || host == "docker.io" || host == "index.docker.io" {
+		host = defaultRegistry
+		if !strings.Contains(repo, "/") {
+			repo = defaultNamespace + "/" + repo
+		}
+	}
+	return host, repo
+}
+
+func readAll(r io.Reader, limit int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, errors.New("content is too large")
+	}
+	return b, nil
+}
@@ -0,0 +1,606 @@
+package oci_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/oci"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Resolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		image      string
+		noDigest   bool
+		wantDigest bool
+		wantErr    require.ErrorAssertionFunc
+	}{
+		{
+			name:       "handles resolving a tag",
+			image:      "foo/bar:1.0",
+			wantDigest: true,
+			wantErr:    require.NoError,
+		},
+		{
+			name:       "handles registry without digest header",
+			image:      "foo/bar:1.0",
+			noDigest:   true,
+			wantDigest: true,
+			wantErr:    require.NoError,
+		},
+		{
+			name:    "handles unknown tag",
+			image:   "foo/bar:2.0",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles unknown repository",
+			image:   "foo/baz:1.0",
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			reg := newTestRegistry(t)
+			reg.noDigest = test.noDigest
+			want := reg.pushImage(t, "foo/bar", "1.0", "", testLayer(t, tarEntry{name: "Procfile", body: "web: ./app"}))
+
+			img, err := image.Decode(reg.host + "/" + test.image)
+			require.NoError(t, err)
+
+			r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+			var pulls []aura.PullProgress
+			got, err := r.Resolve(context.Background(), img, nil, func(p aura.PullProgress) {
+				pulls = append(pulls, p)
+			})
+
+			test.wantErr(t, err)
+			if test.wantDigest {
+				assert.Equal(t, want, got.Digest)
+				assert.Equal(t, reg.host, got.Registry)
+				assert.Equal(t, "foo/bar", got.Repository)
+				assert.Equal(t, image.Platform{OS: "linux", Architecture: "amd64"}, got.Platform)
+				assert.Empty(t, got.IndexDigest)
+				assert.Equal(t, []aura.PullProgress{{ID: "1.0", Status: "Digest: " + want}}, pulls)
+			}
+		})
+	}
+}
+
+func TestRegistry_ResolveHandlesDigest(t *testing.T) {
+	reg := newTestRegistry(t)
+	dgst := reg.pushImage(t, "foo/bar", "1.0", "")
+
+	img, err := image.Decode(reg.host + "/foo/bar@" + dgst)
+	require.NoError(t, err)
+
+	r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+	got, err := r.Resolve(context.Background(), img, nil, nil)
+
+	require.NoError(t, err)
+	want := img
+	want.Platform = image.Platform{OS: "linux", Architecture: "amd64"}
+	assert.Equal(t, want, got)
+}
+
+func TestRegistry_ResolveHandlesIndex(t *testing.T) {
+	tests := []struct {
+		name         string
+		platform     image.Platform
+		imgPlatform  image.Platform
+		wantPlatform image.Platform
+		wantTag      string
+		wantErr      require.ErrorAssertionFunc
+	}{
+		{
+			name:         "handles default platform",
+			wantPlatform: image.Platform{OS: "linux", Architecture: "amd64"},
+			wantTag:      "amd",
+			wantErr:      require.NoError,
+		},
+		{
+			name:         "handles registry platform",
+			platform:     image.Platform{OS: "linux", Architecture: "arm64"},
+			wantPlatform: image.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+			wantTag:      "arm64",
+			wantErr:      require.NoError,
+		},
+		{
+			name:         "handles image platform",
+			platform:     image.Platform{OS: "linux", Architecture: "arm64"},
+			imgPlatform:  image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			wantPlatform: image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			wantTag:      "arm",
+			wantErr:      require.NoError,
+		},
+		{
+			name:        "handles platform variant",
+			imgPlatform: image.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			wantErr:     require.Error,
+		},
+		{
+			name:        "handles unknown platform",
+			imgPlatform: image.Platform{OS: "windows", Architecture: "amd64"},
+			wantErr:     require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			reg := newTestRegistry(t)
+			digests := map[string]string{
+				"amd":   reg.pushImage(t, "foo/bar", "amd", ""),
+				"arm64": reg.pushImage(t, "foo/bar", "arm64", ""),
+				"arm":   reg.pushImage(t, "foo/bar", "arm", ""),
+			}
+			index := reg.pushIndex(t, "foo/bar", "1.0", map[string]string{
+				"linux/amd64":    digests["amd"],
+				"linux/arm64/v8": digests["arm64"],
+				"linux/arm/v7":   digests["arm"],
+			})
+
+			img, err := image.Decode(reg.host + "/foo/bar:1.0")
+			require.NoError(t, err)
+			img.Platform = test.imgPlatform
+
+			opts := []oci.Option{oci.WithHTTPClient(reg.srv.Client())}
+			if !test.platform.IsZero() {
+				opts = append(opts, oci.WithPlatform(test.platform))
+			}
+			r := oci.NewRegistry(opts...)
+
+			var pulls []aura.PullProgress
+			got, err := r.Resolve(context.Background(), img, nil, func(p aura.PullProgress) {
+				pulls = append(pulls, p)
+			})
+
+			test.wantErr(t, err)
+			if test.wantTag == "" {
+				return
+			}
+			assert.Equal(t, digests[test.wantTag], got.Digest)
+			assert.Equal(t, index, got.IndexDigest)
+			assert.Equal(t, test.wantPlatform, got.Platform)
+			assert.Equal(t, []aura.PullProgress{
+				{ID: "1.0", Status: "Digest: " + index},
+				{ID: "1.0", Status: "Platform: " + test.wantPlatform.String() + ", digest: " + digests[test.wantTag]},
+			}, pulls)
+		})
+	}
+}
+
+func TestRegistry_ResolveHandlesPlatformMismatch(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.pushImage(t, "foo/bar", "1.0", "")
+
+	img, err := image.Decode(reg.host + "/foo/bar:1.0")
+	require.NoError(t, err)
+	img.Platform = image.Platform{OS: "linux", Architecture: "arm64"}
+
+	r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+	_, err = r.Resolve(context.Background(), img, nil, nil)
+
+	assert.Error(t, err)
+}
+
+func TestRegistry_ExtractProcfile(t *testing.T) {
+	tests := []struct {
+		name       string
+		workingDir string
+		layers     [][]tarEntry
+		want       []byte
+		wantErr    require.ErrorAssertionFunc
+	}{
+		{
+			name: "handles procfile in root",
+			layers: [][]tarEntry{
+				{{name: "Procfile", body: "web: ./app"}},
+			},
+			want:    []byte("web: ./app"),
+			wantErr: require.NoError,
+		},
+		{
+			name:       "handles procfile in working dir",
+			workingDir: "/app",
+			layers: [][]tarEntry{
+				{{name: "Procfile", body: "web: ./root"}},
+				{{name: "./app/", dir: true}, {name: "./app/Procfile", body: "web: ./app"}},
+			},
+			want:    []byte("web: ./app"),
+			wantErr: require.NoError,
+		},
+		{
+			name:       "handles procfile overridden in upper layer",
+			workingDir: "/app",
+			layers: [][]tarEntry{
+				{{name: "app/Procfile", body: "web: ./old"}},
+				{{name: "app/Procfile", body: "web: ./new"}},
+				{{name: "app/other", body: "other"}},
+			},
+			want:    []byte("web: ./new"),
+			wantErr: require.NoError,
+		},
+		{
+			name:       "handles procfile whiteout",
+			workingDir: "/app",
+			layers: [][]tarEntry{
+				{{name: "app/Procfile", body: "web: ./app"}},
+				{{name: "app/.wh.Procfile"}},
+			},
+			wantErr: require.Error,
+		},
+		{
+			name:       "handles working dir whiteout",
+			workingDir: "/app",
+			layers: [][]tarEntry{
+				{{name: "app/Procfile", body: "web: ./app"}},
+				{{name: ".wh.app"}},
+			},
+			wantErr: require.Error,
+		},
+		{
+			name:       "handles opaque working dir",
+			workingDir: "/app",
+			layers: [][]tarEntry{
+				{{name: "app/Procfile", body: "web: ./app"}},
+				{{name: "app/.wh..wh..opq"}, {name: "app/other", body: "other"}},
+			},
+			wantErr: require.Error,
+		},
+		{
+			name:       "handles procfile in opaque working dir",
+			workingDir: "/app",
+			layers: [][]tarEntry{
+				{{name: "app/Procfile", body: "web: ./old"}},
+				{{name: "app/.wh..wh..opq"}, {name: "app/Procfile", body: "web: ./new"}},
+			},
+			want:    []byte("web: ./new"),
+			wantErr: require.NoError,
+		},
+		{
+			name:       "handles whiteout of another file",
+			workingDir: "/app",
+			layers: [][]tarEntry{
+				{{name: "app/Procfile", body: "web: ./app"}},
+				{{name: "app/.wh.Procfile.bak"}},
+			},
+			want:    []byte("web: ./app"),
+			wantErr: require.NoError,
+		},
+		{
+			name: "handles procfile that is not a file",
+			layers: [][]tarEntry{
+				{{name: "Procfile", link: "/etc/passwd"}},
+			},
+			wantErr: require.Error,
+		},
+		{
+			name: "handles no procfile",
+			layers: [][]tarEntry{
+				{{name: "app", body: "binary"}},
+			},
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			reg := newTestRegistry(t)
+
+			var layers []testBlob
+			for _, entries := range test.layers {
+				layers = append(layers, testLayer(t, entries...))
+			}
+			dgst := reg.pushImage(t, "foo/bar", "1.0", test.workingDir, layers...)
+
+			r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+			got, err := r.ExtractProcfile(context.Background(), reg.host+"/foo/bar@"+dgst, nil)
+
+			test.wantErr(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestRegistry_ExtractProcfileOnlyFetchesNeededLayers(t *testing.T) {
+	reg := newTestRegistry(t)
+	lower := testLayer(t, tarEntry{name: "Procfile", body: "web: ./old"})
+	upper := testLayer(t, tarEntry{name: "Procfile", body: "web: ./new"})
+	reg.pushImage(t, "foo/bar", "1.0", "", lower, upper)
+
+	r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+	got, err := r.ExtractProcfile(context.Background(), reg.host+"/foo/bar:1.0", nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte("web: ./new"), got)
+	assert.Contains(t, reg.requests(), "GET /v2/foo/bar/blobs/"+upper.digest)
+	assert.NotContains(t, reg.requests(), "GET /v2/foo/bar/blobs/"+lower.digest)
+}
+
+func TestRegistry_ExtractProcfileHandlesUncompressedLayers(t *testing.T) {
+	reg := newTestRegistry(t)
+	layer := testBlob{mediaType: oci.MediaTypeOCILayer, body: testTar(t, tarEntry{name: "Procfile", body: "web: ./app"})}
+	layer.digest = digestOf(layer.body)
+	reg.pushImage(t, "foo/bar", "1.0", "", layer)
+
+	r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+	got, err := r.ExtractProcfile(context.Background(), reg.host+"/foo/bar:1.0", nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte("web: ./app"), got)
+}
+
+func TestRegistry_ExtractProcfileHandlesIndex(t *testing.T) {
+	reg := newTestRegistry(t)
+	arm := reg.pushImage(t, "foo/bar", "arm", "", testLayer(t, tarEntry{name: "Procfile", body: "web: ./arm"}))
+	amd := reg.pushImage(t, "foo/bar", "amd", "", testLayer(t, tarEntry{name: "Procfile", body: "web: ./amd"}))
+	reg.pushIndex(t, "foo/bar", "1.0", map[string]string{"linux/arm64": arm, "linux/amd64": amd})
+
+	r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+	got, err := r.ExtractProcfile(context.Background(), reg.host+"/foo/bar:1.0", nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte("web: ./amd"), got)
+}
+
+func TestRegistry_ExtractProcfileHandlesIndexWithPlatform(t *testing.T) {
+	reg := newTestRegistry(t)
+	arm := reg.pushImage(t, "foo/bar", "arm", "", testLayer(t, tarEntry{name: "Procfile", body: "web: ./arm"}))
+	amd := reg.pushImage(t, "foo/bar", "amd", "", testLayer(t, tarEntry{name: "Procfile", body: "web: ./amd"}))
+	reg.pushIndex(t, "foo/bar", "1.0", map[string]string{"linux/arm64": arm, "linux/amd64": amd})
+
+	r := oci.NewRegistry(
+		oci.WithHTTPClient(reg.srv.Client()),
+		oci.WithPlatform(image.Platform{OS: "linux", Architecture: "arm64"}),
+	)
+
+	got, err := r.ExtractProcfile(context.Background(), reg.host+"/foo/bar:1.0", nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte("web: ./arm"), got)
+}
+
+func TestRegistry_ExtractProcfileHandlesIndexWithoutPlatform(t *testing.T) {
+	reg := newTestRegistry(t)
+	arm := reg.pushImage(t, "foo/bar", "arm", "", testLayer(t, tarEntry{name: "Procfile", body: "web: ./arm"}))
+	reg.pushIndex(t, "foo/bar", "1.0", map[string]string{"linux/arm64": arm})
+
+	r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+	_, err := r.ExtractProcfile(context.Background(), reg.host+"/foo/bar:1.0", nil)
+
+	assert.Error(t, err)
+}
+
+func TestRegistry_ExtractProcfileHandlesDigestMismatch(t *testing.T) {
+	reg := newTestRegistry(t)
+	dgst := reg.pushImage(t, "foo/bar", "1.0", "", testLayer(t, tarEntry{name: "Procfile", body: "web: ./app"}))
+	m := reg.manifests["foo/bar/"+dgst]
+	m.body = append(m.body, ' ')
+	reg.manifests["foo/bar/"+dgst] = m
+
+	r := oci.NewRegistry(oci.WithHTTPClient(reg.srv.Client()))
+
+	_, err := r.ExtractProcfile(context.Background(), reg.host+"/foo/bar@"+dgst, nil)
+
+	assert.Error(t, err)
+}
+
+type testBlob struct {
+	mediaType string
+	digest    string
+	body      []byte
+}
+
+// testRegistry is an in-memory OCI distribution registry.
+type testRegistry struct {
+	srv  *httptest.Server
+	host string
+
+	noDigest  bool
+	auth      *testAuth
+	manifests map[string]testBlob
+	blobs     map[string]testBlob
+
+	mu   sync.Mutex
+	reqs []string
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+
+	reg := &testRegistry{
+		manifests: map[string]testBlob{},
+		blobs:     map[string]testBlob{},
+	}
+	reg.srv = httptest.NewTLSServer(http.HandlerFunc(reg.handle))
+	t.Cleanup(reg.srv.Close)
+	reg.host = strings.TrimPrefix(reg.srv.URL, "https://")
+
+	return reg
+}
+
+func (r *testRegistry) handle(rw http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.reqs = append(r.reqs, req.Method+" "+req.URL.Path)
+	r.mu.Unlock()
+
+	if r.auth != nil && !r.auth.authorized(rw, req, r.srv.URL) {
+		return
+	}
+
+	p := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if i := strings.Index(p, "/manifests/"); i >= 0 {
+		m, ok := r.manifests[p[:i]+"/"+p[i+len("/manifests/"):]]
+		if !ok {
+			http.Error(rw, `{"errors":[{"code":"MANIFEST_UNKNOWN"}]}`, http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("Content-Type", m.mediaType)
+		if !r.noDigest {
+			rw.Header().Set("Docker-Content-Digest", m.digest)
+		}
+		if req.Method == http.MethodHead {
+			return
+		}
+		_, _ = rw.Write(m.body)
+		return
+	}
+	if i := strings.Index(p, "/blobs/"); i >= 0 {
+		b, ok := r.blobs[p[:i]+"/"+p[i+len("/blobs/"):]]
+		if !ok {
+			http.Error(rw, `{"errors":[{"code":"BLOB_UNKNOWN"}]}`, http.StatusNotFound)
+			return
+		}
+		_, _ = rw.Write(b.body)
+		return
+	}
+	http.NotFound(rw, req)
+}
+
+func (r *testRegistry) requests() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.reqs...)
+}
+
+// pushImage adds an image to the registry, returning its manifest digest.
+func (r *testRegistry) pushImage(t *testing.T, repo, tag, workingDir string, layers ...testBlob) string {
+	t.Helper()
+
+	cfgBody, err := json.Marshal(map[string]any{
+		"architecture": "amd64",
+		"os":           "linux",
+		"config":       map[string]any{"WorkingDir": workingDir},
+	})
+	require.NoError(t, err)
+	cfg := testBlob{mediaType: "application/vnd.oci.image.config.v1+json", digest: digestOf(cfgBody), body: cfgBody}
+	r.blobs[repo+"/"+cfg.digest] = cfg
+
+	descs := make([]map[string]any, 0, len(layers))
+	for _, layer := range layers {
+		r.blobs[repo+"/"+layer.digest] = layer
+		descs = append(descs, map[string]any{"mediaType": layer.mediaType, "digest": layer.digest, "size": len(layer.body)})
+	}
+
+	return r.pushManifest(t, repo, tag, oci.MediaTypeOCIManifest, map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     oci.MediaTypeOCIManifest,
+		"config":        map[string]any{"mediaType": cfg.mediaType, "digest": cfg.digest, "size": len(cfg.body)},
+		"layers":        descs,
+	})
+}
+
+// pushIndex adds an image index to the registry, with the manifest
+// digests keyed by os/arch[/variant].
+func (r *testRegistry) pushIndex(t *testing.T, repo, tag string, manifests map[string]string) string {
+	t.Helper()
+
+	descs := make([]map[string]any, 0, len(manifests))
+	for p, dgst := range manifests {
+		parts := strings.Split(p, "/")
+		plat := map[string]any{"os": parts[0], "architecture": parts[1]}
+		if len(parts) == 3 {
+			plat["variant"] = parts[2]
+		}
+		descs = append(descs, map[string]any{
+			"mediaType": oci.MediaTypeOCIManifest,
+			"digest":    dgst,
+			"size":      len(r.manifests[repo+"/"+dgst].body),
+			"platform":  plat,
+		})
+	}
+
+	return r.pushManifest(t, repo, tag, oci.MediaTypeOCIIndex, map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     oci.MediaTypeOCIIndex,
+		"manifests":     descs,
+	})
+}
+
+func (r *testRegistry) pushManifest(t *testing.T, repo, tag, mediaType string, v any) string {
+	t.Helper()
+
+	body, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	m := testBlob{mediaType: mediaType, digest: digestOf(body), body: body}
+	r.manifests[repo+"/"+tag] = m
+	r.manifests[repo+"/"+m.digest] = m
+	return m.digest
+}
+
+type tarEntry struct {
+	name string
+	body string
+	link string
+	dir  bool
+}
+
+// testLayer returns a gzipped layer blob of the entries.
+func testLayer(t *testing.T, entries ...tarEntry) testBlob {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err := gw.Write(testTar(t, entries...))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	return testBlob{mediaType: oci.MediaTypeOCILayerGz, digest: digestOf(buf.Bytes()), body: buf.Bytes()}
+}
+
+func testTar(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		switch {
+		case e.dir:
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.link
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Size > 0 {
+			_, err := tw.Write([]byte(e.body))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+
+	return buf.Bytes()
+}
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -3,6 +3,8 @@ package image
 import (
 	"database/sql/driver"
 	"errors"
+	"fmt"
+	"regexp"
 	"strings"
 )
 
@@ -12,6 +14,16 @@ type Image struct {
 	Repository string
 	Tag        string
 	Digest     string
+
+	// IndexDigest is the digest of the image index the image was
+	// resolved from, when the image is multi-platform. It is not
+	// part of the image reference.
+	IndexDigest string
+
+	// Platform is the platform of the image. When set, multi-platform
+	// images are resolved to the manifest of the platform. It is not
+	// part of the image reference.
+	Platform Platform
 }
 
 // Scan decodes an image from a database field.
@@ -40,65 +52,236 @@ func (i Image) String() string {
 	if i.Registry != "" {
 		repo = i.Registry + "/" + repo
 	}
+	return repo + i.suffix()
+}
 
-	switch {
-	case i.Digest != "":
-		return repo + "@" + i.Digest
-	case i.Tag != "":
-		return repo + ":" + i.Tag
+// Familiar returns the short form of the image reference, as shown by
+// the docker cli, omitting the default registry and namespace.
+func (i Image) Familiar() string {
+	repo := i.Repository
+	switch i.Registry {
+	case "", DefaultRegistry:
+		if name := strings.TrimPrefix(repo, DefaultNamespace+"/"); name != repo && !strings.Contains(name, "/") {
+			repo = name
+		}
 	default:
-		return repo
+		repo = i.Registry + "/" + repo
+	}
+	return repo + i.suffix()
+}
+
+func (i Image) suffix() string {
+	var s string
+	if i.Tag != "" {
+		s += ":" + i.Tag
 	}
+	if i.Digest != "" {
+		s += "@" + i.Digest
+	}
+	return s
 }
 
-// Decode decodes a docker image reference.
-func Decode(img string) (Image, error) {
-	repo, tag := splitRepositoryTag(img)
-	reg, repo := splitRepository(repo)
+// Docker Hub defaults, used to normalize image references.
+const (
+	DefaultRegistry  = "docker.io"
+	DefaultNamespace = "library"
+
+	legacyRegistry = "index.docker.io"
+)
+
+// nameMaxLength is the maximum length of a normalized image name.
+const nameMaxLength = 255
+
+// Image reference errors.
+var (
+	ErrInvalidFormat     = errors.New("invalid image format")
+	ErrNameUppercase     = errors.New("repository name must be lowercase")
+	ErrNameTooLong       = fmt.Errorf("repository name must not be more than %d characters", nameMaxLength)
+	ErrInvalidTag        = errors.New("invalid tag format")
+	ErrInvalidDigest     = errors.New("invalid digest format")
+	ErrUnsupportedDigest = errors.New("unsupported digest algorithm")
+)
+
+// The reference grammar, as defined by the distribution project.
+var (
+	domainRegexp = regexp.MustCompile(`^(?:` + domainName + `|\[[a-fA-F0-9:]+\])(?::[0-9]+)?$`)
+	pathRegexp   = regexp.MustCompile(`^` + pathComponent + `(?:/` + pathComponent + `)*$`)
+	tagRegexp    = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+)
 
-	if repo == "" {
-		return Image{}, errors.New("invalid image format")
+const (
+	domainComponent = `(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])`
+	domainName      = domainComponent + `(?:\.` + domainComponent + `)*`
+	pathComponent   = `[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*`
+)
+
+// digestLengths are the encoded lengths of the supported digest algorithms.
+var digestLengths = map[string]int{
+	"sha256": 64,
+	"sha384": 96,
+	"sha512": 128,
+}
+
+// Decode decodes a docker image reference, normalizing it to its
+// canonical form.
+//
+// A reference has the form [registry/]path[:tag][@digest]. The first path
+// component is the registry if it contains a dot or colon, or is localhost.
+// Images without a registry are on Docker Hub, and single component Docker
+// Hub images are in the library namespace.
+func Decode(ref string) (Image, error) {
+	if ref == "" {
+		return Image{}, ErrInvalidFormat
+	}
+
+	name, dgst, hasDigest := strings.Cut(ref, "@")
+	if hasDigest {
+		if err := validateDigest(dgst); err != nil {
+			return Image{}, err
+		}
+	}
+
+	var tag string
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i+1:], "/") {
+		name, tag = name[:i], name[i+1:]
+		if !tagRegexp.MatchString(tag) {
+			return Image{}, fmt.Errorf("%w %q", ErrInvalidTag, tag)
+		}
+	}
+
+	reg, repo := splitRegistry(name)
+	if !domainRegexp.MatchString(reg) {
+		return Image{}, ErrInvalidFormat
+	}
+	if strings.ToLower(repo) != repo {
+		return Image{}, ErrNameUppercase
+	}
+	if !pathRegexp.MatchString(repo) {
+		return Image{}, ErrInvalidFormat
+	}
+
+	if reg == DefaultRegistry && !strings.Contains(repo, "/") {
+		repo = DefaultNamespace + "/" + repo
+	}
+	if len(reg)+1+len(repo) > nameMaxLength {
+		return Image{}, ErrNameTooLong
 	}
 
-	i := Image{
+	return Image{
 		Registry:   reg,
 		Repository: repo,
+		Tag:        tag,
+		Digest:     dgst,
+	}, nil
+}
+
+// splitRegistry splits the registry from the image name,
+// defaulting to Docker Hub.
+func splitRegistry(name string) (string, string) {
+	reg, repo, ok := strings.Cut(name, "/")
+	if !ok || (!strings.ContainsAny(reg, ".:") && reg != "localhost" && strings.ToLower(reg) == reg) {
+		return DefaultRegistry, name
+	}
+	if reg == legacyRegistry {
+		reg = DefaultRegistry
+	}
+	return reg, repo
+}
+
+func validateDigest(dgst string) error {
+	if !digestRegexp.MatchString(dgst) {
+		return fmt.Errorf("%w %q", ErrInvalidDigest, dgst)
+	}
+
+	alg, hex, _ := strings.Cut(dgst, ":")
+	n, ok := digestLengths[alg]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnsupportedDigest, alg)
 	}
-	if strings.Contains(tag, ":") {
-		i.Digest = tag
-	} else {
-		i.Tag = tag
+	if len(hex) != n || strings.ToLower(hex) != hex {
+		return fmt.Errorf("%w %q", ErrInvalidDigest, dgst)
 	}
-	return i, nil
+	return nil
+}
+
+// Platform contains the info for an image platform.
+type Platform struct {
+	OS           string
+	Architecture string
+	Variant      string
 }
 
-// Taken from https://github.com/docker/docker/blob/50a1d0f0ef83a9ed55ea2caaa79539ec835877a3/pkg/parsers/parsers.go#L71-L89
-func splitRepositoryTag(repos string) (repo, tag string) {
-	n := strings.Index(repos, "@")
-	if n >= 0 {
-		parts := strings.Split(repos, "@")
-		return parts[0], parts[1]
+// ParsePlatform parses a platform in the form os/arch[/variant].
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(strings.ToLower(s), "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
 	}
-	n = strings.LastIndex(repos, ":")
-	if n < 0 {
-		return repos, ""
+	for _, part := range parts {
+		if part == "" {
+			return Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+		}
 	}
-	if tag := repos[n+1:]; !strings.Contains(tag, "/") {
-		return repos[:n], tag
+
+	p := Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
 	}
-	return repos, ""
+	return p, nil
 }
 
-func splitRepository(fullRepo string) (registry, path string) {
-	parts := strings.Split(fullRepo, "/")
+// IsZero determines if the platform is not set.
+func (p Platform) IsZero() bool {
+	return p == Platform{}
+}
 
-	if len(parts) < 2 {
-		return "", parts[0]
+// Matches determines if the platform satisfies the wanted platform.
+// A wanted platform without a variant matches any variant.
+func (p Platform) Matches(want Platform) bool {
+	if p.OS != want.OS || p.Architecture != want.Architecture {
+		return false
 	}
+	return want.Variant == "" || normalizeVariant(p) == normalizeVariant(want)
+}
 
-	if len(parts) == 2 {
-		return "", strings.Join(parts, "/")
+// normalizeVariant returns the variant of the platform, with
+// the default variant of the architecture if it has none.
+func normalizeVariant(p Platform) string {
+	if p.Variant == "" && p.Architecture == "arm64" {
+		return "v8"
 	}
+	return p.Variant
+}
 
-	return parts[0], strings.Join(parts[1:], "/")
+// Scan decodes a platform from a database field.
+func (p *Platform) Scan(src any) error {
+	b, ok := src.(string)
+	if !ok {
+		return nil
+	}
+
+	plat, err := ParsePlatform(b)
+	if err != nil {
+		return err
+	}
+	*p = plat
+	return nil
+}
+
+// Value encodes a platform into a database field.
+func (p Platform) Value() (driver.Value, error) {
+	return driver.Value(p.String()), nil
+}
+
+// String returns the string representation of a platform.
+func (p Platform) String() string {
+	if p.IsZero() {
+		return ""
+	}
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
 }
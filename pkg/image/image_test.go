@@ -2,6 +2,7 @@ package image_test
 
 import (
 	"database/sql/driver"
+	"strings"
 	"testing"
 
 	"github.com/nrwiersma/aura/pkg/image"
@@ -19,7 +20,7 @@ func TestImage_Scan(t *testing.T) {
 		{
 			name:    "handles valid type",
 			in:      "foo/bar:latest",
-			want:    image.Image{Repository: "foo/bar", Tag: "latest"},
+			want:    image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"},
 			wantErr: require.NoError,
 		},
 		{
@@ -72,6 +73,11 @@ func TestImage_String(t *testing.T) {
 			img:  image.Image{Registry: "foo", Repository: "bar/baz", Digest: "sha256:c3ab8"},
 			want: "foo/bar/baz@sha256:c3ab8",
 		},
+		{
+			name: "handles image with tag and digest",
+			img:  image.Image{Registry: "foo", Repository: "bar/baz", Tag: "1.0", Digest: "sha256:c3ab8"},
+			want: "foo/bar/baz:1.0@sha256:c3ab8",
+		},
 		{
 			name: "handles image with no tag or digest",
 			img:  image.Image{Registry: "foo", Repository: "bar/baz"},
@@ -89,6 +95,49 @@ func TestImage_String(t *testing.T) {
 	}
 }
 
+func TestImage_Familiar(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		want string
+	}{
+		{
+			name: "handles library image",
+			img:  image.Image{Registry: "docker.io", Repository: "library/nginx", Tag: "latest"},
+			want: "nginx:latest",
+		},
+		{
+			name: "handles docker hub image",
+			img:  image.Image{Registry: "docker.io", Repository: "foo/bar", Digest: "sha256:c3ab8"},
+			want: "foo/bar@sha256:c3ab8",
+		},
+		{
+			name: "handles nested library image",
+			img:  image.Image{Registry: "docker.io", Repository: "library/foo/bar"},
+			want: "library/foo/bar",
+		},
+		{
+			name: "handles image without registry",
+			img:  image.Image{Repository: "library/nginx"},
+			want: "nginx",
+		},
+		{
+			name: "handles other registry",
+			img:  image.Image{Registry: "localhost:5000", Repository: "library/nginx", Tag: "1.0"},
+			want: "localhost:5000/library/nginx:1.0",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got := test.img.Familiar()
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
 func TestDecode(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -99,13 +148,19 @@ func TestDecode(t *testing.T) {
 		{
 			name:    "handles image with tag",
 			img:     "foo/bar:latest",
-			want:    image.Image{Repository: "foo/bar", Tag: "latest"},
+			want:    image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"},
 			wantErr: require.NoError,
 		},
 		{
 			name:    "handles image with digest",
-			img:     "foo/bar@sha256:c3ab8",
-			want:    image.Image{Repository: "foo/bar", Digest: "sha256:c3ab8"},
+			img:     "foo/bar@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2",
+			want:    image.Image{Registry: "docker.io", Repository: "foo/bar", Digest: "sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles image with tag and digest",
+			img:     "foo/bar:1.0@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2",
+			want:    image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "1.0", Digest: "sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"},
 			wantErr: require.NoError,
 		},
 		{
@@ -114,16 +169,70 @@ func TestDecode(t *testing.T) {
 			want:    image.Image{Registry: "ghcr.io", Repository: "foo/bar", Tag: "latest"},
 			wantErr: require.NoError,
 		},
+		{
+			name:    "handles image with registry port",
+			img:     "localhost:5000/foo",
+			want:    image.Image{Registry: "localhost:5000", Repository: "foo"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles image with registry port and tag",
+			img:     "127.0.0.1:5000/foo/bar:1.0",
+			want:    image.Image{Registry: "127.0.0.1:5000", Repository: "foo/bar", Tag: "1.0"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles image with localhost registry",
+			img:     "localhost/foo",
+			want:    image.Image{Registry: "localhost", Repository: "foo"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles image with ipv6 registry",
+			img:     "[::1]:5000/foo",
+			want:    image.Image{Registry: "[::1]:5000", Repository: "foo"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles image with uppercase registry",
+			img:     "Foo/bar",
+			want:    image.Image{Registry: "Foo", Repository: "bar"},
+			wantErr: require.NoError,
+		},
 		{
 			name:    "handles image with single part repo",
 			img:     "bar:latest",
-			want:    image.Image{Repository: "bar", Tag: "latest"},
+			want:    image.Image{Registry: "docker.io", Repository: "library/bar", Tag: "latest"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles image with single part docker hub repo",
+			img:     "docker.io/bar",
+			want:    image.Image{Registry: "docker.io", Repository: "library/bar"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles canonical image",
+			img:     "docker.io/library/nginx",
+			want:    image.Image{Registry: "docker.io", Repository: "library/nginx"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles legacy docker hub registry",
+			img:     "index.docker.io/foo/bar",
+			want:    image.Image{Registry: "docker.io", Repository: "foo/bar"},
 			wantErr: require.NoError,
 		},
 		{
 			name:    "handles image with no tag",
 			img:     "foo/bar",
-			want:    image.Image{Repository: "foo/bar"},
+			want:    image.Image{Registry: "docker.io", Repository: "foo/bar"},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles image with separators",
+			img:     "foo/bar_baz.qux__quux---corge",
+			want:    image.Image{Registry: "docker.io", Repository: "foo/bar_baz.qux__quux---corge"},
 			wantErr: require.NoError,
 		},
 		{
@@ -134,8 +243,62 @@ func TestDecode(t *testing.T) {
 		{
 			name:    "handles image with colon in repo",
 			img:     "foo/bar:baz/bat",
-			want:    image.Image{Registry: "foo", Repository: "bar:baz/bat"},
-			wantErr: require.NoError,
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with uppercase repo",
+			img:     "foo/Bar",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with invalid separator",
+			img:     "foo/bar..baz",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with empty path component",
+			img:     "ghcr.io/foo//bar",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with invalid registry",
+			img:     "-foo.io/bar",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with invalid tag",
+			img:     "foo/bar:-1.0",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with too long tag",
+			img:     "foo/bar:" + strings.Repeat("a", 129),
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with short digest",
+			img:     "foo/bar@sha256:c3ab8",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with uppercase digest",
+			img:     "foo/bar@" + strings.ToUpper("sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2"),
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with unsupported digest algorithm",
+			img:     "foo/bar@md5:c3ab8ff13720e8ad9047dd39466b3c89",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with multiple digests",
+			img:     "foo/bar@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles image with too long name",
+			img:     "foo/" + strings.Repeat("a", 250),
+			wantErr: require.Error,
 		},
 	}
 
@@ -149,3 +312,237 @@ func TestDecode(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		img  string
+		want error
+	}{
+		{
+			name: "handles empty image",
+			img:  "",
+			want: image.ErrInvalidFormat,
+		},
+		{
+			name: "handles uppercase repository",
+			img:  "foo/Bar",
+			want: image.ErrNameUppercase,
+		},
+		{
+			name: "handles long name",
+			img:  "foo/" + strings.Repeat("a", 250),
+			want: image.ErrNameTooLong,
+		},
+		{
+			name: "handles invalid tag",
+			img:  "foo/bar:-1.0",
+			want: image.ErrInvalidTag,
+		},
+		{
+			name: "handles invalid digest",
+			img:  "foo/bar@sha256:c3ab8",
+			want: image.ErrInvalidDigest,
+		},
+		{
+			name: "handles unsupported digest",
+			img:  "foo/bar@md5:c3ab8ff13720e8ad9047dd39466b3c89",
+			want: image.ErrUnsupportedDigest,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := image.Decode(test.img)
+
+			assert.ErrorIs(t, err, test.want)
+		})
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, s := range []string{
+		"nginx",
+		"foo/bar:latest",
+		"docker.io/library/nginx:1.0",
+		"index.docker.io/foo/bar",
+		"localhost:5000/foo",
+		"localhost:5000",
+		"[::1]:5000/foo/bar:1.0",
+		"Foo/bar",
+		"ghcr.io/foo/bar:1.0@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2",
+		"foo/bar@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2@sha256:c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2",
+		"foo/bar:baz/bat",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		img, err := image.Decode(s)
+		if err != nil {
+			return
+		}
+
+		// Decoding the canonical and familiar forms yields the same image.
+		got, err := image.Decode(img.String())
+		require.NoError(t, err)
+		assert.Equal(t, img, got)
+
+		got, err = image.Decode(img.Familiar())
+		require.NoError(t, err)
+		assert.Equal(t, img, got)
+	})
+}
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform string
+		want     image.Platform
+		wantErr  require.ErrorAssertionFunc
+	}{
+		{
+			name:     "handles os and architecture",
+			platform: "linux/amd64",
+			want:     image.Platform{OS: "linux", Architecture: "amd64"},
+			wantErr:  require.NoError,
+		},
+		{
+			name:     "handles variant",
+			platform: "Linux/ARM/v7",
+			want:     image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			wantErr:  require.NoError,
+		},
+		{
+			name:     "handles no architecture",
+			platform: "linux",
+			wantErr:  require.Error,
+		},
+		{
+			name:     "handles empty part",
+			platform: "linux//v7",
+			wantErr:  require.Error,
+		},
+		{
+			name:     "handles too many parts",
+			platform: "linux/arm/v7/foo",
+			wantErr:  require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got, err := image.ParsePlatform(test.platform)
+
+			test.wantErr(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestPlatform_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform image.Platform
+		want     string
+	}{
+		{
+			name:     "handles platform",
+			platform: image.Platform{OS: "linux", Architecture: "amd64"},
+			want:     "linux/amd64",
+		},
+		{
+			name:     "handles platform with variant",
+			platform: image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			want:     "linux/arm/v7",
+		},
+		{
+			name: "handles empty platform",
+			want: "",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got := test.platform.String()
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestPlatform_Matches(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform image.Platform
+		want     image.Platform
+		wantOK   bool
+	}{
+		{
+			name:     "handles matching platform",
+			platform: image.Platform{OS: "linux", Architecture: "amd64"},
+			want:     image.Platform{OS: "linux", Architecture: "amd64"},
+			wantOK:   true,
+		},
+		{
+			name:     "handles any variant",
+			platform: image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			want:     image.Platform{OS: "linux", Architecture: "arm"},
+			wantOK:   true,
+		},
+		{
+			name:     "handles different variant",
+			platform: image.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			want:     image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			wantOK:   false,
+		},
+		{
+			name:     "handles default arm64 variant",
+			platform: image.Platform{OS: "linux", Architecture: "arm64"},
+			want:     image.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+			wantOK:   true,
+		},
+		{
+			name:     "handles different architecture",
+			platform: image.Platform{OS: "linux", Architecture: "arm64"},
+			want:     image.Platform{OS: "linux", Architecture: "amd64"},
+			wantOK:   false,
+		},
+		{
+			name:     "handles different os",
+			platform: image.Platform{OS: "windows", Architecture: "amd64"},
+			want:     image.Platform{OS: "linux", Architecture: "amd64"},
+			wantOK:   false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got := test.platform.Matches(test.want)
+
+			assert.Equal(t, test.wantOK, got)
+		})
+	}
+}
+
+func TestPlatform_Scan(t *testing.T) {
+	p := &image.Platform{}
+
+	err := p.Scan("linux/arm64")
+
+	require.NoError(t, err)
+	assert.Equal(t, image.Platform{OS: "linux", Architecture: "arm64"}, *p)
+}
+
+func TestPlatform_Value(t *testing.T) {
+	p := image.Platform{OS: "linux", Architecture: "arm64"}
+
+	got, err := p.Value()
+
+	require.NoError(t, err)
+	assert.Equal(t, driver.Value("linux/arm64"), got)
+}
@@ -0,0 +1,227 @@
+// Package keyring implements envelope encryption under a set of master keys.
+//
+// Each value is encrypted with a random data key, which in turn is
+// encrypted with the primary master key. Older master keys are kept
+// to decrypt existing data keys until they are rewrapped.
+package keyring
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const keySize = 32
+
+// Key is a master key.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseKey parses a key in the form "id:base64-secret".
+func ParseKey(s string) (Key, error) {
+	id, secret, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || id == "" {
+		return Key{}, errors.New("key must be in the form id:secret")
+	}
+
+	b, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return Key{}, fmt.Errorf("decoding key %q: %w", id, err)
+	}
+	if len(b) != keySize {
+		return Key{}, fmt.Errorf("key %q must be %d bytes", id, keySize)
+	}
+
+	return Key{ID: id, Secret: b}, nil
+}
+
+// ParseKeys parses keys from a reader, one key per line.
+//
+// Empty lines and lines starting with "#" are ignored.
+func ParseKeys(r io.Reader) ([]Key, error) {
+	var keys []Key
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, err := ParseKey(line)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Envelope contains an encrypted value and its encrypted data key.
+type Envelope struct {
+	// KeyID is the ID of the master key that encrypted the data key.
+	KeyID      string
+	DataKey    []byte
+	Ciphertext []byte
+}
+
+// Keyring encrypts and decrypts values using envelope encryption.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// New returns a keyring. The first key is the primary key,
+// used to encrypt new data keys.
+func New(keys ...Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key is required")
+	}
+
+	kr := &Keyring{
+		primary: keys[0].ID,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+	for _, key := range keys {
+		if _, ok := kr.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key %q", key.ID)
+		}
+
+		aead, err := newAEAD(key.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("creating cipher for key %q: %w", key.ID, err)
+		}
+		kr.keys[key.ID] = aead
+	}
+
+	return kr, nil
+}
+
+// Primary returns the ID of the primary key.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Seal encrypts the plaintext under a new data key.
+//
+// The additional data is authenticated, but not encrypted, and must
+// be given again to open the envelope.
+func (k *Keyring) Seal(plaintext, additionalData []byte) (Envelope, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return Envelope{}, fmt.Errorf("generating data key: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return Envelope{}, err
+	}
+	ciphertext, err := seal(aead, plaintext, additionalData)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	encDataKey, err := seal(k.keys[k.primary], dataKey, nil)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		KeyID:      k.primary,
+		DataKey:    encDataKey,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// Open decrypts the envelope.
+func (k *Keyring) Open(env Envelope, additionalData []byte) ([]byte, error) {
+	dataKey, err := k.openDataKey(env)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(aead, env.Ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Rewrap encrypts the data key of the envelope with the primary key.
+//
+// The value itself is not re-encrypted. If the data key is
+// already encrypted with the primary key, the envelope is returned as is.
+func (k *Keyring) Rewrap(env Envelope) (Envelope, bool, error) {
+	if env.KeyID == k.primary {
+		return env, false, nil
+	}
+
+	dataKey, err := k.openDataKey(env)
+	if err != nil {
+		return Envelope{}, false, err
+	}
+
+	encDataKey, err := seal(k.keys[k.primary], dataKey, nil)
+	if err != nil {
+		return Envelope{}, false, err
+	}
+
+	return Envelope{
+		KeyID:      k.primary,
+		DataKey:    encDataKey,
+		Ciphertext: env.Ciphertext,
+	}, true, nil
+}
+
+func (k *Keyring) openDataKey(env Envelope) ([]byte, error) {
+	aead, ok := k.keys[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", env.KeyID)
+	}
+
+	dataKey, err := open(aead, env.DataKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	n := aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, ciphertext[:n], ciphertext[n:], additionalData)
+}
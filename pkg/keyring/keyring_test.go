@@ -0,0 +1,153 @@
+package keyring_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nrwiersma/aura/pkg/keyring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	key1 = "key1:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	key2 = "key2:ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    keyring.Key
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "handles valid key",
+			in:      key1,
+			want:    keyring.Key{ID: "key1", Secret: []byte("0123456789abcdef0123456789abcdef")},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles no id",
+			in:      "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles invalid base64",
+			in:      "key1:not base64",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles invalid key size",
+			in:      "key1:MDEyMzQ1Njc4OWFiY2RlZg==",
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got, err := keyring.ParseKey(test.in)
+
+			test.wantErr(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	in := "# Primary key\n" + key2 + "\n\n" + key1 + "\n"
+
+	got, err := keyring.ParseKeys(strings.NewReader(in))
+
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "key2", got[0].ID)
+	assert.Equal(t, "key1", got[1].ID)
+}
+
+func TestNew_HandlesErrors(t *testing.T) {
+	k1 := mustParseKey(t, key1)
+
+	_, err := keyring.New()
+	assert.Error(t, err)
+
+	_, err = keyring.New(k1, k1)
+	assert.Error(t, err)
+
+	_, err = keyring.New(keyring.Key{ID: "bad", Secret: []byte("short")})
+	assert.Error(t, err)
+}
+
+func TestKeyring_SealOpen(t *testing.T) {
+	kr, err := keyring.New(mustParseKey(t, key1))
+	require.NoError(t, err)
+
+	env, err := kr.Seal([]byte("my secret"), []byte("app/name"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "key1", env.KeyID)
+	assert.False(t, bytes.Contains(env.Ciphertext, []byte("my secret")))
+	got, err := kr.Open(env, []byte("app/name"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("my secret"), got)
+}
+
+func TestKeyring_OpenHandlesWrongAdditionalData(t *testing.T) {
+	kr, err := keyring.New(mustParseKey(t, key1))
+	require.NoError(t, err)
+
+	env, err := kr.Seal([]byte("my secret"), []byte("app/name"))
+	require.NoError(t, err)
+
+	_, err = kr.Open(env, []byte("app/other"))
+
+	assert.Error(t, err)
+}
+
+func TestKeyring_OpenHandlesUnknownKey(t *testing.T) {
+	old, err := keyring.New(mustParseKey(t, key1))
+	require.NoError(t, err)
+	env, err := old.Seal([]byte("my secret"), nil)
+	require.NoError(t, err)
+
+	kr, err := keyring.New(mustParseKey(t, key2))
+	require.NoError(t, err)
+
+	_, err = kr.Open(env, nil)
+
+	assert.Error(t, err)
+}
+
+func TestKeyring_Rewrap(t *testing.T) {
+	old, err := keyring.New(mustParseKey(t, key1))
+	require.NoError(t, err)
+	env, err := old.Seal([]byte("my secret"), nil)
+	require.NoError(t, err)
+
+	kr, err := keyring.New(mustParseKey(t, key2), mustParseKey(t, key1))
+	require.NoError(t, err)
+
+	got, ok, err := kr.Rewrap(env)
+
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "key2", got.KeyID)
+	assert.Equal(t, env.Ciphertext, got.Ciphertext)
+	b, err := kr.Open(got, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("my secret"), b)
+
+	_, ok, err = kr.Rewrap(got)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func mustParseKey(t *testing.T, s string) keyring.Key {
+	t.Helper()
+
+	key, err := keyring.ParseKey(s)
+	require.NoError(t, err)
+	return key
+}
@@ -0,0 +1,163 @@
+package procfile
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type extendedProcess struct {
+	Command     string  `yaml:"command"`
+	Port        int     `yaml:"port"`
+	HealthCheck string  `yaml:"healthcheck"`
+	Instances   int     `yaml:"instances"`
+	Memory      string  `yaml:"memory"`
+	CPU         float64 `yaml:"cpu"`
+	Schedule    string  `yaml:"schedule"`
+}
+
+// extendedRoot returns the root mapping of an extended procfile.
+//
+// A procfile is considered extended when it is valid YAML and
+// at least one process is defined as a mapping.
+func extendedRoot(b []byte) (*yaml.Node, bool) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil || len(doc.Content) == 0 {
+		return nil, false
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, false
+	}
+	for i := 1; i < len(root.Content); i += 2 {
+		if root.Content[i].Kind == yaml.MappingNode {
+			return root, true
+		}
+	}
+	return nil, false
+}
+
+func parseExtended(root *yaml.Node) ([]Process, error) {
+	procs := make([]Process, 0, len(root.Content)/2)
+	seen := map[string]bool{}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+
+		name := key.Value
+		switch {
+		case key.Kind != yaml.ScalarNode || !nameRegexp.MatchString(name):
+			return nil, Error{Line: key.Line, Msg: fmt.Sprintf("invalid process name %q", name)}
+		case seen[name]:
+			return nil, Error{Line: key.Line, Msg: fmt.Sprintf("duplicate process %q", name)}
+		}
+		seen[name] = true
+
+		proc, err := parseExtendedProcess(name, val)
+		if err != nil {
+			return nil, err
+		}
+		procs = append(procs, proc)
+	}
+
+	if len(procs) == 0 {
+		return nil, errNoProcesses
+	}
+	return procs, nil
+}
+
+func parseExtendedProcess(name string, node *yaml.Node) (Process, error) {
+	var def extendedProcess
+	switch node.Kind {
+	case yaml.ScalarNode:
+		// Allow the short form "name: command" in an extended procfile.
+		def.Command = node.Value
+	case yaml.MappingNode:
+		if err := node.Decode(&def); err != nil {
+			return Process{}, Error{Line: node.Line, Msg: fmt.Sprintf("invalid process %q: %v", name, err)}
+		}
+	default:
+		return Process{}, Error{Line: node.Line, Msg: fmt.Sprintf("invalid process %q", name)}
+	}
+
+	proc := Process{
+		Name:        name,
+		Command:     strings.TrimSpace(def.Command),
+		Port:        def.Port,
+		HealthCheck: def.HealthCheck,
+		Instances:   def.Instances,
+		CPU:         def.CPU,
+		Schedule:    strings.TrimSpace(def.Schedule),
+	}
+
+	var msg string
+	switch {
+	case proc.Command == "":
+		msg = "has no command"
+	case proc.Port < 0 || proc.Port > 65535:
+		msg = "has an invalid port"
+	case proc.HealthCheck != "" && !strings.HasPrefix(proc.HealthCheck, "/"):
+		msg = "has an invalid health check path"
+	case proc.Instances < 0:
+		msg = "has an invalid number of instances"
+	case proc.CPU < 0:
+		msg = "has an invalid cpu limit"
+	case proc.Schedule != "" && !isSchedule(proc.Schedule):
+		msg = "has an invalid schedule"
+	}
+	if msg != "" {
+		return Process{}, Error{Line: node.Line, Msg: fmt.Sprintf("process %q %s", name, msg)}
+	}
+
+	if def.Memory != "" {
+		mem, err := parseMemory(def.Memory)
+		if err != nil {
+			return Process{}, Error{Line: node.Line, Msg: fmt.Sprintf("process %q has an invalid memory limit", name)}
+		}
+		proc.Memory = mem
+	}
+
+	return proc, nil
+}
+
+// isSchedule determines if s looks like a cron schedule.
+func isSchedule(s string) bool {
+	if strings.HasPrefix(s, "@") {
+		return len(s) > 1
+	}
+	return len(strings.Fields(s)) == 5
+}
+
+// parseMemory parses a memory size in the form "512m" or "1G" into bytes.
+func parseMemory(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "b")
+
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'k':
+			mult = 1 << 10
+		case 'm':
+			mult = 1 << 20
+		case 'g':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			s = s[:n-1]
+		}
+	}
+
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v <= 0 {
+		return 0, errors.New("memory must be positive")
+	}
+	return v * mult, nil
+}
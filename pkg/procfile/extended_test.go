@@ -0,0 +1,149 @@
+package procfile_test
+
+import (
+	"testing"
+
+	"github.com/nrwiersma/aura/pkg/procfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Extended(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []procfile.Process
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "handles extended procfile",
+			in: `web:
+  command: ./app --port=$PORT
+  port: 8080
+  healthcheck: /healthz
+  instances: 2
+  memory: 512M
+  cpu: 0.5
+worker: ./worker
+cleanup:
+  command: ./cleanup
+  schedule: "*/5 * * * *"
+`,
+			want: []procfile.Process{
+				{
+					Name:        "web",
+					Command:     "./app --port=$PORT",
+					Port:        8080,
+					HealthCheck: "/healthz",
+					Instances:   2,
+					Memory:      512 << 20,
+					CPU:         0.5,
+				},
+				{Name: "worker", Command: "./worker"},
+				{Name: "cleanup", Command: "./cleanup", Schedule: "*/5 * * * *"},
+			},
+			wantErr: require.NoError,
+		},
+		{
+			name: "handles memory units",
+			in: `web:
+  command: ./app
+  memory: 1gb
+`,
+			want: []procfile.Process{
+				{Name: "web", Command: "./app", Memory: 1 << 30},
+			},
+			wantErr: require.NoError,
+		},
+		{
+			name: "handles classic procfile that is valid yaml",
+			in:   "web: ./app\nworker: ./worker",
+			want: []procfile.Process{
+				{Name: "web", Command: "./app"},
+				{Name: "worker", Command: "./worker"},
+			},
+			wantErr: require.NoError,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got, err := procfile.Parse([]byte(test.in))
+
+			test.wantErr(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestParse_ExtendedErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr procfile.Error
+	}{
+		{
+			name:    "handles invalid name",
+			in:      "web app:\n  command: ./app",
+			wantErr: procfile.Error{Line: 1, Msg: `invalid process name "web app"`},
+		},
+		{
+			name:    "handles duplicate process",
+			in:      "web:\n  command: ./app\nweb:\n  command: ./other",
+			wantErr: procfile.Error{Line: 3, Msg: `duplicate process "web"`},
+		},
+		{
+			name:    "handles no command",
+			in:      "web:\n  port: 8080",
+			wantErr: procfile.Error{Line: 2, Msg: `process "web" has no command`},
+		},
+		{
+			name:    "handles invalid port",
+			in:      "web:\n  command: ./app\n  port: 70000",
+			wantErr: procfile.Error{Line: 2, Msg: `process "web" has an invalid port`},
+		},
+		{
+			name:    "handles invalid health check",
+			in:      "web:\n  command: ./app\n  healthcheck: healthz",
+			wantErr: procfile.Error{Line: 2, Msg: `process "web" has an invalid health check path`},
+		},
+		{
+			name:    "handles invalid instances",
+			in:      "web:\n  command: ./app\n  instances: -1",
+			wantErr: procfile.Error{Line: 2, Msg: `process "web" has an invalid number of instances`},
+		},
+		{
+			name:    "handles invalid memory",
+			in:      "web:\n  command: ./app\n  memory: lots",
+			wantErr: procfile.Error{Line: 2, Msg: `process "web" has an invalid memory limit`},
+		},
+		{
+			name:    "handles invalid cpu",
+			in:      "web:\n  command: ./app\n  cpu: -1",
+			wantErr: procfile.Error{Line: 2, Msg: `process "web" has an invalid cpu limit`},
+		},
+		{
+			name:    "handles invalid schedule",
+			in:      "cron:\n  command: ./app\n  schedule: daily",
+			wantErr: procfile.Error{Line: 2, Msg: `process "cron" has an invalid schedule`},
+		},
+		{
+			name:    "handles invalid field type",
+			in:      "web:\n  command: ./app\n  port: abc",
+			wantErr: procfile.Error{Line: 2, Msg: "invalid process \"web\": yaml: unmarshal errors:\n  line 3: cannot unmarshal !!str `abc` into int"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := procfile.Parse([]byte(test.in))
+
+			require.Error(t, err)
+			var got procfile.Error
+			require.ErrorAs(t, err, &got)
+			assert.Equal(t, test.wantErr, got)
+		})
+	}
+}
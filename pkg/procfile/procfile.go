@@ -0,0 +1,102 @@
+// Package procfile implements parsing of classic and extended YAML procfiles.
+package procfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+var errNoProcesses = errors.New("no processes defined")
+
+// Error is returned when a procfile line is invalid.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+// Error stringifies the error.
+func (e Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Process contains a process definition.
+type Process struct {
+	Name    string
+	Command string
+
+	// Port is the port the process listens on.
+	Port int
+	// HealthCheck is the HTTP path used to check the health of the process.
+	HealthCheck string
+	// Instances is the default number of instances. Zero means the default of one.
+	Instances int
+	// Memory is the memory limit in bytes.
+	Memory int64
+	// CPU is the CPU limit in cores.
+	CPU float64
+	// Schedule is the cron schedule of the process.
+	Schedule string
+}
+
+// Parse parses a procfile.
+//
+// Both the classic "name: command" format and the extended
+// YAML format are supported. The format is detected from the content.
+func Parse(b []byte) ([]Process, error) {
+	if root, ok := extendedRoot(b); ok {
+		return parseExtended(root)
+	}
+	return parseClassic(b)
+}
+
+func parseClassic(b []byte) ([]Process, error) {
+	var procs []Process
+	seen := map[string]bool{}
+
+	var lineNo int
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		lineNo++
+
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, cmd, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, Error{Line: lineNo, Msg: "expected a process definition in the form \"name: command\""}
+		}
+		name = strings.TrimSpace(name)
+		cmd = strings.TrimSpace(cmd)
+
+		switch {
+		case !nameRegexp.MatchString(name):
+			return nil, Error{Line: lineNo, Msg: fmt.Sprintf("invalid process name %q", name)}
+		case seen[name]:
+			return nil, Error{Line: lineNo, Msg: fmt.Sprintf("duplicate process %q", name)}
+		case cmd == "":
+			return nil, Error{Line: lineNo, Msg: fmt.Sprintf("process %q has no command", name)}
+		}
+		seen[name] = true
+
+		procs = append(procs, Process{
+			Name:    name,
+			Command: cmd,
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(procs) == 0 {
+		return nil, errNoProcesses
+	}
+	return procs, nil
+}
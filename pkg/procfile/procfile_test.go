@@ -0,0 +1,97 @@
+package procfile_test
+
+import (
+	"testing"
+
+	"github.com/nrwiersma/aura/pkg/procfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []procfile.Process
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "handles procfile",
+			in:   "web: ./app --port=$PORT\nworker: ./worker",
+			want: []procfile.Process{
+				{Name: "web", Command: "./app --port=$PORT"},
+				{Name: "worker", Command: "./worker"},
+			},
+			wantErr: require.NoError,
+		},
+		{
+			name: "handles empty lines and comments",
+			in:   "\n# The web process\nweb: ./app\n\n",
+			want: []procfile.Process{
+				{Name: "web", Command: "./app"},
+			},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles empty procfile",
+			in:      "\n# Nothing here\n",
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got, err := procfile.Parse([]byte(test.in))
+
+			test.wantErr(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr procfile.Error
+	}{
+		{
+			name:    "handles invalid line",
+			in:      "web: ./app\nworker ./worker",
+			wantErr: procfile.Error{Line: 2, Msg: `expected a process definition in the form "name: command"`},
+		},
+		{
+			name:    "handles invalid name",
+			in:      "\nweb app: ./app",
+			wantErr: procfile.Error{Line: 2, Msg: `invalid process name "web app"`},
+		},
+		{
+			name:    "handles empty name",
+			in:      ": ./app",
+			wantErr: procfile.Error{Line: 1, Msg: `invalid process name ""`},
+		},
+		{
+			name:    "handles duplicate process",
+			in:      "web: ./app\n# Comment\nweb: ./other",
+			wantErr: procfile.Error{Line: 3, Msg: `duplicate process "web"`},
+		},
+		{
+			name:    "handles empty command",
+			in:      "web:  ",
+			wantErr: procfile.Error{Line: 1, Msg: `process "web" has no command`},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := procfile.Parse([]byte(test.in))
+
+			require.Error(t, err)
+			var got procfile.Error
+			require.ErrorAs(t, err, &got)
+			assert.Equal(t, test.wantErr, got)
+		})
+	}
+}
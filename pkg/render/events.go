@@ -0,0 +1,80 @@
+package render
+
+import (
+	"bytes"
+	"net/http"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Event stream content types.
+const (
+	ContentTypeEventStream = "text/event-stream"
+	ContentTypeNDJSON      = "application/x-ndjson"
+)
+
+// EventWriter writes a stream of json events, either as
+// server-sent events or as json lines.
+type EventWriter struct {
+	rw          http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+// NewEventWriter returns an event writer for the given content type.
+func NewEventWriter(rw http.ResponseWriter, contentType string) *EventWriter {
+	return &EventWriter{
+		rw:          rw,
+		contentType: contentType,
+	}
+}
+
+// Started determines if the stream has been started.
+//
+// Once started, errors can only be reported as events.
+func (w *EventWriter) Started() bool {
+	return w.started
+}
+
+// Write writes an event and flushes it to the client.
+func (w *EventWriter) Write(event string, v interface{}) error {
+	data, err := jsoniter.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch w.contentType {
+	case ContentTypeEventStream:
+		buf.WriteString("event: ")
+		buf.WriteString(event)
+		buf.WriteString("\ndata: ")
+		buf.Write(data)
+		buf.WriteString("\n\n")
+	default:
+		b, err := jsoniter.Marshal(struct {
+			Event string              `json:"event"`
+			Data  jsoniter.RawMessage `json:"data"`
+		}{Event: event, Data: data})
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	if !w.started {
+		w.rw.Header().Set("Content-Type", w.contentType)
+		w.rw.Header().Set("Cache-Control", "no-cache")
+		w.rw.WriteHeader(http.StatusOK)
+		w.started = true
+	}
+
+	if _, err = w.rw.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if f, ok := w.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
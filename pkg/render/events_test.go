@@ -0,0 +1,51 @@
+package render_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nrwiersma/aura/pkg/render"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventWriter_Write(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		want        string
+	}{
+		{
+			name:        "writes server-sent events",
+			contentType: render.ContentTypeEventStream,
+			want:        "event: test\ndata: {\"a\":1,\"b\":\"one\"}\n\nevent: test\ndata: {\"a\":2,\"b\":\"two\"}\n\n",
+		},
+		{
+			name:        "writes json lines",
+			contentType: render.ContentTypeNDJSON,
+			want:        "{\"event\":\"test\",\"data\":{\"a\":1,\"b\":\"one\"}}\n{\"event\":\"test\",\"data\":{\"a\":2,\"b\":\"two\"}}\n",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			w := render.NewEventWriter(rec, test.contentType)
+			assert.False(t, w.Started())
+
+			err := w.Write("test", testMessage{A: 1, B: "one"})
+			require.NoError(t, err)
+			err = w.Write("test", testMessage{A: 2, B: "two"})
+			require.NoError(t, err)
+
+			assert.True(t, w.Started())
+			assert.True(t, rec.Flushed)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, test.contentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, test.want, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package aura
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nrwiersma/aura/pkg/image"
+	"gorm.io/gorm"
+)
+
+// Image policy rules.
+const (
+	PolicyRequireDigest = "require-digest"
+	PolicyForbidLatest  = "forbid-latest"
+	PolicyAllow         = "allow"
+	PolicyDeny          = "deny"
+)
+
+// ImagePolicy restricts the images that can be deployed.
+//
+// Allow and Deny contain glob patterns matched against the image name,
+// its registry and repository, or a prefix of its path components. For
+// example "ghcr.io" matches all images in the registry, and
+// "docker.io/library/*" matches all official Docker Hub images. Registries
+// are matched case-insensitively, and Docker Hub aliases match "docker.io".
+// Deny takes precedence over Allow, and an empty Allow allows all images.
+type ImagePolicy struct {
+	RequireDigest bool     `json:"requireDigest,omitempty"`
+	ForbidLatest  bool     `json:"forbidLatest,omitempty"`
+	Allow         []string `json:"allow,omitempty"`
+	Deny          []string `json:"deny,omitempty"`
+}
+
+// Scan decodes an image policy from a database field.
+func (p *ImagePolicy) Scan(src any) error {
+	var b []byte
+	switch val := src.(type) {
+	case string:
+		b = []byte(val)
+	case []byte:
+		b = val
+	default:
+		return nil
+	}
+
+	return json.Unmarshal(b, p)
+}
+
+// Value encodes an image policy into a database field.
+func (p ImagePolicy) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return driver.Value(string(b)), nil
+}
+
+// Validate validates an image policy.
+func (p ImagePolicy) Validate() error {
+	for _, pattern := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if pattern == "" || strings.HasPrefix(pattern, "/") || strings.HasSuffix(pattern, "/") {
+			return fmt.Errorf("invalid image pattern %q", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid image pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Check checks the image against the policy, returning a PolicyError
+// with the violated rule.
+func (p ImagePolicy) Check(img image.Image) error {
+	if p.RequireDigest && img.Digest == "" {
+		return PolicyError{Rule: PolicyRequireDigest, Reason: "the image must be referenced by digest"}
+	}
+	// An image without a tag or digest is the latest tag.
+	if p.ForbidLatest && (img.Tag == "latest" || img.Tag == "" && img.Digest == "") {
+		return PolicyError{Rule: PolicyForbidLatest, Reason: "the latest tag cannot be deployed"}
+	}
+
+	name := imageName(img)
+	for _, pattern := range p.Deny {
+		if matchImagePattern(pattern, name) {
+			return PolicyError{Rule: PolicyDeny, Reason: fmt.Sprintf("%s matches denied pattern %q", name, pattern)}
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		if matchImagePattern(pattern, name) {
+			return nil
+		}
+	}
+	return PolicyError{Rule: PolicyAllow, Reason: fmt.Sprintf("%s does not match an allowed pattern", name)}
+}
+
+// PolicyError is returned when an image violates an image policy.
+type PolicyError struct {
+	// Policy is the policy that was violated, either "server" or "app".
+	Policy string
+	Rule   string
+	Reason string
+}
+
+// Error stringifies the error.
+func (e PolicyError) Error() string {
+	msg := fmt.Sprintf("image violates policy rule %q: %s", e.Rule, e.Reason)
+	if e.Policy != "" {
+		msg = e.Policy + " " + msg
+	}
+	return msg
+}
+
+// dockerHubRegistries are the registry hosts of Docker Hub.
+var dockerHubRegistries = map[string]bool{
+	"docker.io":            true,
+	"index.docker.io":      true,
+	"registry-1.docker.io": true,
+}
+
+// canonicalRegistry returns the canonical registry host, as hosts
+// are case-insensitive and Docker Hub has several hosts.
+func canonicalRegistry(reg string) string {
+	reg = strings.ToLower(reg)
+	if reg == "" || dockerHubRegistries[reg] {
+		return image.DefaultRegistry
+	}
+	return reg
+}
+
+// imageName returns the canonical registry and repository of the image.
+func imageName(img image.Image) string {
+	reg := canonicalRegistry(img.Registry)
+	repo := img.Repository
+	if reg == image.DefaultRegistry && !strings.Contains(repo, "/") {
+		repo = image.DefaultNamespace + "/" + repo
+	}
+	return reg + "/" + repo
+}
+
+// matchImagePattern determines if the pattern matches the
+// image name, or a prefix of its path components.
+func matchImagePattern(pattern, name string) bool {
+	reg, repo, hasRepo := strings.Cut(pattern, "/")
+	pattern = canonicalRegistry(reg)
+	if hasRepo {
+		pattern += "/" + repo
+	}
+
+	n := strings.Count(pattern, "/") + 1
+	parts := strings.Split(name, "/")
+	if len(parts) < n {
+		return false
+	}
+
+	ok, err := path.Match(pattern, strings.Join(parts[:n], "/"))
+	return err == nil && ok
+}
+
+// checkImagePolicy checks the image against the server
+// and application image policies.
+func (a *Aura) checkImagePolicy(app *App, img image.Image) error {
+	policies := []struct {
+		name   string
+		policy ImagePolicy
+	}{
+		{name: "server", policy: a.imagePolicy},
+		{name: "app", policy: app.ImagePolicy},
+	}
+	for _, p := range policies {
+		if err := p.policy.Check(img); err != nil {
+			var policyErr PolicyError
+			if errors.As(err, &policyErr) {
+				policyErr.Policy = p.name
+				err = policyErr
+			}
+			return ValidationError{err: err}
+		}
+	}
+	return nil
+}
+
+// checkReleasePolicy checks the image of a release against the server
+// and application image policies, as the policies may have changed
+// since it was deployed.
+func (a *Aura) checkReleasePolicy(app *App, release *Release) error {
+	if release.Image == nil {
+		return nil
+	}
+	return a.checkImagePolicy(app, *release.Image)
+}
+
+// SetImagePolicyConfig contains image policy update configuration.
+type SetImagePolicyConfig struct {
+	App *App
+
+	Policy ImagePolicy
+}
+
+// Validate validates a set image policy configuration.
+func (c SetImagePolicyConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+
+	return c.Policy.Validate()
+}
+
+// SetImagePolicy replaces the image policy of an application.
+//
+// The policy is enforced on deploy, in addition to the server policy.
+func (a *Aura) SetImagePolicy(ctx context.Context, cfg SetImagePolicyConfig) (*App, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+
+	app, err := a.apps.Modify(ctx, cfg.App.ID, []string{"image_policy"}, func(app *App) {
+		app.ImagePolicy = cfg.Policy
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("could not update app: %w", err)
+		}
+	}
+	return app, nil
+}
@@ -0,0 +1,346 @@
+package aura_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/memory"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAura_SetImagePolicy(t *testing.T) {
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	assert.Equal(t, aura.ImagePolicy{}, app.ImagePolicy)
+
+	policy := aura.ImagePolicy{
+		RequireDigest: true,
+		Allow:         []string{"ghcr.io/foo/*"},
+		Deny:          []string{"ghcr.io/foo/bad"},
+	}
+	got, err := a.SetImagePolicy(context.Background(), aura.SetImagePolicyConfig{App: app, Policy: policy})
+
+	require.NoError(t, err)
+	assert.Equal(t, policy, got.ImagePolicy)
+	found, err := a.App(context.Background(), aura.AppsQuery{ID: app.ID})
+	require.NoError(t, err)
+	assert.Equal(t, policy, found.ImagePolicy)
+}
+
+func TestAura_SetImagePolicyHandlesValidationError(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  aura.SetImagePolicyConfig
+	}{
+		{
+			name: "no app",
+			cfg:  aura.SetImagePolicyConfig{},
+		},
+		{
+			name: "invalid app",
+			cfg:  aura.SetImagePolicyConfig{App: &aura.App{}},
+		},
+		{
+			name: "empty pattern",
+			cfg:  aura.SetImagePolicyConfig{App: &aura.App{ID: "123"}, Policy: aura.ImagePolicy{Allow: []string{""}}},
+		},
+		{
+			name: "bad pattern",
+			cfg:  aura.SetImagePolicyConfig{App: &aura.App{ID: "123"}, Policy: aura.ImagePolicy{Deny: []string{"ghcr.io/[foo"}}},
+		},
+		{
+			name: "trailing slash",
+			cfg:  aura.SetImagePolicyConfig{App: &aura.App{ID: "123"}, Policy: aura.ImagePolicy{Allow: []string{"ghcr.io/"}}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			a := aura.New(db, &mockRegistry{}, &mockScheduler{})
+
+			_, err := a.SetImagePolicy(context.Background(), test.cfg)
+
+			assert.ErrorAs(t, err, &aura.ValidationError{})
+		})
+	}
+}
+
+func TestImagePolicy_Check(t *testing.T) {
+	dgst := "sha256:" + strings.Repeat("a", 64)
+
+	tests := []struct {
+		name     string
+		policy   aura.ImagePolicy
+		image    string
+		wantRule string
+	}{
+		{
+			name:  "empty policy",
+			image: "foo/bar",
+		},
+		{
+			name:   "digest required",
+			policy: aura.ImagePolicy{RequireDigest: true},
+			image:  "foo/bar@" + dgst,
+		},
+		{
+			name:     "digest required without digest",
+			policy:   aura.ImagePolicy{RequireDigest: true},
+			image:    "foo/bar:1.0",
+			wantRule: aura.PolicyRequireDigest,
+		},
+		{
+			name:   "latest forbidden",
+			policy: aura.ImagePolicy{ForbidLatest: true},
+			image:  "foo/bar:1.0",
+		},
+		{
+			name:     "latest forbidden with latest tag",
+			policy:   aura.ImagePolicy{ForbidLatest: true},
+			image:    "foo/bar:latest",
+			wantRule: aura.PolicyForbidLatest,
+		},
+		{
+			name:     "latest forbidden without tag",
+			policy:   aura.ImagePolicy{ForbidLatest: true},
+			image:    "foo/bar",
+			wantRule: aura.PolicyForbidLatest,
+		},
+		{
+			name:   "latest forbidden with digest",
+			policy: aura.ImagePolicy{ForbidLatest: true},
+			image:  "foo/bar@" + dgst,
+		},
+		{
+			name:   "allowed registry",
+			policy: aura.ImagePolicy{Allow: []string{"ghcr.io"}},
+			image:  "ghcr.io/foo/bar:1.0",
+		},
+		{
+			name:   "allowed repository prefix",
+			policy: aura.ImagePolicy{Allow: []string{"ghcr.io/foo/*"}},
+			image:  "ghcr.io/foo/bar/baz:1.0",
+		},
+		{
+			name:   "allowed docker hub image",
+			policy: aura.ImagePolicy{Allow: []string{"docker.io/library/*"}},
+			image:  "nginx:1.0",
+		},
+		{
+			name:     "not allowed",
+			policy:   aura.ImagePolicy{Allow: []string{"ghcr.io/foo/*"}},
+			image:    "ghcr.io/bar/baz:1.0",
+			wantRule: aura.PolicyAllow,
+		},
+		{
+			name:     "not allowed partial component",
+			policy:   aura.ImagePolicy{Allow: []string{"ghcr.io/foo"}},
+			image:    "ghcr.io/foobar/baz:1.0",
+			wantRule: aura.PolicyAllow,
+		},
+		{
+			name:     "denied",
+			policy:   aura.ImagePolicy{Deny: []string{"*.example.com"}},
+			image:    "registry.example.com/foo/bar:1.0",
+			wantRule: aura.PolicyDeny,
+		},
+		{
+			name:     "denied registry in upper case",
+			policy:   aura.ImagePolicy{Deny: []string{"ghcr.io/*"}},
+			image:    "GHCR.IO/evil/x:1.0",
+			wantRule: aura.PolicyDeny,
+		},
+		{
+			name:     "denied upper case registry pattern",
+			policy:   aura.ImagePolicy{Deny: []string{"GHCR.IO/*"}},
+			image:    "ghcr.io/evil/x:1.0",
+			wantRule: aura.PolicyDeny,
+		},
+		{
+			name:     "denied docker hub alias",
+			policy:   aura.ImagePolicy{Deny: []string{"docker.io/library/*"}},
+			image:    "registry-1.docker.io/library/nginx:1.0",
+			wantRule: aura.PolicyDeny,
+		},
+		{
+			name:     "denied docker hub alias without namespace",
+			policy:   aura.ImagePolicy{Deny: []string{"docker.io/library/*"}},
+			image:    "Registry-1.Docker.IO/nginx:1.0",
+			wantRule: aura.PolicyDeny,
+		},
+		{
+			name:     "denied docker hub alias pattern",
+			policy:   aura.ImagePolicy{Deny: []string{"index.docker.io"}},
+			image:    "nginx:1.0",
+			wantRule: aura.PolicyDeny,
+		},
+		{
+			name:     "not allowed docker hub alias",
+			policy:   aura.ImagePolicy{Allow: []string{"ghcr.io"}},
+			image:    "registry-1.docker.io/foo/bar:1.0",
+			wantRule: aura.PolicyAllow,
+		},
+		{
+			name:     "deny takes precedence",
+			policy:   aura.ImagePolicy{Allow: []string{"ghcr.io"}, Deny: []string{"ghcr.io/foo/bad"}},
+			image:    "ghcr.io/foo/bad:1.0",
+			wantRule: aura.PolicyDeny,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			img, err := image.Decode(test.image)
+			require.NoError(t, err)
+
+			err = test.policy.Check(img)
+
+			if test.wantRule == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var policyErr aura.PolicyError
+			require.ErrorAs(t, err, &policyErr)
+			assert.Equal(t, test.wantRule, policyErr.Rule)
+		})
+	}
+}
+
+func TestAura_DeployEnforcesImagePolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     aura.ImagePolicy
+		app        aura.ImagePolicy
+		wantPolicy string
+		wantRule   string
+	}{
+		{
+			name:       "server policy",
+			server:     aura.ImagePolicy{ForbidLatest: true},
+			app:        aura.ImagePolicy{Allow: []string{"ghcr.io"}},
+			wantPolicy: "server",
+			wantRule:   aura.PolicyForbidLatest,
+		},
+		{
+			name:       "app policy",
+			server:     aura.ImagePolicy{Allow: []string{"docker.io"}},
+			app:        aura.ImagePolicy{Deny: []string{"docker.io/foo/*"}},
+			wantPolicy: "app",
+			wantRule:   aura.PolicyDeny,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			img, err := image.Decode("foo/bar:latest")
+			require.NoError(t, err)
+
+			db := testDB(t)
+			reg := &mockRegistry{}
+			a := aura.New(db, reg, &mockScheduler{}, aura.WithImagePolicy(test.server))
+
+			app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+			require.NoError(t, err)
+			app, err = a.SetImagePolicy(context.Background(), aura.SetImagePolicyConfig{App: app, Policy: test.app})
+			require.NoError(t, err)
+
+			_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+			require.ErrorAs(t, err, &aura.ValidationError{})
+			var policyErr aura.PolicyError
+			require.ErrorAs(t, err, &policyErr)
+			assert.Equal(t, test.wantPolicy, policyErr.Policy)
+			assert.Equal(t, test.wantRule, policyErr.Rule)
+			reg.AssertNotCalled(t, "Resolve", mock.Anything)
+			releases, err := a.Releases(context.Background(), aura.ReleasesQuery{App: app})
+			require.NoError(t, err)
+			assert.Empty(t, releases)
+		})
+	}
+}
+
+func TestAura_ReleaseChangesEnforceImagePolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(a *aura.Aura, app *aura.App) error
+	}{
+		{
+			name: "redeploy",
+			fn: func(a *aura.Aura, app *aura.App) error {
+				_, err := a.Redeploy(context.Background(), aura.RedeployConfig{App: app})
+				return err
+			},
+		},
+		{
+			name: "rollback",
+			fn: func(a *aura.Aura, app *aura.App) error {
+				_, err := a.Rollback(context.Background(), aura.RollbackConfig{App: app, Version: 1})
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "1.0.0"}
+
+			db := testDB(t)
+			reg := &mockRegistry{}
+			reg.On("Resolve", img).Return(img, nil)
+			reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+
+			a := aura.New(db, reg, memory.NewScheduler())
+
+			app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+			require.NoError(t, err)
+			_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+			require.NoError(t, err)
+			app, err = a.SetImagePolicy(context.Background(), aura.SetImagePolicyConfig{App: app, Policy: aura.ImagePolicy{
+				Deny: []string{"docker.io/foo/*"},
+			}})
+			require.NoError(t, err)
+
+			err = test.fn(a, app)
+
+			require.ErrorAs(t, err, &aura.ValidationError{})
+			var policyErr aura.PolicyError
+			require.ErrorAs(t, err, &policyErr)
+			assert.Equal(t, "app", policyErr.Policy)
+			assert.Equal(t, aura.PolicyDeny, policyErr.Rule)
+			releases, err := a.Releases(context.Background(), aura.ReleasesQuery{App: app})
+			require.NoError(t, err)
+			assert.Len(t, releases, 1)
+		})
+	}
+}
+
+func TestAura_CreateDeploymentEnforcesImagePolicy(t *testing.T) {
+	img, err := image.Decode("foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, &mockScheduler{}, aura.WithImagePolicy(aura.ImagePolicy{RequireDigest: true}))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.CreateDeployment(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	var policyErr aura.PolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, aura.PolicyRequireDigest, policyErr.Rule)
+	assert.EqualError(t, err, `server image violates policy rule "require-digest": the image must be referenced by digest`)
+}
@@ -0,0 +1,87 @@
+package aura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Process contains the info of a running process.
+type Process struct {
+	ID        string
+	Type      string
+	Command   string
+	Version   int
+	State     string
+	CreatedAt *time.Time
+}
+
+// Run contains the info of a one-off process run.
+type Run struct {
+	ID      string
+	Command string
+	Attach  bool
+	// ExitCode is the exit code of an attached run.
+	ExitCode *int
+	// Output is the combined output of an attached run.
+	Output []byte
+}
+
+// Processes returns the running processes of an application.
+func (a *Aura) Processes(ctx context.Context, app *App) ([]*Process, error) {
+	procs, err := a.sched.Processes(ctx, app)
+	if err != nil {
+		return nil, fmt.Errorf("could not get processes: %w", err)
+	}
+	return procs, nil
+}
+
+// RunConfig contains one-off process run configuration.
+type RunConfig struct {
+	App *App
+
+	Command string
+	// Attach waits for the process to exit, capturing its exit code and output.
+	Attach bool
+}
+
+// Validate validates a run configuration.
+func (c RunConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if strings.TrimSpace(c.Command) == "" {
+		return errors.New("a command is required")
+	}
+
+	return nil
+}
+
+// Run runs a one-off process with the image and config of the current release.
+func (a *Aura) Run(ctx context.Context, cfg RunConfig) (*Run, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+
+	release, err := a.currentRelease(ctx, cfg.App)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.prepareRelease(ctx, cfg.App, release); err != nil {
+		return nil, err
+	}
+
+	run := &Run{
+		Command: cfg.Command,
+		Attach:  cfg.Attach,
+	}
+	if err = a.sched.Run(ctx, cfg.App, release, run); err != nil {
+		return nil, fmt.Errorf("could not run process: %w", err)
+	}
+	return run, nil
+}
@@ -0,0 +1,147 @@
+package aura_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/memory"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAura_Processes(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	err = sched.Submit(context.Background(), app, &aura.Release{ID: "123", AppID: app.ID, Version: 2, Procfile: []byte("web: ./app")}, nil)
+	require.NoError(t, err)
+
+	got, err := a.Processes(context.Background(), app)
+
+	require.NoError(t, err)
+	want := []*aura.Process{{ID: "123.web.1", Type: "web", Command: "./app", Version: 2, State: "running"}}
+	assert.Equal(t, want, got)
+}
+
+func TestAura_ProcessesHandlesSchedulerError(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := &mockScheduler{}
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	sched.On("Processes", app).Return(nil, errors.New("test"))
+
+	_, err = a.Processes(context.Background(), app)
+
+	require.Error(t, err)
+}
+
+func TestAura_Run(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	release, err := a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	got, err := a.Run(context.Background(), aura.RunConfig{App: app, Command: "rake db:migrate", Attach: true})
+
+	require.NoError(t, err)
+	assert.Equal(t, release.ID+".run", got.ID)
+	assert.Equal(t, "rake db:migrate", got.Command)
+	require.NotNil(t, got.ExitCode)
+	assert.Equal(t, 0, *got.ExitCode)
+}
+
+func TestAura_RunHandlesNoRelease(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.Run(context.Background(), aura.RunConfig{App: app, Command: "rake db:migrate"})
+
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_RunHandlesSchedulerError(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	sched.On("Run", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("test"))
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	_, err = a.Run(context.Background(), aura.RunConfig{App: app, Command: "rake db:migrate"})
+
+	assert.Error(t, err)
+}
+
+func TestAura_RunHandlesValidationError(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  aura.RunConfig
+	}{
+		{
+			name: "no app",
+			cfg:  aura.RunConfig{Command: "rake db:migrate"},
+		},
+		{
+			name: "invalid app",
+			cfg:  aura.RunConfig{App: &aura.App{}, Command: "rake db:migrate"},
+		},
+		{
+			name: "no command",
+			cfg:  aura.RunConfig{App: &aura.App{ID: "123"}, Command: " "},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			reg := &mockRegistry{}
+			sched := memory.NewScheduler()
+
+			a := aura.New(db, reg, sched)
+
+			_, err := a.Run(context.Background(), test.cfg)
+
+			assert.ErrorAs(t, err, &aura.ValidationError{})
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package aura
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nrwiersma/aura/pkg/keyring"
+	"github.com/segmentio/ksuid"
+	"gorm.io/gorm"
+)
+
+// RegistryAuth contains the credentials of an image registry.
+type RegistryAuth struct {
+	Username string
+	Password string
+}
+
+// RegistryCredential contains the encrypted registry credentials
+// of an application.
+type RegistryCredential struct {
+	ID       string
+	AppID    string
+	Registry string
+	Username string
+	// KeyID is the ID of the master key the data key is encrypted with.
+	KeyID string
+	// DataKey is the encrypted data key.
+	DataKey []byte
+	// Password is the encrypted password.
+	Password  []byte
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+// BeforeCreate is a pre-creation hook.
+func (c *RegistryCredential) BeforeCreate(_ *gorm.DB) error {
+	c.ID = ksuid.New().String()
+
+	now := time.Now().UTC()
+	c.CreatedAt = &now
+	c.UpdatedAt = &now
+
+	return nil
+}
+
+// BeforeUpdate is a pre-update hook.
+func (c *RegistryCredential) BeforeUpdate(_ *gorm.DB) error {
+	now := time.Now().UTC()
+	c.UpdatedAt = &now
+
+	return nil
+}
+
+type registryCredentialService struct {
+	db *DB
+}
+
+func (s *registryCredentialService) First(ctx context.Context, scope scope) (*RegistryCredential, error) {
+	var cred *RegistryCredential
+	scope = composedScope{order("registry"), scope}
+	return cred, s.db.WithContext(ctx).Scopes(scope.scope).First(&cred).Error
+}
+
+func (s *registryCredentialService) Find(ctx context.Context, scope scope) ([]*RegistryCredential, error) {
+	var creds []*RegistryCredential
+	scope = composedScope{order("registry"), scope}
+	return creds, s.db.WithContext(ctx).Scopes(scope.scope).Find(&creds).Error
+}
+
+func (s *registryCredentialService) Create(ctx context.Context, cred *RegistryCredential) (*RegistryCredential, error) {
+	return cred, s.db.WithContext(ctx).Create(cred).Error
+}
+
+func (s *registryCredentialService) Update(ctx context.Context, cred *RegistryCredential) error {
+	return s.db.WithContext(ctx).Save(cred).Error
+}
+
+func (s *registryCredentialService) Delete(ctx context.Context, cred *RegistryCredential) error {
+	return s.db.WithContext(ctx).Delete(cred).Error
+}
+
+// DockerHub is the registry host of Docker Hub.
+const DockerHub = "docker.io"
+
+// RegistryHost returns the normalised host of a registry, as used to
+// match credentials to images. An empty registry is Docker Hub.
+func RegistryHost(registry string) string {
+	host := strings.ToLower(registry)
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+
+	switch host {
+	case "", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return DockerHub
+	default:
+		return host
+	}
+}
+
+// ParseDockerConfig parses the registry credentials in a Docker
+// config.json file, keyed by registry host.
+//
+// Credential stores and helpers are not supported, registries
+// without credentials in the file are skipped.
+func ParseDockerConfig(r io.Reader) (map[string]RegistryAuth, error) {
+	var cfg struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.NewDecoder(r).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("decoding docker config: %w", err)
+	}
+
+	auths := make(map[string]RegistryAuth, len(cfg.Auths))
+	for reg, auth := range cfg.Auths {
+		user, pass := auth.Username, auth.Password
+		if auth.Auth != "" {
+			b, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("decoding auth of %q: %w", reg, err)
+			}
+			var ok bool
+			user, pass, ok = strings.Cut(string(b), ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid auth of %q", reg)
+			}
+		}
+		if user == "" && pass == "" {
+			continue
+		}
+
+		auths[RegistryHost(reg)] = RegistryAuth{Username: user, Password: pass}
+	}
+	return auths, nil
+}
+
+// RegistryCredentials returns the registry credentials of an application.
+//
+// The passwords are not decrypted.
+func (a *Aura) RegistryCredentials(ctx context.Context, app *App) ([]*RegistryCredential, error) {
+	creds, err := a.regCreds.Find(ctx, fieldEquals("app_id", app.ID))
+	if err != nil {
+		return nil, fmt.Errorf("could not find registry credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// registryHostRegexp matches registry hosts, with an optional port.
+var registryHostRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:[0-9]+)?$`)
+
+// SetRegistryCredentialConfig contains registry credential update configuration.
+type SetRegistryCredentialConfig struct {
+	App *App
+
+	Registry string
+	Username string
+	Password string
+}
+
+// Validate validates a set registry credential configuration.
+func (c SetRegistryCredentialConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if !registryHostRegexp.MatchString(RegistryHost(c.Registry)) {
+		return fmt.Errorf("invalid registry %q", c.Registry)
+	}
+	if c.Username == "" {
+		return errors.New("a username is required")
+	}
+	if c.Password == "" {
+		return errors.New("a password is required")
+	}
+
+	return nil
+}
+
+// SetRegistryCredential creates or updates the credentials of an
+// application for a registry.
+//
+// The password is encrypted with a new data key under the primary master key.
+func (a *Aura) SetRegistryCredential(ctx context.Context, cfg SetRegistryCredentialConfig) (*RegistryCredential, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+	if a.keys == nil {
+		return nil, ErrNoKeyring
+	}
+
+	reg := RegistryHost(cfg.Registry)
+	env, err := a.keys.Seal([]byte(cfg.Password), registryCredentialAD(cfg.App.ID, reg))
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt registry credential: %w", err)
+	}
+
+	cred, err := a.registryCredential(ctx, cfg.App, reg)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			cred, err = a.regCreds.Create(ctx, &RegistryCredential{
+				AppID:    cfg.App.ID,
+				Registry: reg,
+				Username: cfg.Username,
+				KeyID:    env.KeyID,
+				DataKey:  env.DataKey,
+				Password: env.Ciphertext,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("could not create registry credential: %w", err)
+			}
+			return cred, nil
+		default:
+			return nil, err
+		}
+	}
+
+	cred.Username = cfg.Username
+	cred.KeyID = env.KeyID
+	cred.DataKey = env.DataKey
+	cred.Password = env.Ciphertext
+	if err = a.regCreds.Update(ctx, cred); err != nil {
+		return nil, fmt.Errorf("could not update registry credential: %w", err)
+	}
+	return cred, nil
+}
+
+// DeleteRegistryCredentialConfig contains registry credential removal configuration.
+type DeleteRegistryCredentialConfig struct {
+	App *App
+
+	Registry string
+}
+
+// Validate validates a delete registry credential configuration.
+func (c DeleteRegistryCredentialConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if c.Registry == "" {
+		return errors.New("a registry is required")
+	}
+
+	return nil
+}
+
+// DeleteRegistryCredential removes the credentials of an application for a registry.
+func (a *Aura) DeleteRegistryCredential(ctx context.Context, cfg DeleteRegistryCredentialConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return ValidationError{err: err}
+	}
+
+	cred, err := a.registryCredential(ctx, cfg.App, RegistryHost(cfg.Registry))
+	if err != nil {
+		return err
+	}
+
+	if err = a.regCreds.Delete(ctx, cred); err != nil {
+		return fmt.Errorf("could not delete registry credential: %w", err)
+	}
+	return nil
+}
+
+func (a *Aura) registryCredential(ctx context.Context, app *App, reg string) (*RegistryCredential, error) {
+	cred, err := a.regCreds.First(ctx, composedScope{fieldEquals("app_id", app.ID), fieldEquals("registry", reg)})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("could not find registry credential: %w", err)
+		}
+	}
+	return cred, nil
+}
+
+// registryAuth returns the credentials of the application for the
+// registry, falling back to the server wide credentials. If there
+// are no credentials for the registry, nil is returned.
+func (a *Aura) registryAuth(ctx context.Context, app *App, registry string) (*RegistryAuth, error) {
+	reg := RegistryHost(registry)
+
+	// Credentials can only be stored with a keyring.
+	if a.keys != nil {
+		cred, err := a.registryCredential(ctx, app, reg)
+		switch {
+		case err == nil:
+			b, err := a.keys.Open(keyring.Envelope{
+				KeyID:      cred.KeyID,
+				DataKey:    cred.DataKey,
+				Ciphertext: cred.Password,
+			}, registryCredentialAD(cred.AppID, cred.Registry))
+			if err != nil {
+				return nil, fmt.Errorf("could not decrypt registry credential: %w", err)
+			}
+			return &RegistryAuth{Username: cred.Username, Password: string(b)}, nil
+		case errors.Is(err, ErrNotFound):
+		default:
+			return nil, err
+		}
+	}
+
+	if auth, ok := a.registryAuths[reg]; ok {
+		return &auth, nil
+	}
+	return nil, nil
+}
+
+// registryCredentialAD returns the additional data binding an encrypted
+// registry credential to its application and registry.
+func registryCredentialAD(appID, registry string) []byte {
+	return []byte(appID + "/registry/" + registry)
+}
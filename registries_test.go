@@ -0,0 +1,312 @@
+package aura_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/memory"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAura_SetRegistryCredential(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.SetRegistryCredential(context.Background(), aura.SetRegistryCredentialConfig{
+		App:      app,
+		Registry: "ghcr.io",
+		Username: "alice",
+		Password: "old",
+	})
+	require.NoError(t, err)
+
+	got, err := a.SetRegistryCredential(context.Background(), aura.SetRegistryCredentialConfig{
+		App:      app,
+		Registry: "https://GHCR.io/v2/",
+		Username: "bob",
+		Password: "hunter2",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr.io", got.Registry)
+	assert.Equal(t, "bob", got.Username)
+	assert.Equal(t, "key1", got.KeyID)
+	assert.NotContains(t, string(got.Password), "hunter2")
+	creds, err := a.RegistryCredentials(context.Background(), app)
+	require.NoError(t, err)
+	require.Len(t, creds, 1)
+	assert.Equal(t, got.ID, creds[0].ID)
+}
+
+func TestAura_SetRegistryCredentialValidatesConfig(t *testing.T) {
+	app := &aura.App{ID: "123"}
+
+	tests := []struct {
+		name string
+		cfg  aura.SetRegistryCredentialConfig
+	}{
+		{
+			name: "no app",
+			cfg:  aura.SetRegistryCredentialConfig{Registry: "ghcr.io", Username: "bob", Password: "hunter2"},
+		},
+		{
+			name: "invalid registry",
+			cfg:  aura.SetRegistryCredentialConfig{App: app, Registry: "ghcr_io", Username: "bob", Password: "hunter2"},
+		},
+		{
+			name: "no username",
+			cfg:  aura.SetRegistryCredentialConfig{App: app, Registry: "ghcr.io", Password: "hunter2"},
+		},
+		{
+			name: "no password",
+			cfg:  aura.SetRegistryCredentialConfig{App: app, Registry: "ghcr.io", Username: "bob"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			reg := &mockRegistry{}
+			sched := memory.NewScheduler()
+
+			a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+			_, err := a.SetRegistryCredential(context.Background(), test.cfg)
+
+			assert.ErrorAs(t, err, &aura.ValidationError{})
+		})
+	}
+}
+
+func TestAura_SetRegistryCredentialHandlesNoKeyring(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.SetRegistryCredential(context.Background(), aura.SetRegistryCredentialConfig{
+		App:      app,
+		Registry: "ghcr.io",
+		Username: "bob",
+		Password: "hunter2",
+	})
+
+	assert.ErrorIs(t, err, aura.ErrNoKeyring)
+}
+
+func TestAura_DeleteRegistryCredential(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.SetRegistryCredential(context.Background(), aura.SetRegistryCredentialConfig{
+		App:      app,
+		Registry: "ghcr.io",
+		Username: "bob",
+		Password: "hunter2",
+	})
+	require.NoError(t, err)
+
+	err = a.DeleteRegistryCredential(context.Background(), aura.DeleteRegistryCredentialConfig{App: app, Registry: "ghcr.io"})
+
+	require.NoError(t, err)
+	creds, err := a.RegistryCredentials(context.Background(), app)
+	require.NoError(t, err)
+	assert.Empty(t, creds)
+	err = a.DeleteRegistryCredential(context.Background(), aura.DeleteRegistryCredentialConfig{App: app, Registry: "ghcr.io"})
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_DeployUsesRegistryAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		image    string
+		appCreds bool
+		want     *aura.RegistryAuth
+	}{
+		{
+			name:     "app credentials",
+			image:    "ghcr.io/foo/bar:latest",
+			appCreds: true,
+			want:     &aura.RegistryAuth{Username: "app", Password: "app-pass"},
+		},
+		{
+			name:  "server credentials",
+			image: "ghcr.io/foo/bar:latest",
+			want:  &aura.RegistryAuth{Username: "server", Password: "server-pass"},
+		},
+		{
+			name:  "docker hub credentials",
+			image: "foo/bar:latest",
+			want:  &aura.RegistryAuth{Username: "hub", Password: "hub-pass"},
+		},
+		{
+			name:     "no credentials",
+			image:    "quay.io/foo/bar:latest",
+			appCreds: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			img, err := image.Decode(test.image)
+			require.NoError(t, err)
+
+			db := testDB(t)
+			reg := &mockRegistry{}
+			reg.On("Resolve", img).Return(img, nil)
+			reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+			sched := memory.NewScheduler()
+
+			a := aura.New(db, reg, sched,
+				aura.WithKeyring(testKeyring(t, testKey1)),
+				aura.WithRegistryAuths(map[string]aura.RegistryAuth{
+					"https://ghcr.io":             {Username: "server", Password: "server-pass"},
+					"https://index.docker.io/v1/": {Username: "hub", Password: "hub-pass"},
+				}),
+			)
+
+			app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+			require.NoError(t, err)
+			if test.appCreds {
+				_, err = a.SetRegistryCredential(context.Background(), aura.SetRegistryCredentialConfig{
+					App:      app,
+					Registry: "ghcr.io",
+					Username: "app",
+					Password: "app-pass",
+				})
+				require.NoError(t, err)
+			}
+
+			_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+			require.NoError(t, err)
+			assert.Equal(t, []*aura.RegistryAuth{test.want, test.want}, reg.auths)
+		})
+	}
+}
+
+func TestParseDockerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    map[string]aura.RegistryAuth
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "handles auth",
+			config: `{"auths":{"https://index.docker.io/v1/":{"auth":"` +
+				base64.StdEncoding.EncodeToString([]byte("bob:hunter:2")) + `"}}}`,
+			want:    map[string]aura.RegistryAuth{"docker.io": {Username: "bob", Password: "hunter:2"}},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles username and password",
+			config:  `{"auths":{"ghcr.io":{"username":"bob","password":"hunter2"}}}`,
+			want:    map[string]aura.RegistryAuth{"ghcr.io": {Username: "bob", Password: "hunter2"}},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "skips registries without credentials",
+			config:  `{"auths":{"ghcr.io":{}},"credsStore":"desktop"}`,
+			want:    map[string]aura.RegistryAuth{},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles invalid auth encoding",
+			config:  `{"auths":{"ghcr.io":{"auth":"not base64"}}}`,
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles auth without password",
+			config:  `{"auths":{"ghcr.io":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("bob")) + `"}}}`,
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles invalid json",
+			config:  `{`,
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got, err := aura.ParseDockerConfig(strings.NewReader(test.config))
+
+			test.wantErr(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestRegistryHost(t *testing.T) {
+	tests := []struct {
+		registry string
+		want     string
+	}{
+		{registry: "", want: "docker.io"},
+		{registry: "https://index.docker.io/v1/", want: "docker.io"},
+		{registry: "registry-1.docker.io", want: "docker.io"},
+		{registry: "GHCR.io", want: "ghcr.io"},
+		{registry: "http://localhost:5000/v2/", want: "localhost:5000"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.registry, func(t *testing.T) {
+			got := aura.RegistryHost(test.registry)
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestAura_RedeployPassesRegistryAuth(t *testing.T) {
+	img, err := image.Decode("ghcr.io/foo/bar:latest")
+	require.NoError(t, err)
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	want := aura.RegistryAuth{Username: "server", Password: "server-pass"}
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.MatchedBy(func(release *aura.Release) bool {
+		return release.Auth != nil && *release.Auth == want
+	}), mock.Anything).Return(nil).Twice()
+
+	a := aura.New(db, reg, sched, aura.WithRegistryAuths(map[string]aura.RegistryAuth{"ghcr.io": want}))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	_, err = a.Redeploy(context.Background(), aura.RedeployConfig{App: app})
+
+	require.NoError(t, err)
+	sched.AssertExpectations(t)
+}
@@ -14,15 +14,41 @@ import (
 
 // Release contains the info for a release.
 type Release struct {
-	ID        string
-	AppID     string
-	App       *App
-	Image     *image.Image
-	Version   int
-	Procfile  []byte
-	CreatedAt *time.Time
+	ID              string
+	AppID           string
+	App             *App
+	Image           *image.Image
+	IndexDigest     string
+	Platform        *image.Platform
+	Version         int
+	Procfile        []byte
+	ConfigID        *string
+	Config          *Config
+	Reason          string
+	RollbackVersion *int
+	Status          string
+	Output          []byte
+	CreatedAt       *time.Time
+
+	// Secrets contains the decrypted secrets of the application, set
+	// when the release is scheduled. They are never stored.
+	Secrets Vars `gorm:"-"`
+	// Auth contains the credentials of the image registry, set when
+	// the release is scheduled. They are never stored.
+	Auth *RegistryAuth `gorm:"-"`
 }
 
+// Release statuses.
+const (
+	// ReleasePending is the status of a release being released.
+	ReleasePending = "pending"
+	// ReleaseActive is the status of a release that was deployed.
+	ReleaseActive = "active"
+	// ReleaseFailed is the status of a release whose release phase
+	// or submission failed.
+	ReleaseFailed = "failed"
+)
+
 // BeforeCreate is a pre-creation hook.
 func (r *Release) BeforeCreate(_ *gorm.DB) error {
 	r.ID = ksuid.New().String()
@@ -33,7 +59,7 @@ func (r *Release) BeforeCreate(_ *gorm.DB) error {
 	return nil
 }
 
-var releasesPreload = preload("App")
+var releasesPreload = preload("App", "Config")
 
 type releaseService struct {
 	db *DB
@@ -45,6 +71,13 @@ func (s *releaseService) First(ctx context.Context, scope scope) (*Release, erro
 	return release, s.db.WithContext(ctx).Scopes(scope.scope).First(&release).Error
 }
 
+func (s *releaseService) Latest(ctx context.Context, scope scope) (*Release, error) {
+	var release *Release
+	scope = composedScope{releasesPreload, order("version DESC"), scope}
+	// Take is used as First orders by the primary key before the scopes are applied.
+	return release, s.db.WithContext(ctx).Scopes(scope.scope).Take(&release).Error
+}
+
 func (s *releaseService) Find(ctx context.Context, scope scope) ([]*Release, error) {
 	var releases []*Release
 	scope = composedScope{releasesPreload, order("version"), scope}
@@ -55,8 +88,10 @@ func (s *releaseService) Create(ctx context.Context, release *Release) (*Release
 	tx := s.db.WithContext(ctx).Begin()
 	defer func() { _ = tx.Rollback() }()
 
-	// Lock the releases for this app for updates, so the version number if unique in a race.
-	_ = tx.Where("app_id = ?", release.AppID).Clauses(clause.Locking{Strength: "UPDATE"}).Error
+	// Lock the app for updates, so the version number is unique in a race.
+	if err := lockApp(tx, release.AppID); err != nil {
+		return nil, fmt.Errorf("locking app: %w", err)
+	}
 
 	ver, err := s.currentVersion(tx, release.AppID)
 	if err != nil {
@@ -69,13 +104,17 @@ func (s *releaseService) Create(ctx context.Context, release *Release) (*Release
 	}
 	release.Version = ver + 1
 
-	if err = tx.Create(release).Error; err != nil {
+	if err = tx.Omit(clause.Associations).Create(release).Error; err != nil {
 		return nil, fmt.Errorf("creating release: %w", err)
 	}
 
 	return release, tx.Commit().Error
 }
 
+func (s *releaseService) Update(ctx context.Context, release *Release) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Save(release).Error
+}
+
 func (s *releaseService) currentVersion(tx *gorm.DB, appID string) (int, error) {
 	var release *Release
 	return release.Version, tx.Where("app_id = ?", appID).Order("version DESC").First(&release).Error
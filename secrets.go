@@ -0,0 +1,369 @@
+package aura
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nrwiersma/aura/pkg/keyring"
+	"github.com/segmentio/ksuid"
+	"gorm.io/gorm"
+)
+
+// Secret contains an encrypted secret of an application.
+type Secret struct {
+	ID    string
+	AppID string
+	Name  string
+	// KeyID is the ID of the master key the data key is encrypted with.
+	KeyID string
+	// DataKey is the encrypted data key.
+	DataKey []byte
+	// Value is the encrypted secret value.
+	Value     []byte
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
+
+// BeforeCreate is a pre-creation hook.
+func (s *Secret) BeforeCreate(_ *gorm.DB) error {
+	s.ID = ksuid.New().String()
+
+	now := time.Now().UTC()
+	s.CreatedAt = &now
+	s.UpdatedAt = &now
+
+	return nil
+}
+
+// BeforeUpdate is a pre-update hook.
+func (s *Secret) BeforeUpdate(_ *gorm.DB) error {
+	now := time.Now().UTC()
+	s.UpdatedAt = &now
+
+	return nil
+}
+
+type secretService struct {
+	db *DB
+}
+
+func (s *secretService) First(ctx context.Context, scope scope) (*Secret, error) {
+	var secret *Secret
+	scope = composedScope{order("name"), scope}
+	return secret, s.db.WithContext(ctx).Scopes(scope.scope).First(&secret).Error
+}
+
+func (s *secretService) Find(ctx context.Context, scope scope) ([]*Secret, error) {
+	var secrets []*Secret
+	scope = composedScope{order("name"), scope}
+	return secrets, s.db.WithContext(ctx).Scopes(scope.scope).Find(&secrets).Error
+}
+
+func (s *secretService) Create(ctx context.Context, secret *Secret) (*Secret, error) {
+	return secret, s.db.WithContext(ctx).Create(secret).Error
+}
+
+func (s *secretService) Update(ctx context.Context, secret *Secret) error {
+	return s.db.WithContext(ctx).Save(secret).Error
+}
+
+func (s *secretService) Delete(ctx context.Context, secret *Secret) error {
+	return s.db.WithContext(ctx).Delete(secret).Error
+}
+
+// Secrets returns the secrets of an application.
+//
+// The secret values are not decrypted.
+func (a *Aura) Secrets(ctx context.Context, app *App) ([]*Secret, error) {
+	secrets, err := a.secrets.Find(ctx, fieldEquals("app_id", app.ID))
+	if err != nil {
+		return nil, fmt.Errorf("could not find secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// SecretValue returns the decrypted value of an application secret.
+func (a *Aura) SecretValue(ctx context.Context, app *App, name string) (string, error) {
+	if a.keys == nil {
+		return "", ErrNoKeyring
+	}
+
+	secret, err := a.secret(ctx, app, name)
+	if err != nil {
+		return "", err
+	}
+
+	return a.openSecret(secret)
+}
+
+// openSecret decrypts the value of a secret.
+func (a *Aura) openSecret(secret *Secret) (string, error) {
+	b, err := a.keys.Open(keyring.Envelope{
+		KeyID:      secret.KeyID,
+		DataKey:    secret.DataKey,
+		Ciphertext: secret.Value,
+	}, secretAD(secret.AppID, secret.Name))
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt secret: %w", err)
+	}
+	return string(b), nil
+}
+
+// loadSecrets sets the decrypted secrets of an application on a release,
+// so they are passed to its processes by the scheduler.
+func (a *Aura) loadSecrets(ctx context.Context, app *App, release *Release) error {
+	secrets, err := a.Secrets(ctx, app)
+	if err != nil {
+		return err
+	}
+	if len(secrets) == 0 {
+		release.Secrets = nil
+		return nil
+	}
+	if a.keys == nil {
+		return ErrNoKeyring
+	}
+
+	vars := make(Vars, len(secrets))
+	for _, secret := range secrets {
+		val, err := a.openSecret(secret)
+		if err != nil {
+			return fmt.Errorf("could not open secret %q: %w", secret.Name, err)
+		}
+		vars[secret.Name] = val
+	}
+	release.Secrets = vars
+	return nil
+}
+
+// SetSecretConfig contains secret update configuration.
+type SetSecretConfig struct {
+	App *App
+
+	Name  string
+	Value string
+}
+
+// Validate validates a set secret configuration.
+func (c SetSecretConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if !varNameRegexp.MatchString(c.Name) || len(c.Name) > 100 {
+		return fmt.Errorf("invalid secret name %q", c.Name)
+	}
+
+	return nil
+}
+
+// SetSecret creates or updates an application secret, redeploying the
+// current release with it. If the secret is saved, but the release
+// could not be redeployed, the secret is returned with a RedeployError.
+//
+// The value is encrypted with a new data key under the primary master key.
+func (a *Aura) SetSecret(ctx context.Context, cfg SetSecretConfig) (*Secret, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, ValidationError{err: err}
+	}
+	if a.keys == nil {
+		return nil, ErrNoKeyring
+	}
+
+	unlock, err := a.lockActiveApp(ctx, cfg.App, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	env, err := a.keys.Seal([]byte(cfg.Value), secretAD(cfg.App.ID, cfg.Name))
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt secret: %w", err)
+	}
+
+	secret, err := a.secret(ctx, cfg.App, cfg.Name)
+	switch {
+	case err == nil:
+		secret.KeyID = env.KeyID
+		secret.DataKey = env.DataKey
+		secret.Value = env.Ciphertext
+		if err = a.secrets.Update(ctx, secret); err != nil {
+			return nil, fmt.Errorf("could not update secret: %w", err)
+		}
+	case errors.Is(err, ErrNotFound):
+		secret, err = a.secrets.Create(ctx, &Secret{
+			AppID:   cfg.App.ID,
+			Name:    cfg.Name,
+			KeyID:   env.KeyID,
+			DataKey: env.DataKey,
+			Value:   env.Ciphertext,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not create secret: %w", err)
+		}
+	default:
+		return nil, err
+	}
+
+	if err = a.redeploySecrets(ctx, cfg.App); err != nil {
+		return secret, err
+	}
+	return secret, nil
+}
+
+// DeleteSecretConfig contains secret removal configuration.
+type DeleteSecretConfig struct {
+	App *App
+
+	Name string
+}
+
+// Validate validates a delete secret configuration.
+func (c DeleteSecretConfig) Validate() error {
+	if c.App == nil {
+		return errors.New("an application is required")
+	}
+	if c.App.ID == "" {
+		return errors.New("the application is invalid")
+	}
+	if c.Name == "" {
+		return errors.New("a secret name is required")
+	}
+
+	return nil
+}
+
+// DeleteSecret removes an application secret, redeploying the current
+// release without it. If the secret is removed, but the release could
+// not be redeployed, a RedeployError is returned.
+func (a *Aura) DeleteSecret(ctx context.Context, cfg DeleteSecretConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return ValidationError{err: err}
+	}
+
+	unlock, err := a.lockActiveApp(ctx, cfg.App, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	secret, err := a.secret(ctx, cfg.App, cfg.Name)
+	if err != nil {
+		return err
+	}
+
+	if err = a.secrets.Delete(ctx, secret); err != nil {
+		return fmt.Errorf("could not delete secret: %w", err)
+	}
+
+	return a.redeploySecrets(ctx, cfg.App)
+}
+
+// redeploySecrets applies changed secrets to the current
+// release of the application, if there is one.
+func (a *Aura) redeploySecrets(ctx context.Context, app *App) error {
+	release, err := a.currentRelease(ctx, app)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return nil
+		default:
+			return err
+		}
+	}
+	if _, err = a.redeploy(ctx, app, release, ReasonSecretChange); err != nil {
+		return RedeployError{Err: err}
+	}
+	return nil
+}
+
+// ReencryptSecrets encrypts the data keys of all secrets and registry
+// credentials that are not encrypted with the primary master key,
+// returning the number of secrets and credentials updated. This
+// allows old master keys to be retired.
+func (a *Aura) ReencryptSecrets(ctx context.Context) (int, error) {
+	if a.keys == nil {
+		return 0, ErrNoKeyring
+	}
+
+	secrets, err := a.secrets.Find(ctx, scopeFunc(func(db *gorm.DB) *gorm.DB {
+		return db.Where("key_id <> ?", a.keys.Primary())
+	}))
+	if err != nil {
+		return 0, fmt.Errorf("could not find secrets: %w", err)
+	}
+
+	var n int
+	for _, secret := range secrets {
+		env, ok, err := a.keys.Rewrap(keyring.Envelope{
+			KeyID:      secret.KeyID,
+			DataKey:    secret.DataKey,
+			Ciphertext: secret.Value,
+		})
+		if err != nil {
+			return n, fmt.Errorf("could not re-encrypt secret %q of app %q: %w", secret.Name, secret.AppID, err)
+		}
+		if !ok {
+			continue
+		}
+
+		secret.KeyID = env.KeyID
+		secret.DataKey = env.DataKey
+		if err = a.secrets.Update(ctx, secret); err != nil {
+			return n, fmt.Errorf("could not update secret: %w", err)
+		}
+		n++
+	}
+
+	creds, err := a.regCreds.Find(ctx, scopeFunc(func(db *gorm.DB) *gorm.DB {
+		return db.Where("key_id <> ?", a.keys.Primary())
+	}))
+	if err != nil {
+		return n, fmt.Errorf("could not find registry credentials: %w", err)
+	}
+
+	for _, cred := range creds {
+		env, ok, err := a.keys.Rewrap(keyring.Envelope{
+			KeyID:      cred.KeyID,
+			DataKey:    cred.DataKey,
+			Ciphertext: cred.Password,
+		})
+		if err != nil {
+			return n, fmt.Errorf("could not re-encrypt registry credential %q of app %q: %w", cred.Registry, cred.AppID, err)
+		}
+		if !ok {
+			continue
+		}
+
+		cred.KeyID = env.KeyID
+		cred.DataKey = env.DataKey
+		if err = a.regCreds.Update(ctx, cred); err != nil {
+			return n, fmt.Errorf("could not update registry credential: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (a *Aura) secret(ctx context.Context, app *App, name string) (*Secret, error) {
+	secret, err := a.secrets.First(ctx, composedScope{fieldEquals("app_id", app.ID), fieldEquals("name", name)})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("could not find secret: %w", err)
+		}
+	}
+	return secret, nil
+}
+
+// secretAD returns the additional data binding an encrypted secret
+// to its application and name.
+func secretAD(appID, name string) []byte {
+	return []byte(appID + "/" + name)
+}
@@ -0,0 +1,310 @@
+package aura_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nrwiersma/aura"
+	"github.com/nrwiersma/aura/memory"
+	"github.com/nrwiersma/aura/pkg/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAura_SetSecret(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+
+	_, err = a.SetSecret(context.Background(), aura.SetSecretConfig{App: app, Name: "DB_PASS", Value: "old"})
+	require.NoError(t, err)
+
+	got, err := a.SetSecret(context.Background(), aura.SetSecretConfig{App: app, Name: "DB_PASS", Value: "hunter2"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "DB_PASS", got.Name)
+	assert.Equal(t, "key1", got.KeyID)
+	assert.NotContains(t, string(got.Value), "hunter2")
+	secrets, err := a.Secrets(context.Background(), app)
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	assert.Equal(t, got.ID, secrets[0].ID)
+	val, err := a.SecretValue(context.Background(), app, "DB_PASS")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", val)
+}
+
+func TestAura_DeployPassesSecrets(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("release: ./migrate\nweb: ./app"), nil)
+	sched := &mockScheduler{}
+	hasSecrets := mock.MatchedBy(func(r *aura.Release) bool {
+		return assert.ObjectsAreEqual(aura.Vars{"DB_PASS": "hunter2"}, r.Secrets)
+	})
+	sched.On("Run", mock.Anything, hasSecrets, mock.Anything).Return(nil)
+	sched.On("Submit", mock.Anything, hasSecrets, mock.Anything).Return(nil)
+
+	a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.SetSecret(context.Background(), aura.SetSecretConfig{App: app, Name: "DB_PASS", Value: "hunter2"})
+	require.NoError(t, err)
+
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+	_, err = a.Run(context.Background(), aura.RunConfig{App: app, Command: "rake db:migrate"})
+	require.NoError(t, err)
+
+	sched.AssertExpectations(t)
+	sched.AssertNumberOfCalls(t, "Run", 2)
+}
+
+func TestAura_DeployHandlesSecretsWithoutKeyring(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := &mockScheduler{}
+
+	a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.SetSecret(context.Background(), aura.SetSecretConfig{App: app, Name: "DB_PASS", Value: "hunter2"})
+	require.NoError(t, err)
+
+	a = aura.New(db, reg, sched)
+
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+
+	assert.ErrorIs(t, err, aura.ErrNoKeyring)
+	sched.AssertNotCalled(t, "Submit", mock.Anything, mock.Anything, mock.Anything)
+	releases, err := a.Releases(context.Background(), aura.ReleasesQuery{App: app})
+	require.NoError(t, err)
+	assert.Empty(t, releases)
+}
+
+func TestAura_SetSecretHandlesNoKeyring(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched)
+
+	_, err := a.SetSecret(context.Background(), aura.SetSecretConfig{App: &aura.App{ID: "123"}, Name: "DB_PASS"})
+
+	assert.ErrorIs(t, err, aura.ErrNoKeyring)
+}
+
+func TestAura_SetSecretHandlesValidationError(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  aura.SetSecretConfig
+	}{
+		{
+			name: "no app",
+			cfg:  aura.SetSecretConfig{Name: "DB_PASS"},
+		},
+		{
+			name: "invalid app",
+			cfg:  aura.SetSecretConfig{App: &aura.App{}, Name: "DB_PASS"},
+		},
+		{
+			name: "invalid name",
+			cfg:  aura.SetSecretConfig{App: &aura.App{ID: "123"}, Name: "1DB-PASS"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			reg := &mockRegistry{}
+			sched := memory.NewScheduler()
+
+			a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+			_, err := a.SetSecret(context.Background(), test.cfg)
+
+			assert.ErrorAs(t, err, &aura.ValidationError{})
+		})
+	}
+}
+
+func TestAura_DeleteSecret(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.SetSecret(context.Background(), aura.SetSecretConfig{App: app, Name: "DB_PASS", Value: "hunter2"})
+	require.NoError(t, err)
+
+	err = a.DeleteSecret(context.Background(), aura.DeleteSecretConfig{App: app, Name: "DB_PASS"})
+
+	require.NoError(t, err)
+	secrets, err := a.Secrets(context.Background(), app)
+	require.NoError(t, err)
+	assert.Empty(t, secrets)
+	err = a.DeleteSecret(context.Background(), aura.DeleteSecretConfig{App: app, Name: "DB_PASS"})
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_SecretChangesRedeploy(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	_, err = a.SetSecret(context.Background(), aura.SetSecretConfig{App: app, Name: "DB_PASS", Value: "hunter2"})
+	require.NoError(t, err)
+	err = a.DeleteSecret(context.Background(), aura.DeleteSecretConfig{App: app, Name: "DB_PASS"})
+	require.NoError(t, err)
+
+	sched.AssertCalled(t, "Submit", app, mock.MatchedBy(func(r *aura.Release) bool {
+		return r.Version == 2 && r.Reason == aura.ReasonSecretChange &&
+			assert.ObjectsAreEqual(aura.Vars{"DB_PASS": "hunter2"}, r.Secrets)
+	}), mock.Anything)
+	sched.AssertCalled(t, "Submit", app, mock.MatchedBy(func(r *aura.Release) bool {
+		return r.Version == 3 && r.Reason == aura.ReasonSecretChange && len(r.Secrets) == 0
+	}), mock.Anything)
+}
+
+func TestAura_SetSecretHandlesRedeployError(t *testing.T) {
+	img := image.Image{Registry: "docker.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := &mockScheduler{}
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	sched.On("Submit", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("test"))
+
+	a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = a.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+
+	got, err := a.SetSecret(context.Background(), aura.SetSecretConfig{App: app, Name: "DB_PASS", Value: "hunter2"})
+
+	require.ErrorAs(t, err, &aura.RedeployError{})
+	require.NotNil(t, got)
+	val, err := a.SecretValue(context.Background(), app, "DB_PASS")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", val)
+	err = a.DeleteSecret(context.Background(), aura.DeleteSecretConfig{App: app, Name: "DB_PASS"})
+	assert.ErrorAs(t, err, &aura.RedeployError{})
+}
+
+func TestAura_SecretChangesHandleDestroyedApp(t *testing.T) {
+	db := testDB(t)
+	a := aura.New(db, &mockRegistry{}, memory.NewScheduler(), aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := a.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	err = a.Destroy(context.Background(), aura.DestroyConfig{App: app})
+	require.NoError(t, err)
+
+	_, err = a.SetSecret(context.Background(), aura.SetSecretConfig{App: app, Name: "DB_PASS", Value: "hunter2"})
+
+	assert.ErrorIs(t, err, aura.ErrNotFound)
+}
+
+func TestAura_ReencryptSecrets(t *testing.T) {
+	db := testDB(t)
+	reg := &mockRegistry{}
+	sched := memory.NewScheduler()
+
+	old := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := old.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = old.SetSecret(context.Background(), aura.SetSecretConfig{App: app, Name: "DB_PASS", Value: "hunter2"})
+	require.NoError(t, err)
+
+	a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey2, testKey1)))
+
+	got, err := a.ReencryptSecrets(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, got)
+	secrets, err := a.Secrets(context.Background(), app)
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	assert.Equal(t, "key2", secrets[0].KeyID)
+	rotated := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey2)))
+	val, err := rotated.SecretValue(context.Background(), app, "DB_PASS")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", val)
+	got, err = a.ReencryptSecrets(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, got)
+}
+
+func TestAura_ReencryptSecretsHandlesRegistryCredentials(t *testing.T) {
+	img := image.Image{Registry: "ghcr.io", Repository: "foo/bar", Tag: "latest"}
+
+	db := testDB(t)
+	reg := &mockRegistry{}
+	reg.On("Resolve", img).Return(img, nil)
+	reg.On("ExtractProcfile", img.String()).Return([]byte("web: ./app"), nil)
+	sched := memory.NewScheduler()
+
+	old := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey1)))
+
+	app, err := old.Create(context.Background(), aura.CreateConfig{Name: "test-app"})
+	require.NoError(t, err)
+	_, err = old.SetRegistryCredential(context.Background(), aura.SetRegistryCredentialConfig{
+		App:      app,
+		Registry: "ghcr.io",
+		Username: "bob",
+		Password: "hunter2",
+	})
+	require.NoError(t, err)
+
+	a := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey2, testKey1)))
+
+	got, err := a.ReencryptSecrets(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, got)
+	creds, err := a.RegistryCredentials(context.Background(), app)
+	require.NoError(t, err)
+	require.Len(t, creds, 1)
+	assert.Equal(t, "key2", creds[0].KeyID)
+	rotated := aura.New(db, reg, sched, aura.WithKeyring(testKeyring(t, testKey2)))
+	_, err = rotated.Deploy(context.Background(), aura.DeployConfig{App: app, Image: img})
+	require.NoError(t, err)
+	assert.Contains(t, reg.auths, &aura.RegistryAuth{Username: "bob", Password: "hunter2"})
+}